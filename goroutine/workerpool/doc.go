@@ -9,7 +9,8 @@ would exhaust resources.
 Non-blocking task submission
 
 A task is a function submitted to the worker pool for execution. Submitting
-tasks to this worker pool will not block, regardless of the number of tasks.
+tasks to this worker pool will not block, regardless of the number of tasks,
+unless the waiting queue is bounded as described below.
 Incoming tasks are immediately dispatched to an available worker. If no worker
 is immediately available, or there are already tasks waiting for an available
 worker, then the task is put on a waiting queue to wait for an available
@@ -24,6 +25,29 @@ distributing load over multiple systems, and/or storing input for pending
 processing in intermediate storage such as a database, file system, distributed
 message queue, etc.
 
+Futures
+
+Tasks that produce a result can be submitted with SubmitFuture, which takes a
+func(context.Context) (T, error) and returns a Future. Future.Get waits for the
+result, Future.Done can be used in a select, and Future.Cancel cancels the
+context passed to the task. A task that is cancelled while still waiting in the
+queue is never run. SubmitAll submits a batch of such tasks, CollectAll waits
+for all of their results or returns the first error after cancelling the rest,
+and CollectSettled waits for every task and returns each result and error.
+
+Bounded waiting queue
+
+By default the waiting queue is unbounded. To protect against bursts of tasks
+exhausting memory, create the pool with WithQueueCapacity to limit the number
+of tasks that are submitted but not yet started, and choose what happens to a
+task submitted while the queue is full: OverflowBlock blocks the caller until
+there is room, OverflowFailFast and OverflowDropNewest reject the new task,
+OverflowDropOldest discards the oldest waiting task to make room, and
+OverflowCallerRuns runs the new task in the caller's goroutine. TrySubmit
+reports rejected tasks as errors, and Rejected returns the number of tasks that
+were rejected, dropped or run by the caller. Tasks submitted by SubmitWait and
+Pause are not limited by the capacity.
+
 Dispatcher
 
 This worker pool uses a single dispatcher goroutine to read tasks from the
@@ -0,0 +1,176 @@
+package workerpool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Future 状态
+const (
+	futurePending int32 = iota // 在等待队列中，还未开始执行
+	futureRunning              // 正在执行
+	futureSkipped              // 开始执行前 ctx 已经取消或任务被丢弃，不再执行
+)
+
+// Future 表示提交到协程池中的带返回值任务的执行结果
+type Future[T any] struct {
+	done   chan struct{}      // 任务结束后关闭
+	ctx    context.Context    // 传递给任务函数的 ctx
+	cancel context.CancelFunc // 取消任务的 ctx
+	stop   func() bool        // 注销 ctx 取消时的回调
+	state  atomic.Int32       // 执行状态，保证任务函数最多执行一次、结果只写入一次
+	value  T                  // 任务结果，done 关闭后才能读取
+	err    error              // 任务错误，done 关闭后才能读取
+}
+
+// SubmitFuture 将带返回值的任务函数提交到协程池，返回用于获取结果的 Future，不会阻塞调用方
+//
+// 任务函数接收的 ctx 派生自参数 ctx，在调用 Future.Cancel 或参数 ctx 取消时被取消，
+// 任务函数需要自行检查 ctx 以便尽快返回。
+// 如果任务在开始执行之前就被取消，任务函数不会执行，Future 立即以 ctx 的取消原因结束，
+// 不需要等待任务从等待队列中轮到执行。
+//
+// 等待队列已满时按照协程池的 OverflowPolicy 处理，任务被拒绝或丢弃时 Future 以对应的错误结束，
+// 调用 Stop 时还在等待队列中的任务以 ErrStopped 结束。
+// 与 Submit 相同，协程池停止后不能再提交任务。
+func SubmitFuture[T any](ctx context.Context, p *WorkerPool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	f.stop = context.AfterFunc(f.ctx, func() {
+		if f.state.CompareAndSwap(futurePending, futureSkipped) {
+			var zero T
+			f.complete(zero, context.Cause(f.ctx))
+		}
+	})
+
+	err := p.submit(task{
+		run: func() {
+			// ctx 取消后由 AfterFunc 的回调结束 Future，回调在单独的协程中执行，此时可能还未执行
+			if f.ctx.Err() != nil || !f.state.CompareAndSwap(futurePending, futureRunning) {
+				return
+			}
+			f.stop()
+			f.complete(fn(f.ctx))
+		},
+		drop:    f.drop,
+		bounded: p.capacity > 0,
+	})
+	if err != nil {
+		f.drop(err)
+	}
+	return f
+}
+
+// drop 任务被协程池拒绝或丢弃，以 err 结束 Future
+func (f *Future[T]) drop(err error) {
+	if f.state.CompareAndSwap(futurePending, futureSkipped) {
+		f.stop()
+		var zero T
+		f.complete(zero, err)
+	}
+}
+
+// complete 保存任务结果并通知等待方，只会调用一次
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+	f.cancel() // 释放 ctx 占用的资源
+}
+
+// Get 阻塞等待任务结束并返回任务结果
+// ctx 取消时返回 ctx.Err()，但不会取消任务，需要取消任务时调用 Cancel。
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		// 任务同时结束时仍然返回任务结果
+		select {
+		case <-f.done:
+			return f.value, f.err
+		default:
+		}
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 返回任务结束时关闭的通道，可以在 select 中等待多个任务
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消任务，可以重复调用
+// 还未开始执行的任务不再执行，Future 立即以 context.Canceled 结束；
+// 正在执行的任务函数的 ctx 被取消，Future 在任务函数返回后结束，结果由任务函数决定。
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+// SubmitAll 将一批带返回值的任务函数提交到协程池，返回与 tasks 顺序一致的 Future
+func SubmitAll[T any](ctx context.Context, p *WorkerPool, tasks ...func(ctx context.Context) (T, error)) []*Future[T] {
+	futures := make([]*Future[T], 0, len(tasks))
+	for _, task := range tasks {
+		futures = append(futures, SubmitFuture(ctx, p, task))
+	}
+	return futures
+}
+
+// CollectAll 等待所有任务执行成功，返回与 futures 顺序一致的结果
+// 任一任务失败时取消其余任务，并立即返回最先出现的错误，不等待其余任务结束；
+// ctx 取消时返回 ctx.Err()，不会取消任务。
+func CollectAll[T any](ctx context.Context, futures ...*Future[T]) ([]T, error) {
+	stop := make(chan struct{})
+	defer close(stop)
+	finished := make(chan int, len(futures))
+	for i, f := range futures {
+		go func() {
+			select {
+			case <-f.done:
+				finished <- i
+			case <-stop:
+			}
+		}()
+	}
+
+	for range futures {
+		select {
+		case i := <-finished:
+			if err := futures[i].err; err != nil {
+				for _, f := range futures {
+					f.Cancel()
+				}
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	results := make([]T, 0, len(futures))
+	for _, f := range futures {
+		results = append(results, f.value)
+	}
+	return results, nil
+}
+
+// Result 任务的结果和错误
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// CollectSettled 等待所有任务结束，返回与 futures 顺序一致的结果和错误，任务失败时不会取消其余任务
+// ctx 取消时返回 ctx.Err()，不会取消任务。
+func CollectSettled[T any](ctx context.Context, futures ...*Future[T]) ([]Result[T], error) {
+	results := make([]Result[T], 0, len(futures))
+	for _, f := range futures {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		results = append(results, Result[T]{Value: f.value, Err: f.err})
+	}
+	return results, nil
+}
@@ -0,0 +1,302 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestSubmitFuture(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(2)
+	defer wp.StopWait()
+
+	ctx := context.Background()
+	ok := SubmitFuture(ctx, wp, func(ctx context.Context) (string, error) {
+		return "alpha", nil
+	})
+	errTask := errors.New("task failed")
+	failed := SubmitFuture(ctx, wp, func(ctx context.Context) (int, error) {
+		return 0, errTask
+	})
+
+	v, err := ok.Get(ctx)
+	if err != nil || v != "alpha" {
+		t.Fatalf("Get() = %q, %v, want alpha", v, err)
+	}
+	select {
+	case <-ok.Done():
+	default:
+		t.Fatal("Done channel should be closed after task completed")
+	}
+	if _, err = failed.Get(ctx); !errors.Is(err, errTask) {
+		t.Fatalf("Get() error = %v, want %v", err, errTask)
+	}
+}
+
+func TestFutureCancelPending(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1)
+	defer wp.StopWait()
+
+	// 占用唯一的 worker，使后续任务留在等待队列中
+	release := make(chan struct{})
+	wp.Submit(func() { <-release })
+
+	var ran atomic.Bool
+	f := SubmitFuture(context.Background(), wp, func(ctx context.Context) (int, error) {
+		ran.Store(true)
+		return 1, nil
+	})
+	f.Cancel()
+	f.Cancel()
+
+	// 不需要等待任务轮到执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := f.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get() error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	wp.StopWait()
+	if ran.Load() {
+		t.Fatal("cancelled task should not run")
+	}
+}
+
+func TestFutureCancelRunning(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1)
+	defer wp.StopWait()
+
+	started := make(chan struct{})
+	f := SubmitFuture(context.Background(), wp, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 42, ctx.Err()
+	})
+	<-started
+	f.Cancel()
+
+	v, err := f.Get(context.Background())
+	if v != 42 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get() = %d, %v, want the result returned by the task", v, err)
+	}
+}
+
+func TestFutureParentContext(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1)
+	defer wp.StopWait()
+
+	release := make(chan struct{})
+	wp.Submit(func() { <-release })
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	f := SubmitFuture(ctx, wp, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if _, err := f.Get(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFutureGetContext(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1)
+	defer wp.StopWait()
+
+	release := make(chan struct{})
+	f := SubmitFuture(context.Background(), wp, func(ctx context.Context) (int, error) {
+		<-release
+		return 1, ctx.Err()
+	})
+
+	// Get 超时不会取消任务
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if v, err := f.Get(context.Background()); v != 1 || err != nil {
+		t.Fatalf("Get() = %d, %v, want 1", v, err)
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(4)
+	defer wp.StopWait()
+
+	ctx := context.Background()
+	var tasks []func(context.Context) (string, error)
+	for i := 0; i < max; i++ {
+		tasks = append(tasks, func(ctx context.Context) (string, error) {
+			// 后提交的任务先结束，结果仍按提交顺序返回
+			time.Sleep(time.Duration(max-i) * time.Millisecond)
+			return strconv.Itoa(i), nil
+		})
+	}
+	results, err := CollectAll(ctx, SubmitAll(ctx, wp, tasks...)...)
+	if err != nil {
+		t.Fatalf("CollectAll() error = %v", err)
+	}
+	if len(results) != max {
+		t.Fatalf("got %d results, want %d", len(results), max)
+	}
+	for i, r := range results {
+		if r != strconv.Itoa(i) {
+			t.Fatalf("results[%d] = %s, want %d", i, r, i)
+		}
+	}
+
+	empty, err := CollectAll[int](ctx)
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("CollectAll() with no futures = %v, %v", empty, err)
+	}
+}
+
+func TestCollectAllFirstError(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(2)
+	defer wp.StopWait()
+
+	errTask := errors.New("task failed")
+	var ran atomic.Int32
+	blocked := func(ctx context.Context) (int, error) {
+		ran.Add(1)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	failing := func(ctx context.Context) (int, error) {
+		ran.Add(1)
+		return 0, errTask
+	}
+	// 第一个任务一直阻塞，第二个任务失败后取消第一个任务以及还在等待队列中的任务
+	futures := SubmitAll(context.Background(), wp, blocked, failing, blocked, blocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := CollectAll(ctx, futures...); !errors.Is(err, errTask) {
+		t.Fatalf("CollectAll() error = %v, want %v", err, errTask)
+	}
+	for i, f := range futures {
+		if _, err := f.Get(ctx); i != 1 && !errors.Is(err, context.Canceled) {
+			t.Fatalf("future %d error = %v, want context.Canceled", i, err)
+		}
+	}
+	wp.StopWait()
+	if n := ran.Load(); n > 3 {
+		t.Fatalf("%d tasks ran, cancelled waiting tasks should not run", n)
+	}
+}
+
+func TestCollectSettled(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(2)
+	defer wp.StopWait()
+
+	ctx := context.Background()
+	errTask := errors.New("task failed")
+	futures := SubmitAll(ctx, wp,
+		func(ctx context.Context) (int, error) { return 1, nil },
+		func(ctx context.Context) (int, error) { return 0, errTask },
+		func(ctx context.Context) (int, error) { time.Sleep(10 * time.Millisecond); return 3, ctx.Err() },
+	)
+	results, err := CollectSettled(ctx, futures...)
+	if err != nil {
+		t.Fatalf("CollectSettled() error = %v", err)
+	}
+	want := []Result[int]{{Value: 1}, {Err: errTask}, {Value: 3}}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i].Value != want[i].Value || !errors.Is(results[i].Err, want[i].Err) {
+			t.Fatalf("results[%d] = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	// ctx 取消时不再等待
+	release := make(chan struct{})
+	defer close(release)
+	pending := SubmitFuture(context.Background(), wp, func(ctx context.Context) (int, error) {
+		<-release
+		return 0, nil
+	})
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := CollectSettled(timeout, pending); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CollectSettled() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFutureRejected(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		policy  OverflowPolicy
+		wantErr error
+	}{
+		{policy: OverflowFailFast, wantErr: ErrQueueFull},
+		{policy: OverflowDropNewest, wantErr: ErrDropped},
+		{policy: OverflowDropOldest, wantErr: ErrDropped},
+	} {
+		wp := New(1, WithQueueCapacity(1, tt.policy))
+		release := occupyWorkers(t, wp, 1)
+
+		first := SubmitFuture(ctx, wp, func(ctx context.Context) (int, error) { return 1, nil })
+		second := SubmitFuture(ctx, wp, func(ctx context.Context) (int, error) { return 2, nil })
+		// OverflowDropOldest 丢弃先提交的任务，其他策略拒绝新任务
+		rejected, accepted, want := second, first, 1
+		if tt.policy == OverflowDropOldest {
+			rejected, accepted, want = first, second, 2
+		}
+		if _, err := rejected.Get(ctx); !errors.Is(err, tt.wantErr) {
+			t.Fatalf("policy %d: Get() error = %v, want %v", tt.policy, err, tt.wantErr)
+		}
+
+		close(release)
+		if v, err := accepted.Get(ctx); v != want || err != nil {
+			t.Fatalf("policy %d: Get() = %d, %v, want %d", tt.policy, v, err, want)
+		}
+		wp.StopWait()
+	}
+}
+
+func TestFutureStop(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1)
+	release := occupyWorkers(t, wp, 1)
+	f := SubmitFuture(context.Background(), wp, func(ctx context.Context) (int, error) { return 1, nil })
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		close(release)
+	}()
+	// Stop 丢弃等待队列中的任务
+	wp.Stop()
+	if _, err := f.Get(context.Background()); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Get() error = %v, want ErrStopped", err)
+	}
+}
@@ -2,6 +2,7 @@ package workerpool
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,10 +15,57 @@ const (
 	idleTimeout = 2 * time.Second
 )
 
+var (
+	// ErrQueueFull 等待队列已满，按照 OverflowFailFast 策略拒绝任务
+	ErrQueueFull = errors.New("workerpool: waiting queue is full")
+	// ErrDropped 等待队列已满，按照 OverflowDropNewest 或 OverflowDropOldest 策略丢弃任务
+	ErrDropped = errors.New("workerpool: task dropped because waiting queue is full")
+	// ErrStopped 协程池已经停止，任务不会执行
+	ErrStopped = errors.New("workerpool: worker pool stopped")
+)
+
+// OverflowPolicy 等待队列已满时新提交任务的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞提交任务的调用方，直到等待队列中有空位
+	OverflowBlock OverflowPolicy = iota
+	// OverflowFailFast 拒绝新任务，TrySubmit 返回 ErrQueueFull
+	OverflowFailFast
+	// OverflowDropNewest 丢弃新任务，TrySubmit 返回 ErrDropped
+	OverflowDropNewest
+	// OverflowDropOldest 丢弃等待队列中最早提交的任务，新任务进入队尾
+	OverflowDropOldest
+	// OverflowCallerRuns 在提交任务的调用方协程中直接执行新任务，执行完成后才返回
+	OverflowCallerRuns
+)
+
+// Option 创建协程池时的可选配置
+type Option func(*WorkerPool)
+
+// WithQueueCapacity 限制等待队列的容量，即已经提交但还未开始执行的任务数量，
+// 超出容量时按照 policy 处理新提交的任务，capacity 小于 1 时不限制容量。
+//
+// SubmitWait 和 Pause 提交的任务不受容量限制，也不会被丢弃。
+func WithQueueCapacity(capacity int, policy OverflowPolicy) Option {
+	return func(p *WorkerPool) {
+		if capacity < 1 {
+			return
+		}
+		p.capacity = capacity
+		p.policy = policy
+		// 除 OverflowDropOldest 在调度器中处理外，其他策略需要在提交时判断队列是否已满
+		if policy != OverflowDropOldest {
+			p.slots = make(chan struct{}, capacity)
+		}
+	}
+}
+
 // New 创建并启动协程池
 // maxWorkers 参数指定可以并发执行任务的最大工作协程数。
 // 当没有任务需要执行时，工作协程（worker）会逐渐停止，直至没有剩余的 worker。
-func New(maxWorkers int) *WorkerPool {
+// 默认不限制等待队列的容量，可以通过 WithQueueCapacity 限制。
+func New(maxWorkers int, opts ...Option) *WorkerPool {
 	// 至少有一个 worker
 	if maxWorkers < 1 {
 		maxWorkers = 1
@@ -26,11 +74,14 @@ func New(maxWorkers int) *WorkerPool {
 	// 实例化协程池对象
 	pool := &WorkerPool{
 		maxWorkers:  maxWorkers,
-		taskQueue:   make(chan func()),
+		taskQueue:   make(chan task),
 		workerQueue: make(chan func()),
 		stopSignal:  make(chan struct{}),
 		stoppedChan: make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(pool)
+	}
 
 	// 启动任务调度器
 	go pool.dispatch()
@@ -40,17 +91,28 @@ func New(maxWorkers int) *WorkerPool {
 
 // WorkerPool 是 Go 协程的集合池，用于确保同时处理请求的协程数量严格受控于预设的上限值
 type WorkerPool struct {
-	maxWorkers   int                 // 最大工作协程数
-	taskQueue    chan func()         // 任务提交队列
-	workerQueue  chan func()         // 工作协程消费队列
-	stoppedChan  chan struct{}       // 停止完成通知通道
-	stopSignal   chan struct{}       // 停止信号通道
-	waitingQueue deque.Deque[func()] // 等待队列（双端队列）
-	stopLock     sync.Mutex          // 停止操作互斥锁
-	stopOnce     sync.Once           // 控制只停止一次
-	stopped      bool                // 是否已经停止
-	waiting      int32               // 等待队列中任务计数
-	wait         bool                // 协程池退出时是否等待已入队任务执行完成
+	maxWorkers   int               // 最大工作协程数
+	taskQueue    chan task         // 任务提交队列
+	workerQueue  chan func()       // 工作协程消费队列
+	stoppedChan  chan struct{}     // 停止完成通知通道
+	stopSignal   chan struct{}     // 停止信号通道
+	waitingQueue deque.Deque[task] // 等待队列（双端队列）
+	stopLock     sync.Mutex        // 停止操作互斥锁
+	stopOnce     sync.Once         // 控制只停止一次
+	stopped      bool              // 是否已经停止
+	waiting      int32             // 等待队列中任务计数
+	wait         bool              // 协程池退出时是否等待已入队任务执行完成
+	capacity     int               // 等待队列容量，为 0 时不限制
+	policy       OverflowPolicy    // 等待队列已满时的处理策略
+	slots        chan struct{}     // 已提交还未开始执行的任务占用的位置，只在需要提交时判断队列是否已满的策略中使用
+	rejected     atomic.Uint64     // 因等待队列已满被拒绝、丢弃或在调用方执行的任务计数
+}
+
+// task 提交到协程池中的任务
+type task struct {
+	run     func()
+	drop    func(err error) // 任务不再执行时调用，可以为 nil
+	bounded bool            // 是否受等待队列容量限制
 }
 
 // Size 返回协程池大小
@@ -85,9 +147,11 @@ func (p *WorkerPool) Stopped() bool {
 // Submit 将任务函数提交到工作池队列等待执行，不会等待任务执行完成
 //
 // 任务函数所需的外部变量必须通过闭包捕获。
-// 需要返回值的任务应当通过闭包内的通道（channel）传递结果。
+// 需要返回值的任务应当通过闭包内的通道（channel）传递结果，或者使用 SubmitFuture 获取结果和错误。
 //
-// 无论提交多少个任务，Submit 都不会阻塞调用方。
+// 没有通过 WithQueueCapacity 限制等待队列容量时，无论提交多少个任务，Submit 都不会阻塞调用方。
+// 限制容量时，等待队列已满后按照 OverflowPolicy 处理新任务，被拒绝或丢弃的任务不会执行，
+// 需要感知任务是否被拒绝时使用 TrySubmit。
 // 任务会立即分配给可用的 worker 或启动新的 worker 执行任务。
 // 如果达到最大 worker 数量限制，没有可用的 worker，则任务将放入等待队列（waitingQueue）中。
 //
@@ -98,24 +162,69 @@ func (p *WorkerPool) Stopped() bool {
 // 空闲工作协程，直至所有空闲协程都被回收。基于 Go 协程的轻量级特性，
 // 新建协程的耗时开销可忽略不计，因此无需长期维持空闲协程池。
 func (p *WorkerPool) Submit(task func()) {
-	if task != nil {
-		p.taskQueue <- task
+	_ = p.TrySubmit(task)
+}
+
+// TrySubmit 与 Submit 相同，任务因等待队列已满没有进入协程池时返回错误：
+// OverflowFailFast 策略返回 ErrQueueFull，OverflowDropNewest 策略返回 ErrDropped，
+// OverflowBlock 策略阻塞期间协程池停止时返回 ErrStopped。
+// OverflowDropOldest 策略丢弃的是等待队列中的其他任务，新任务总是能够提交成功。
+func (p *WorkerPool) TrySubmit(fn func()) error {
+	if fn == nil {
+		return nil
 	}
+	return p.submit(task{run: fn, bounded: p.capacity > 0})
 }
 
 // SubmitWait 提交任务函数到队列，并阻塞等待任务执行完成
-func (p *WorkerPool) SubmitWait(task func()) {
-	if task == nil {
+// 调用方会阻塞等待，任务不受等待队列容量限制。
+func (p *WorkerPool) SubmitWait(fn func()) {
+	if fn == nil {
 		return
 	}
 	doneChan := make(chan struct{})
-	p.taskQueue <- func() { // 提交任务
-		task()
+	_ = p.submit(task{run: func() { // 提交任务
+		fn()
 		close(doneChan)
-	}
+	}})
 	<-doneChan // 阻塞等待任务执行完成
 }
 
+// Rejected 返回因等待队列已满被拒绝、丢弃或在调用方协程中执行的任务数量
+func (p *WorkerPool) Rejected() uint64 {
+	return p.rejected.Load()
+}
+
+// submit 将任务发送给调度器，受容量限制的任务在等待队列已满时按照策略处理
+func (p *WorkerPool) submit(t task) error {
+	if t.bounded && p.slots != nil {
+		select {
+		case p.slots <- struct{}{}: // 占用一个位置，任务交给 worker 时释放
+		default:
+			switch p.policy {
+			case OverflowBlock:
+				select {
+				case p.slots <- struct{}{}:
+				case <-p.stopSignal:
+					return ErrStopped
+				}
+			case OverflowCallerRuns:
+				p.rejected.Add(1)
+				t.run()
+				return nil
+			case OverflowDropNewest:
+				p.rejected.Add(1)
+				return ErrDropped
+			default:
+				p.rejected.Add(1)
+				return ErrQueueFull
+			}
+		}
+	}
+	p.taskQueue <- t
+	return nil
+}
+
 // WaitingQueueSize 返回等待队列中的任务计数
 func (p *WorkerPool) WaitingQueueSize() int {
 	return int(atomic.LoadInt32(&p.waiting))
@@ -138,13 +247,14 @@ func (p *WorkerPool) Pause(ctx context.Context) {
 	ready := new(sync.WaitGroup)
 	ready.Add(p.maxWorkers) // 设置与最大 worker 数匹配的计数器
 	for i := 0; i < p.maxWorkers; i++ {
-		p.Submit(func() { // 向每个 worker 发送暂停指令
+		// 暂停指令不受等待队列容量限制，避免被拒绝或在调用方协程中执行
+		_ = p.submit(task{run: func() { // 向每个 worker 发送暂停指令
 			ready.Done() // 标记暂停指令发送完成
 			select {
 			case <-ctx.Done(): // 调用方通过 ctx 取消暂停
 			case <-p.stopSignal: // 协程池内部通过接收停止信号取消暂停
 			}
-		})
+		}})
 	}
 	// 阻塞等待所有 worker 都进入暂停状态
 	ready.Wait()
@@ -177,21 +287,22 @@ Loop:
 
 		// 直通模式：开始处理提交上来的新任务
 		select {
-		case task, ok := <-p.taskQueue: // 接收到新任务
+		case t, ok := <-p.taskQueue: // 接收到新任务
 			if !ok { // 协程池停止时会关闭任务通道，如果 !ok 说明协程池已停止，退出循环
 				break Loop
 			}
 
 			select {
-			case p.workerQueue <- task: // 尝试派发任务
+			case p.workerQueue <- t.run: // 尝试派发任务
+				p.release(t)
 			default: // 没有空闲的 worker，无法立即派发任务
 				if workerCount < p.maxWorkers { // 如果协程池中的活跃协程数量小于最大值，那么创建一个新的协程（worker）来执行任务
 					wg.Add(1)
-					go worker(task, p.workerQueue, &wg) // 创建新的 worker 执行任务
-					workerCount++                       // worker 记数加 1
+					go worker(t.run, p.workerQueue, &wg) // 创建新的 worker 执行任务
+					workerCount++                        // worker 记数加 1
+					p.release(t)
 				} else { // 已达协程池容量上限
-					p.waitingQueue.PushBack(task)                              // 将任务提交到等待队列
-					atomic.StoreInt32(&p.waiting, int32(p.waitingQueue.Len())) // 原子更新等待计数
+					p.enqueue(t) // 将任务提交到等待队列
 				}
 			}
 			idle = false // 标记为非空闲
@@ -209,6 +320,8 @@ Loop:
 
 	if p.wait { // 调用了 StopWait() 方法，需要运行等待队列中的任务，直至队列清空
 		p.runQueuedTasks()
+	} else { // 调用了 Stop() 方法，丢弃等待队列中的任务
+		p.dropQueuedTasks()
 	}
 
 	// 终止所有 worker
@@ -252,18 +365,41 @@ func (p *WorkerPool) stop(wait bool) {
 // 如果 worker pool 已停止，则返回 false。
 func (p *WorkerPool) processWaitingQueue() bool {
 	select {
-	case task, ok := <-p.taskQueue: // 接收到新任务
+	case t, ok := <-p.taskQueue: // 接收到新任务
 		if !ok { // 协程池停止时会关闭任务通道，如果 !ok 说明协程池已停止，返回 false，不再继续处理
 			return false
 		}
-		p.waitingQueue.PushBack(task) // 将新任务加入等待队列队尾
-	case p.workerQueue <- p.waitingQueue.Front(): // 从等待队列队头获取任务并放入工作队列
-		p.waitingQueue.PopFront() // 任务已经开始处理，所以要从等待队列中移除任务
+		p.enqueue(t) // 将新任务加入等待队列队尾
+	case p.workerQueue <- p.waitingQueue.Front().run: // 从等待队列队头获取任务并放入工作队列
+		p.release(p.waitingQueue.PopFront()) // 任务已经开始处理，所以要从等待队列中移除任务
 	}
 	atomic.StoreInt32(&p.waiting, int32(p.waitingQueue.Len())) // 原子修改等待队列中任务计数
 	return true
 }
 
+// 将任务加入等待队列队尾
+// OverflowDropOldest 策略下等待队列已满时，先丢弃队列中最早提交的受容量限制的任务。
+func (p *WorkerPool) enqueue(t task) {
+	if t.bounded && p.policy == OverflowDropOldest && p.waitingQueue.Len() >= p.capacity {
+		if i := p.waitingQueue.Index(func(t task) bool { return t.bounded }); i >= 0 {
+			dropped := p.waitingQueue.Remove(i)
+			p.rejected.Add(1)
+			if dropped.drop != nil {
+				dropped.drop(ErrDropped)
+			}
+		}
+	}
+	p.waitingQueue.PushBack(t)
+	atomic.StoreInt32(&p.waiting, int32(p.waitingQueue.Len())) // 原子更新等待计数
+}
+
+// 任务已经交给 worker，释放任务占用的等待队列位置
+func (p *WorkerPool) release(t task) {
+	if t.bounded && p.slots != nil {
+		<-p.slots
+	}
+}
+
 // 停止一个空闲 worker
 func (p *WorkerPool) killIdleWorker() bool {
 	select {
@@ -280,7 +416,18 @@ func (p *WorkerPool) killIdleWorker() bool {
 func (p *WorkerPool) runQueuedTasks() {
 	for p.waitingQueue.Len() != 0 { // 直至队列清空终止循环
 		// 从等待队列中获取队首任务并交给工作队列去执行
-		p.workerQueue <- p.waitingQueue.PopFront()
+		t := p.waitingQueue.PopFront()
+		p.workerQueue <- t.run
+		p.release(t)
 		atomic.StoreInt32(&p.waiting, int32(p.waitingQueue.Len())) // 原子修改等待任务计数
 	}
 }
+
+// 通知等待队列中的任务不再执行，任务保留在队列中
+func (p *WorkerPool) dropQueuedTasks() {
+	for i := 0; i < p.waitingQueue.Len(); i++ {
+		if t := p.waitingQueue.At(i); t.drop != nil {
+			t.drop(ErrStopped)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package workerpool
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -712,3 +714,196 @@ func benchmarkExecWorkers(n int, b *testing.B) {
 	}
 	allDone.Wait()
 }
+
+// occupyWorkers 提交 n 个阻塞的任务并等待它们开始执行，关闭返回的通道后任务结束
+func occupyWorkers(t *testing.T, wp *WorkerPool, n int) chan struct{} {
+	t.Helper()
+	started := make(chan struct{}, n)
+	release := make(chan struct{})
+	for i := 0; i < n; i++ {
+		if err := wp.TrySubmit(func() {
+			started <- struct{}{}
+			<-release
+		}); err != nil {
+			t.Fatal("failed to submit task:", err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+	return release
+}
+
+func TestQueueCapacityBlock(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1, WithQueueCapacity(2, OverflowBlock))
+	defer wp.StopWait()
+	release := occupyWorkers(t, wp, 1)
+
+	var ran int32
+	for i := 0; i < 2; i++ {
+		wp.Submit(func() { atomic.AddInt32(&ran, 1) })
+	}
+	submitted := make(chan error)
+	go func() {
+		submitted <- wp.TrySubmit(func() { atomic.AddInt32(&ran, 1) })
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("submit should block while the waiting queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := wp.WaitingQueueSize(); n > 2 {
+		t.Fatal("waiting queue exceeds its capacity:", n)
+	}
+
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatal("blocked submit failed:", err)
+	}
+	wp.StopWait()
+	if atomic.LoadInt32(&ran) != 3 || wp.Rejected() != 0 {
+		t.Fatalf("ran %d tasks, rejected %d, want 3 and 0", ran, wp.Rejected())
+	}
+}
+
+func TestQueueCapacityBlockStop(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1, WithQueueCapacity(1, OverflowBlock))
+	release := occupyWorkers(t, wp, 1)
+	wp.Submit(func() {})
+
+	submitted := make(chan error)
+	go func() {
+		submitted <- wp.TrySubmit(func() {})
+	}()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		close(release)
+	}()
+	wp.Stop()
+	if err := <-submitted; !errors.Is(err, ErrStopped) {
+		t.Fatalf("TrySubmit() error = %v, want ErrStopped", err)
+	}
+}
+
+func TestQueueCapacityReject(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	for _, tt := range []struct {
+		policy  OverflowPolicy
+		wantErr error
+	}{
+		{policy: OverflowFailFast, wantErr: ErrQueueFull},
+		{policy: OverflowDropNewest, wantErr: ErrDropped},
+	} {
+		wp := New(1, WithQueueCapacity(2, tt.policy))
+		release := occupyWorkers(t, wp, 1)
+
+		var ran []int
+		var mutex sync.Mutex
+		for i := 0; i < 5; i++ {
+			err := wp.TrySubmit(func() {
+				mutex.Lock()
+				ran = append(ran, i)
+				mutex.Unlock()
+			})
+			if i < 2 && err != nil {
+				t.Fatalf("policy %d: task %d should be queued, got %v", tt.policy, i, err)
+			}
+			if i >= 2 && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("policy %d: TrySubmit() error = %v, want %v", tt.policy, err, tt.wantErr)
+			}
+		}
+		// Submit 同样拒绝任务，但不返回错误
+		wp.Submit(func() { t.Error("rejected task should not run") })
+
+		close(release)
+		wp.StopWait()
+		if len(ran) != 2 || ran[0] != 0 || ran[1] != 1 {
+			t.Fatalf("policy %d: ran tasks %v, want [0 1]", tt.policy, ran)
+		}
+		if n := wp.Rejected(); n != 4 {
+			t.Fatalf("policy %d: rejected %d tasks, want 4", tt.policy, n)
+		}
+	}
+}
+
+func TestQueueCapacityDropOldest(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1, WithQueueCapacity(2, OverflowDropOldest))
+	release := occupyWorkers(t, wp, 1)
+
+	var ran []int
+	for i := 0; i < 5; i++ {
+		if err := wp.TrySubmit(func() { ran = append(ran, i) }); err != nil {
+			t.Fatal("TrySubmit() error:", err)
+		}
+	}
+	if n := wp.WaitingQueueSize(); n != 2 {
+		t.Fatal("expected 2 tasks in waiting queue, have", n)
+	}
+
+	close(release)
+	wp.StopWait()
+	if len(ran) != 2 || ran[0] != 3 || ran[1] != 4 {
+		t.Fatalf("ran tasks %v, want the newest tasks [3 4]", ran)
+	}
+	if n := wp.Rejected(); n != 3 {
+		t.Fatalf("rejected %d tasks, want 3", n)
+	}
+}
+
+func TestQueueCapacityCallerRuns(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(1, WithQueueCapacity(1, OverflowCallerRuns))
+	defer wp.StopWait()
+	release := occupyWorkers(t, wp, 1)
+	defer close(release)
+
+	wp.Submit(func() {})
+	ran := false
+	if err := wp.TrySubmit(func() { ran = true }); err != nil {
+		t.Fatal("TrySubmit() error:", err)
+	}
+	// 在调用方协程中执行，返回时已经执行完成
+	if !ran {
+		t.Fatal("task should run in the caller goroutine when the waiting queue is full")
+	}
+	if n := wp.Rejected(); n != 1 {
+		t.Fatalf("rejected %d tasks, want 1", n)
+	}
+}
+
+func TestQueueCapacityPause(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	wp := New(2, WithQueueCapacity(1, OverflowFailFast))
+	defer wp.StopWait()
+
+	// 暂停指令不受容量限制
+	ctx, cancel := context.WithCancel(context.Background())
+	wp.Pause(ctx)
+
+	ran := make(chan struct{})
+	if err := wp.TrySubmit(func() { close(ran) }); err != nil {
+		t.Fatal("TrySubmit() error:", err)
+	}
+	if err := wp.TrySubmit(func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("TrySubmit() error = %v, want ErrQueueFull", err)
+	}
+	// SubmitWait 不受容量限制
+	done := make(chan struct{})
+	go func() {
+		wp.SubmitWait(func() {})
+		close(done)
+	}()
+
+	cancel()
+	<-ran
+	<-done
+}
@@ -0,0 +1,9 @@
+// Package assets 部署 nightwatch 使用的建表语句和配置文件
+package assets
+
+import _ "embed"
+
+// Schema MySQL 建表语句，SQLite 存储启动时转换后自动建表
+//
+//go:embed schema.sql
+var Schema string
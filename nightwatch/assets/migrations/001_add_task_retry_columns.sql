@@ -0,0 +1,8 @@
+-- 为已有部署的 task 表添加任务重试相关字段
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `max_attempts` int(11) NOT NULL DEFAULT '1' COMMENT '最大执行次数，包含首次执行' AFTER `user_id`,
+  ADD COLUMN IF NOT EXISTS `backoff_strategy` varchar(45) NOT NULL DEFAULT 'Fixed' COMMENT '重试退避策略：Fixed/Exponential' AFTER `max_attempts`,
+  ADD COLUMN IF NOT EXISTS `backoff_seconds` int(11) NOT NULL DEFAULT '0' COMMENT '重试退避基础时长（秒）' AFTER `backoff_strategy`,
+  ADD COLUMN IF NOT EXISTS `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '当前执行次数' AFTER `backoff_seconds`,
+  ADD COLUMN IF NOT EXISTS `retry_at` datetime DEFAULT NULL COMMENT '下次重试时间' AFTER `attempt`,
+  ADD COLUMN IF NOT EXISTS `attempts` TEXT COMMENT '每次执行的结果' AFTER `retry_at`;
@@ -0,0 +1,3 @@
+-- 为已有部署的 task 表添加任务执行器字段
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `executor` varchar(45) NOT NULL DEFAULT 'kubernetes' COMMENT '任务执行器：kubernetes/local' AFTER `user_id`;
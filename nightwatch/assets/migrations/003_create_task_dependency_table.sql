@@ -0,0 +1,10 @@
+-- 为已有部署添加任务依赖关系表
+CREATE TABLE IF NOT EXISTS `task_dependency` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '下游任务 ID',
+  `depends_on_id` bigint(20) NOT NULL COMMENT '上游任务 ID',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_task_id_depends_on_id` (`task_id`, `depends_on_id`),
+  KEY `idx_depends_on_id` (`depends_on_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务依赖关系表';
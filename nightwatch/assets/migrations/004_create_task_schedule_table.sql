@@ -0,0 +1,21 @@
+-- 为已有部署添加定时任务表，并记录任务由哪个定时任务创建
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `schedule_id` bigint(20) DEFAULT NULL COMMENT '创建任务的定时任务 ID' AFTER `attempts`,
+  ADD INDEX IF NOT EXISTS `idx_schedule_id` (`schedule_id`);
+
+CREATE TABLE IF NOT EXISTS `task_schedule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(52) NOT NULL DEFAULT '' COMMENT '定时任务名称',
+  `namespace` varchar(45) NOT NULL DEFAULT '' COMMENT 'k8s namespace 名称',
+  `user_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '用户 ID',
+  `spec` varchar(255) NOT NULL DEFAULT '' COMMENT 'cron 表达式，支持秒级',
+  `template` TEXT NOT NULL COMMENT '创建任务使用的模板',
+  `concurrency_policy` varchar(45) NOT NULL DEFAULT 'Allow' COMMENT '并发策略：Allow/Forbid/Replace',
+  `catch_up_limit` int(11) NOT NULL DEFAULT '0' COMMENT '错过执行时间后最多补偿执行的次数',
+  `suspend` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否暂停',
+  `last_schedule_at` datetime DEFAULT NULL COMMENT '最近一次执行时间',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_name_namespace` (`name`, `namespace`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='定时任务表';
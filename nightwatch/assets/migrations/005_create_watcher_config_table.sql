@@ -0,0 +1,11 @@
+-- 为已有部署添加 Watcher 配置表，没有配置的 Watcher 保持默认启用
+CREATE TABLE IF NOT EXISTS `watcher_config` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL COMMENT 'Watcher 名称',
+  `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用',
+  `spec` varchar(255) NOT NULL DEFAULT '' COMMENT '覆盖默认定时周期的 cron 表达式，为空时使用默认值',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Watcher 配置表';
@@ -0,0 +1,7 @@
+-- 为已有部署添加 fencing token 表，获取锁的实例递增 token，旧 leader 的写入会被拒绝
+CREATE TABLE IF NOT EXISTS `fencing_token` (
+  `name` varchar(64) NOT NULL COMMENT '分布式锁名称',
+  `token` bigint(20) NOT NULL DEFAULT '0' COMMENT '最新一任 leader 的 fencing token',
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='leader fencing token 表';
@@ -0,0 +1,3 @@
+-- 为已有部署的 task 表添加乐观锁版本号字段
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `resource_version` bigint(20) NOT NULL DEFAULT '0' COMMENT '乐观锁版本号，每次更新递增' AFTER `schedule_id`;
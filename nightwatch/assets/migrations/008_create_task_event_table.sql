@@ -0,0 +1,14 @@
+-- 为已有部署添加任务状态变化记录表
+CREATE TABLE IF NOT EXISTS `task_event` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '状态变化时的执行次数',
+  `from_status` varchar(45) NOT NULL DEFAULT '' COMMENT '变化前的状态',
+  `to_status` varchar(45) NOT NULL DEFAULT '' COMMENT '变化后的状态',
+  `reason` varchar(45) NOT NULL DEFAULT '' COMMENT '变化原因',
+  `message` TEXT COMMENT '详细信息',
+  `conditions` TEXT COMMENT '执行实例的状况，如 Job Conditions',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  KEY `idx_task_id` (`task_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务状态变化记录表';
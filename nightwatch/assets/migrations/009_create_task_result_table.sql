@@ -0,0 +1,15 @@
+-- 为已有部署添加任务执行结果表
+CREATE TABLE IF NOT EXISTS `task_result` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '执行次数',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '执行实例名称，如 Pod 名称',
+  `reason` varchar(255) NOT NULL DEFAULT '' COMMENT '退出原因',
+  `exit_code` int(11) DEFAULT NULL COMMENT '退出码',
+  `message` TEXT COMMENT '退出信息',
+  `logs` MEDIUMTEXT COMMENT '最后的日志',
+  `logs_truncated` tinyint(1) NOT NULL DEFAULT '0' COMMENT '日志是否被截断',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_task_id_attempt` (`task_id`, `attempt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务执行结果表';
@@ -0,0 +1,3 @@
+-- 为已有部署的 task 表添加优先级字段
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `priority` int(11) NOT NULL DEFAULT '0' COMMENT '优先级，值越大越先启动' AFTER `user_id`;
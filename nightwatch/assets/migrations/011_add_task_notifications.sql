@@ -0,0 +1,21 @@
+-- 为已有部署的 task 表添加通知目标字段，并添加任务通知表
+ALTER TABLE `task`
+  ADD COLUMN IF NOT EXISTS `notifications` TEXT COMMENT '任务结束时的通知目标' AFTER `schedule_id`;
+
+CREATE TABLE IF NOT EXISTS `task_notification` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `event_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '触发通知的状态变化记录 ID',
+  `target` TEXT NOT NULL COMMENT '通知目标',
+  `payload` TEXT NOT NULL COMMENT '通知内容',
+  `status` varchar(45) NOT NULL DEFAULT 'Pending' COMMENT '发送状态：Pending/Sent/Failed',
+  `attempts` int(11) NOT NULL DEFAULT '0' COMMENT '已经发送的次数',
+  `next_attempt_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次发送时间',
+  `last_error` TEXT COMMENT '最近一次发送失败的原因',
+  `sent_at` datetime DEFAULT NULL COMMENT '发送成功的时间',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_task_id` (`task_id`),
+  KEY `idx_status_next_attempt_at` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务通知表';
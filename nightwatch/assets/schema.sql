@@ -9,12 +9,116 @@ CREATE TABLE IF NOT EXISTS `task` (
   `info` TEXT NOT NULL COMMENT '任务 k8s 相关信息',
   `status` varchar(45) NOT NULL DEFAULT '' COMMENT '任务状态',
   `user_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '用户 ID',
+  `priority` int(11) NOT NULL DEFAULT '0' COMMENT '优先级，值越大越先启动',
+  `executor` varchar(45) NOT NULL DEFAULT 'kubernetes' COMMENT '任务执行器：kubernetes/local',
+  `max_attempts` int(11) NOT NULL DEFAULT '1' COMMENT '最大执行次数，包含首次执行',
+  `backoff_strategy` varchar(45) NOT NULL DEFAULT 'Fixed' COMMENT '重试退避策略：Fixed/Exponential',
+  `backoff_seconds` int(11) NOT NULL DEFAULT '0' COMMENT '重试退避基础时长（秒）',
+  `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '当前执行次数',
+  `retry_at` datetime DEFAULT NULL COMMENT '下次重试时间',
+  `attempts` TEXT COMMENT '每次执行的结果',
+  `schedule_id` bigint(20) DEFAULT NULL COMMENT '创建任务的定时任务 ID',
+  `notifications` TEXT COMMENT '任务结束时的通知目标',
+  `resource_version` bigint(20) NOT NULL DEFAULT '0' COMMENT '乐观锁版本号，每次更新递增',
   `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
-  UNIQUE KEY `uk_name_namespace` (`name`, `namespace`)
+  UNIQUE KEY `uk_name_namespace` (`name`, `namespace`),
+  KEY `idx_schedule_id` (`schedule_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务表';
 
+CREATE TABLE IF NOT EXISTS `task_dependency` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '下游任务 ID',
+  `depends_on_id` bigint(20) NOT NULL COMMENT '上游任务 ID',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_task_id_depends_on_id` (`task_id`, `depends_on_id`),
+  KEY `idx_depends_on_id` (`depends_on_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务依赖关系表';
+
+CREATE TABLE IF NOT EXISTS `task_event` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '状态变化时的执行次数',
+  `from_status` varchar(45) NOT NULL DEFAULT '' COMMENT '变化前的状态',
+  `to_status` varchar(45) NOT NULL DEFAULT '' COMMENT '变化后的状态',
+  `reason` varchar(45) NOT NULL DEFAULT '' COMMENT '变化原因',
+  `message` TEXT COMMENT '详细信息',
+  `conditions` TEXT COMMENT '执行实例的状况，如 Job Conditions',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  KEY `idx_task_id` (`task_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务状态变化记录表';
+
+CREATE TABLE IF NOT EXISTS `task_result` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `attempt` int(11) NOT NULL DEFAULT '0' COMMENT '执行次数',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '执行实例名称，如 Pod 名称',
+  `reason` varchar(255) NOT NULL DEFAULT '' COMMENT '退出原因',
+  `exit_code` int(11) DEFAULT NULL COMMENT '退出码',
+  `message` TEXT COMMENT '退出信息',
+  `logs` MEDIUMTEXT COMMENT '最后的日志',
+  `logs_truncated` tinyint(1) NOT NULL DEFAULT '0' COMMENT '日志是否被截断',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_task_id_attempt` (`task_id`, `attempt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务执行结果表';
+
+CREATE TABLE IF NOT EXISTS `task_notification` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `task_id` bigint(20) NOT NULL COMMENT '任务 ID',
+  `event_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '触发通知的状态变化记录 ID',
+  `target` TEXT NOT NULL COMMENT '通知目标',
+  `payload` TEXT NOT NULL COMMENT '通知内容',
+  `status` varchar(45) NOT NULL DEFAULT 'Pending' COMMENT '发送状态：Pending/Sent/Failed',
+  `attempts` int(11) NOT NULL DEFAULT '0' COMMENT '已经发送的次数',
+  `next_attempt_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次发送时间',
+  `last_error` TEXT COMMENT '最近一次发送失败的原因',
+  `sent_at` datetime DEFAULT NULL COMMENT '发送成功的时间',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_task_id` (`task_id`),
+  KEY `idx_status_next_attempt_at` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='任务通知表';
+
+CREATE TABLE IF NOT EXISTS `task_schedule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(52) NOT NULL DEFAULT '' COMMENT '定时任务名称',
+  `namespace` varchar(45) NOT NULL DEFAULT '' COMMENT 'k8s namespace 名称',
+  `user_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '用户 ID',
+  `spec` varchar(255) NOT NULL DEFAULT '' COMMENT 'cron 表达式，支持秒级',
+  `template` TEXT NOT NULL COMMENT '创建任务使用的模板',
+  `concurrency_policy` varchar(45) NOT NULL DEFAULT 'Allow' COMMENT '并发策略：Allow/Forbid/Replace',
+  `catch_up_limit` int(11) NOT NULL DEFAULT '0' COMMENT '错过执行时间后最多补偿执行的次数',
+  `suspend` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否暂停',
+  `last_schedule_at` datetime DEFAULT NULL COMMENT '最近一次执行时间',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_name_namespace` (`name`, `namespace`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='定时任务表';
+
+CREATE TABLE IF NOT EXISTS `watcher_config` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL COMMENT 'Watcher 名称',
+  `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用',
+  `spec` varchar(255) NOT NULL DEFAULT '' COMMENT '覆盖默认定时周期的 cron 表达式，为空时使用默认值',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Watcher 配置表';
+
+CREATE TABLE IF NOT EXISTS `fencing_token` (
+  `name` varchar(64) NOT NULL COMMENT '分布式锁名称',
+  `token` bigint(20) NOT NULL DEFAULT '0' COMMENT '最新一任 leader 的 fencing token',
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='leader fencing token 表';
+
 -- test data
 INSERT INTO `task` (`id`, `name`, `namespace`, `info`, `status`, `user_id`) VALUES (1, 'demo-task-1', 'default', '{"image":"alpine","command":["sleep"],"args":["60"]}', 'Normal', 1);
 INSERT INTO `task` (`id`, `name`, `namespace`, `info`, `status`, `user_id`) VALUES (2, 'demo-task-2', 'demo', '{"image":"busybox","command":["sleep"],"args":["3600"]}', 'Normal', 2);
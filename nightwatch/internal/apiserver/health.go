@@ -0,0 +1,73 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 单项健康检查的超时时间
+const healthCheckTimeout = 3 * time.Second
+
+// HealthCheck 检查依赖的外部服务是否可用
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthResponse 健康检查响应
+type HealthResponse struct {
+	Status string            `json:"status"` // 所有检查都通过时为 ok，否则为 failed
+	Checks map[string]string `json:"checks"` // 每项检查的结果，通过时为 ok，否则为错误信息
+}
+
+// runChecks 并发执行所有健康检查，返回检查结果以及是否全部通过
+func (s *Server) runChecks(ctx context.Context) (*HealthResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		ok = true
+	)
+	resp := &HealthResponse{Status: "ok", Checks: make(map[string]string, len(s.checks))}
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := "ok"
+			if err := c.Check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[c.Name] = result
+			if result != "ok" {
+				ok = false
+				resp.Status = "failed"
+			}
+		}()
+	}
+	wg.Wait()
+	return resp, ok
+}
+
+// healthz 存活检查，进程能够处理请求即返回 200，响应中包含依赖服务的连通性
+// 依赖服务不可用时重启进程无济于事，因此不影响状态码
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	resp, _ := s.runChecks(r.Context())
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// readyz 就绪检查，任一依赖服务不可用时返回 503
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	resp, ok := s.runChecks(r.Context())
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
@@ -0,0 +1,52 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	healthy := HealthCheck{Name: "mysql", Check: func(ctx context.Context) error { return nil }}
+	unhealthy := HealthCheck{Name: "redis", Check: func(ctx context.Context) error { return errors.New("connection refused") }}
+
+	tests := []struct {
+		name       string
+		path       string
+		checks     []HealthCheck
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "healthz ok", path: "/healthz", checks: []HealthCheck{healthy}, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "healthz failed", path: "/healthz", checks: []HealthCheck{healthy, unhealthy}, wantCode: http.StatusOK, wantStatus: "failed"},
+		{name: "readyz ok", path: "/readyz", checks: []HealthCheck{healthy}, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "readyz failed", path: "/readyz", checks: []HealthCheck{healthy, unhealthy}, wantCode: http.StatusServiceUnavailable, wantStatus: "failed"},
+		{name: "readyz without checks", path: "/readyz", wantCode: http.StatusOK, wantStatus: "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("", nil, nil, nil, tt.checks...)
+			rec := httptest.NewRecorder()
+			s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var resp HealthResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus || len(resp.Checks) != len(tt.checks) {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+			for _, c := range tt.checks {
+				if c.Name == "redis" && resp.Checks[c.Name] != "connection refused" {
+					t.Fatalf("check %s = %q", c.Name, resp.Checks[c.Name])
+				}
+			}
+		})
+	}
+}
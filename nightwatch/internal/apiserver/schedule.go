@@ -0,0 +1,239 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 错过执行时间后最多补偿执行的次数上限
+const maxCatchUpLimit = 100
+
+// CreateScheduleRequest 创建定时任务请求
+type CreateScheduleRequest struct {
+	Name              string                  `json:"name"`
+	Namespace         string                  `json:"namespace"`
+	UserID            int64                   `json:"user_id"`
+	Spec              string                  `json:"spec"`
+	Template          model.TaskTemplate      `json:"template"`
+	ConcurrencyPolicy model.ConcurrencyPolicy `json:"concurrency_policy"`
+	CatchUpLimit      int                     `json:"catch_up_limit"`
+	Suspend           bool                    `json:"suspend"`
+}
+
+// Validate 校验创建定时任务请求，executors 为允许使用的执行器
+func (r *CreateScheduleRequest) Validate(executors []model.ExecutorType) error {
+	var allErrs field.ErrorList
+	// 定时任务名称加上时间戳后缀作为任务名称，需要满足 DNS-1123 label 规范
+	for _, msg := range validation.IsDNS1123Label(r.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("name"), r.Name, msg))
+	}
+	if len(r.Name) > model.MaxScheduleNameLength {
+		allErrs = append(allErrs, field.TooLong(field.NewPath("name"), r.Name, model.MaxScheduleNameLength))
+	}
+	for _, msg := range validation.IsDNS1123Label(r.Namespace) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("namespace"), r.Namespace, msg))
+	}
+	if _, err := watcher.Parser.Parse(r.Spec); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec"), r.Spec, err.Error()))
+	}
+	allErrs = append(allErrs, r.Template.Validate(field.NewPath("template"), executors)...)
+	switch r.ConcurrencyPolicy {
+	case "", model.ConcurrencyPolicyAllow, model.ConcurrencyPolicyForbid, model.ConcurrencyPolicyReplace:
+	default:
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("concurrency_policy"), r.ConcurrencyPolicy,
+			[]model.ConcurrencyPolicy{model.ConcurrencyPolicyAllow, model.ConcurrencyPolicyForbid, model.ConcurrencyPolicyReplace}))
+	}
+	if r.CatchUpLimit < 0 || r.CatchUpLimit > maxCatchUpLimit {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("catch_up_limit"), r.CatchUpLimit,
+			fmt.Sprintf("must be between 0 and %d", maxCatchUpLimit)))
+	}
+	return allErrs.ToAggregate()
+}
+
+func (r *CreateScheduleRequest) toSchedule() *model.TaskSchedule {
+	policy := r.ConcurrencyPolicy
+	if policy == "" {
+		policy = model.ConcurrencyPolicyAllow
+	}
+	template := r.Template
+	if template.BackoffStrategy == "" {
+		template.BackoffStrategy = model.BackoffStrategyFixed
+	}
+	return &model.TaskSchedule{
+		Name:              r.Name,
+		Namespace:         r.Namespace,
+		UserID:            r.UserID,
+		Spec:              r.Spec,
+		Template:          template,
+		ConcurrencyPolicy: policy,
+		CatchUpLimit:      r.CatchUpLimit,
+		Suspend:           r.Suspend,
+	}
+}
+
+// ListScheduleResponse 定时任务列表分页响应
+type ListScheduleResponse struct {
+	TotalCount int64                 `json:"total_count"`
+	Offset     int                   `json:"offset"`
+	Limit      int                   `json:"limit"`
+	Schedules  []*model.TaskSchedule `json:"schedules"`
+}
+
+func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(s.executors); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	schedule := req.toSchedule()
+	if err := s.store.Schedules().Create(r.Context(), schedule); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			writeError(w, http.StatusConflict, fmt.Errorf("schedule %s/%s already exists", req.Namespace, req.Name))
+			return
+		}
+		slog.Error("Failed to create schedule", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, schedule)
+}
+
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.findSchedule(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filters := make(map[string]any)
+	if v := query.Get("namespace"); v != "" {
+		filters["namespace"] = v
+	}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid user_id: %w", err))
+			return
+		}
+		filters["user_id"] = userID
+	}
+	if v := query.Get("suspend"); v != "" {
+		suspend, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid suspend: %w", err))
+			return
+		}
+		filters["suspend"] = suspend
+	}
+
+	opts, err := pageOptions(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts = append(opts, meta.WithFilter(filters))
+
+	count, schedules, err := s.store.Schedules().List(r.Context(), opts...)
+	if err != nil {
+		if errors.Is(err, meta.ErrInvalidOption) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		slog.Error("Failed to list schedules", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	o := meta.NewListOptions(opts...)
+	writeJSON(w, http.StatusOK, ListScheduleResponse{
+		TotalCount: count,
+		Offset:     o.Offset,
+		Limit:      o.Limit,
+		Schedules:  schedules,
+	})
+}
+
+// suspendSchedule 返回暂停或恢复定时任务的 handler，暂停期间错过的执行时间按补偿次数上限补偿执行
+func (s *Server) suspendSchedule(suspend bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedule, ok := s.findSchedule(w, r)
+		if !ok {
+			return
+		}
+
+		// 条件更新，避免覆盖 watcher 同时记录的最近一次执行时间
+		conds := map[string]any{"updated_at": schedule.UpdatedAt, "last_schedule_at": schedule.LastScheduleAt}
+		schedule.Suspend = suspend
+		ok, err := s.store.Schedules().UpdateWhere(r.Context(), schedule, conds)
+		if err != nil {
+			slog.Error("Failed to update schedule", "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusConflict, errors.New("schedule has been changed concurrently, please retry"))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, schedule)
+	}
+}
+
+// deleteSchedule 删除定时任务，已经创建的任务不受影响
+func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.findSchedule(w, r); !ok {
+		return
+	}
+
+	if err := s.store.Schedules().Delete(r.Context(), r.PathValue("id")); err != nil {
+		slog.Error("Failed to delete schedule", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// findSchedule 根据路径参数查询定时任务，查询失败时直接写入错误响应
+func (s *Server) findSchedule(w http.ResponseWriter, r *http.Request) (*model.TaskSchedule, bool) {
+	id := r.PathValue("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid schedule id: %s", id))
+		return nil, false
+	}
+
+	schedule, err := s.store.Schedules().Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("schedule %s not found", id))
+			return nil, false
+		}
+		slog.Error("Failed to get schedule", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return schedule, true
+}
@@ -0,0 +1,46 @@
+package apiserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func TestCreateScheduleRequestValidate(t *testing.T) {
+	valid := func() CreateScheduleRequest {
+		return CreateScheduleRequest{
+			Name:      "nightly-report",
+			Namespace: "demo",
+			Spec:      "0 0 2 * * *",
+			Template:  model.TaskTemplate{Info: model.TaskInfo{Image: "busybox"}},
+		}
+	}
+	executors := []model.ExecutorType{model.ExecutorKubernetes}
+
+	tests := []struct {
+		name    string
+		modify  func(r *CreateScheduleRequest)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(r *CreateScheduleRequest) {}},
+		{name: "descriptor spec", modify: func(r *CreateScheduleRequest) { r.Spec = "@every 1h" }},
+		{name: "spec without seconds", modify: func(r *CreateScheduleRequest) { r.Spec = "0 2 * * *" }, wantErr: true},
+		{name: "name too long", modify: func(r *CreateScheduleRequest) { r.Name = strings.Repeat("a", 53) }, wantErr: true},
+		{name: "invalid template", modify: func(r *CreateScheduleRequest) { r.Template.Info.Image = "" }, wantErr: true},
+		{name: "local executor disabled", modify: func(r *CreateScheduleRequest) { r.Template.Executor = model.ExecutorLocal }, wantErr: true},
+		{name: "forbid", modify: func(r *CreateScheduleRequest) { r.ConcurrencyPolicy = model.ConcurrencyPolicyForbid }},
+		{name: "unsupported policy", modify: func(r *CreateScheduleRequest) { r.ConcurrencyPolicy = "Queue" }, wantErr: true},
+		{name: "negative catch up limit", modify: func(r *CreateScheduleRequest) { r.CatchUpLimit = -1 }, wantErr: true},
+		{name: "catch up limit too large", modify: func(r *CreateScheduleRequest) { r.CatchUpLimit = maxCatchUpLimit + 1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			if err := req.Validate(executors); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
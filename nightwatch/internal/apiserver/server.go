@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// WatcherLister 返回当前实例中所有 Watcher 的调度状态
+type WatcherLister interface {
+	Watchers() []watcher.Status
+}
+
+// Server 提供 task、定时任务和 Watcher 管理的 REST API，以及健康检查和监控指标
+type Server struct {
+	store     store.IStore
+	executors []model.ExecutorType // 允许创建任务时指定的执行器
+	watchers  WatcherLister
+	checks    []HealthCheck // /healthz 和 /readyz 执行的依赖服务检查
+	server    *http.Server
+}
+
+// New 创建 API Server 对象
+func New(addr string, store store.IStore, executors []model.ExecutorType, watchers WatcherLister, checks ...HealthCheck) *Server {
+	s := &Server{store: store, executors: executors, watchers: watchers, checks: checks}
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tasks", s.createTask)
+	mux.HandleFunc("GET /v1/tasks", s.listTasks)
+	mux.HandleFunc("GET /v1/tasks/{id}", s.getTask)
+	mux.HandleFunc("GET /v1/tasks/{id}/events", s.listTaskEvents)
+	mux.HandleFunc("GET /v1/tasks/{id}/results", s.listTaskResults)
+	mux.HandleFunc("GET /v1/tasks/{id}/notifications", s.listTaskNotifications)
+	mux.HandleFunc("POST /v1/tasks/{id}/cancel", s.cancelTask)
+	mux.HandleFunc("POST /v1/tasks/{id}/retry", s.retryTask)
+	mux.HandleFunc("DELETE /v1/tasks/{id}", s.deleteTask)
+	mux.HandleFunc("POST /v1/schedules", s.createSchedule)
+	mux.HandleFunc("GET /v1/schedules", s.listSchedules)
+	mux.HandleFunc("GET /v1/schedules/{id}", s.getSchedule)
+	mux.HandleFunc("POST /v1/schedules/{id}/suspend", s.suspendSchedule(true))
+	mux.HandleFunc("POST /v1/schedules/{id}/resume", s.suspendSchedule(false))
+	mux.HandleFunc("DELETE /v1/schedules/{id}", s.deleteSchedule)
+	mux.HandleFunc("GET /v1/watchers", s.listWatchers)
+	mux.HandleFunc("PUT /v1/watchers/{name}", s.updateWatcher)
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+// Handler 返回 API Server 的路由，用于在测试中通过 httptest 启动
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Run 启动 API Server，此方法会阻塞直到关闭 stopCh
+func (s *Server) Run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.server.Shutdown(ctx); err != nil {
+			slog.Error("Failed to shutdown api server", "err", err)
+		}
+	}()
+
+	slog.Info("Starting api server", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Failed to run api server", "err", err)
+	}
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if v == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Message: err.Error()})
+}
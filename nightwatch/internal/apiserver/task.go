@@ -0,0 +1,485 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+// CreateTaskRequest 创建任务请求
+type CreateTaskRequest struct {
+	Name            string                `json:"name"`
+	Namespace       string                `json:"namespace"`
+	Info            model.TaskInfo        `json:"info"`
+	UserID          int64                 `json:"user_id"`
+	Priority        int                   `json:"priority"` // 优先级，值越大越先启动
+	Executor        model.ExecutorType    `json:"executor"`
+	MaxAttempts     int                   `json:"max_attempts"`
+	BackoffStrategy model.BackoffStrategy `json:"backoff_strategy"`
+	BackoffSeconds  int                   `json:"backoff_seconds"`
+	DependsOn       []int64               `json:"depends_on"` // 依赖的上游任务 ID，上游任务都执行成功后才会启动
+	// 任务进入终态时的通知目标
+	Notifications model.NotificationTargets `json:"notifications"`
+}
+
+// Validate 校验创建任务请求，executors 为允许使用的执行器
+func (r *CreateTaskRequest) Validate(executors []model.ExecutorType) error {
+	var allErrs field.ErrorList
+	// 任务名称会作为 Job 名称，需要满足 DNS-1123 label 规范
+	for _, msg := range validation.IsDNS1123Label(r.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("name"), r.Name, msg))
+	}
+	for _, msg := range validation.IsDNS1123Label(r.Namespace) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("namespace"), r.Namespace, msg))
+	}
+	template := &model.TaskTemplate{
+		Info:            r.Info,
+		Executor:        r.Executor,
+		MaxAttempts:     r.MaxAttempts,
+		BackoffStrategy: r.BackoffStrategy,
+		BackoffSeconds:  r.BackoffSeconds,
+		Notifications:   r.Notifications,
+	}
+	allErrs = append(allErrs, template.Validate(nil, executors)...)
+	for i, id := range r.DependsOn {
+		if id <= 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("depends_on").Index(i), id, "must be greater than 0"))
+		}
+	}
+	return allErrs.ToAggregate()
+}
+
+func (r *CreateTaskRequest) toTask() *model.Task {
+	strategy := r.BackoffStrategy
+	if strategy == "" {
+		strategy = model.BackoffStrategyFixed
+	}
+	return &model.Task{
+		Name:            r.Name,
+		Namespace:       r.Namespace,
+		Info:            r.Info,
+		Status:          model.TaskStatusNormal,
+		UserID:          r.UserID,
+		Priority:        r.Priority,
+		Executor:        r.Executor,
+		MaxAttempts:     max(r.MaxAttempts, 1),
+		BackoffStrategy: strategy,
+		BackoffSeconds:  r.BackoffSeconds,
+		Notifications:   r.Notifications,
+	}
+}
+
+// ListTaskResponse 任务列表分页响应
+type ListTaskResponse struct {
+	TotalCount int64         `json:"total_count"`
+	Offset     int           `json:"offset"`
+	Limit      int           `json:"limit"`
+	Tasks      []*model.Task `json:"tasks"`
+	// 下一页的游标，作为 cursor 参数传入即可继续查询，已经是最后一页时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var req CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(s.executors); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	task := req.toTask()
+	err := s.store.TX(r.Context(), func(ctx context.Context) error {
+		if err := s.store.Tasks().Create(ctx, task); err != nil {
+			return err
+		}
+		return s.store.Tasks().AddDependencies(ctx, task.ID, req.DependsOn)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			writeError(w, http.StatusConflict, fmt.Errorf("task %s/%s already exists", req.Namespace, req.Name))
+			return
+		}
+		if errors.Is(err, store.ErrUpstreamNotFound) || errors.Is(err, store.ErrDependencyCycle) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		slog.Error("Failed to create task", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, task)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filters := make(map[string]any)
+	if v := query["status"]; len(v) > 0 {
+		filters["status"] = splitValues(v)
+	}
+	if v := query.Get("namespace"); v != "" {
+		filters["namespace"] = v
+	}
+	if v := query.Get("name"); v != "" {
+		filters["name"] = v
+	}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid user_id: %w", err))
+			return
+		}
+		filters["user_id"] = userID
+	}
+	if v := query.Get("schedule_id"); v != "" {
+		scheduleID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid schedule_id: %w", err))
+			return
+		}
+		filters["schedule_id"] = scheduleID
+	}
+
+	opts, err := pageOptions(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts = append(opts, meta.WithFilter(filters))
+	if v := query.Get("name_like"); v != "" {
+		opts = append(opts, meta.WithContains("name", v))
+	}
+	// 创建时间范围 [created_after, created_before)
+	var createdAt [2]any
+	for i, name := range []string{"created_after", "created_before"} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", name, err))
+				return
+			}
+			createdAt[i] = t
+		}
+	}
+	opts = append(opts, meta.WithRange("created_at", createdAt[0], createdAt[1]))
+
+	count, tasks, err := s.store.Tasks().List(r.Context(), opts...)
+	if err != nil {
+		if errors.Is(err, meta.ErrInvalidOption) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		slog.Error("Failed to list tasks", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	o := meta.NewListOptions(opts...)
+	resp := ListTaskResponse{
+		TotalCount: count,
+		Offset:     o.Offset,
+		Limit:      o.Limit,
+		Tasks:      tasks,
+	}
+	if len(tasks) == o.Limit {
+		if resp.NextCursor, err = store.TaskCursor(tasks[len(tasks)-1], o.Order); err != nil {
+			slog.Error("Failed to create task cursor", "err", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// cancelTask 取消任务，还未启动或正在等待重试的任务直接取消
+// 已经提交执行的任务变为 Cancelling，由 watcher 删除执行实例后变为 Cancelled，返回 202
+func (s *Server) cancelTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	if task.Status == model.TaskStatusCancelling {
+		writeJSON(w, http.StatusAccepted, task)
+		return
+	}
+	to, ok := task.Status.CancelStatus()
+	if !ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("task in %s status cannot be cancelled", task.Status))
+		return
+	}
+
+	// 条件更新，避免与 watcher 并发启动任务时覆盖彼此的状态
+	from := task.Status
+	task.Status = to
+	err := s.store.TX(r.Context(), func(ctx context.Context) error {
+		var err error
+		if ok, err = s.store.Tasks().UpdateWhere(ctx, task, map[string]any{"status": from}); err != nil || !ok {
+			return err
+		}
+		event := model.NewTaskEvent(task, from, model.TaskEventReasonCancelled, "")
+		if err := s.store.TaskEvents().Create(ctx, event); err != nil {
+			return err
+		}
+		return s.store.Notifications().Create(ctx, model.NewTaskNotifications(task, event)...)
+	})
+	if err != nil {
+		slog.Error("Failed to cancel task", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusConflict, errors.New("task status has been changed concurrently, cannot be cancelled"))
+		return
+	}
+
+	if task.Status == model.TaskStatusCancelling {
+		writeJSON(w, http.StatusAccepted, task)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// RetryTaskRequest 重新执行任务请求
+type RetryTaskRequest struct {
+	// 新任务的名称，为空时使用原任务名称加上 -retry- 和当前时间戳
+	Name string `json:"name"`
+}
+
+// retryTask 以执行失败、被取消或上游失败的任务的配置创建新任务，原任务保持终态不变
+// 新任务不继承依赖关系，创建后立即可以启动
+func (s *Server) retryTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	var req RetryTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !slices.Contains([]model.TaskStatus{model.TaskStatusFailed, model.TaskStatusCancelled, model.TaskStatusUpstreamFailed}, task.Status) {
+		writeError(w, http.StatusConflict, fmt.Errorf("task in %s status cannot be retried", task.Status))
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = retryTaskName(task.Name, time.Now())
+	}
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		writeError(w, http.StatusBadRequest, field.Invalid(field.NewPath("name"), name, strings.Join(msgs, "; ")))
+		return
+	}
+
+	rerun := task.Rerun(name)
+	if err := s.store.Tasks().Create(r.Context(), rerun); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			writeError(w, http.StatusConflict, fmt.Errorf("task %s/%s already exists", rerun.Namespace, rerun.Name))
+			return
+		}
+		slog.Error("Failed to create retry task", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rerun)
+}
+
+// retryTaskName 返回重新执行任务时默认的新任务名称，超出长度时截断原任务名称
+func retryTaskName(name string, now time.Time) string {
+	suffix := fmt.Sprintf("-retry-%d", now.Unix())
+	if len(name)+len(suffix) > validation.DNS1123LabelMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// ListTaskEventResponse 任务状态变化时间线响应
+type ListTaskEventResponse struct {
+	Events []*model.TaskEvent `json:"events"`
+}
+
+// listTaskEvents 按发生顺序返回任务的状态变化记录
+func (s *Server) listTaskEvents(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := s.store.TaskEvents().List(r.Context(), task.ID)
+	if err != nil {
+		slog.Error("Failed to list task events", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListTaskEventResponse{Events: events})
+}
+
+// ListTaskResultResponse 任务每次执行结果的响应
+type ListTaskResultResponse struct {
+	Results []*model.TaskResult `json:"results"`
+}
+
+// listTaskResults 按执行次数返回任务每次执行的退出原因和最后的日志
+func (s *Server) listTaskResults(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := s.store.Tasks().ListResults(r.Context(), task.ID)
+	if err != nil {
+		slog.Error("Failed to list task results", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListTaskResultResponse{Results: results})
+}
+
+// ListTaskNotificationResponse 任务通知的响应
+type ListTaskNotificationResponse struct {
+	Notifications []*model.TaskNotification `json:"notifications"`
+}
+
+// listTaskNotifications 返回任务的所有通知及其发送状态
+func (s *Server) listTaskNotifications(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	notifications, err := s.store.Notifications().List(r.Context(), task.ID)
+	if err != nil {
+		slog.Error("Failed to list task notifications", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListTaskNotificationResponse{Notifications: notifications})
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.findTask(w, r)
+	if !ok {
+		return
+	}
+
+	// 已经提交执行的任务需要先结束，避免执行实例无人管理，Unknown 状态的执行实例也可能仍在运行
+	if slices.Contains([]model.TaskStatus{
+		model.TaskStatusPending,
+		model.TaskStatusRunning,
+		model.TaskStatusUnknown,
+		model.TaskStatusCancelling,
+	}, task.Status) {
+		writeError(w, http.StatusConflict, fmt.Errorf("task in %s status cannot be deleted", task.Status))
+		return
+	}
+
+	// 检查之后任务可能被 watcher 提交执行，只有状态和版本都没有变化时才删除
+	ok, err := s.store.Tasks().DeleteWhere(r.Context(), task, map[string]any{"status": task.Status})
+	if err != nil {
+		slog.Error("Failed to delete task", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusConflict, errors.New("task status has been changed concurrently, cannot be deleted"))
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// findTask 根据路径参数查询任务，查询失败时直接写入错误响应
+func (s *Server) findTask(w http.ResponseWriter, r *http.Request) (*model.Task, bool) {
+	id := r.PathValue("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid task id: %s", id))
+		return nil, false
+	}
+
+	task, err := s.store.Tasks().Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("task %s not found", id))
+			return nil, false
+		}
+		slog.Error("Failed to get task", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return task, true
+}
+
+// pageOptions 解析 offset、limit、order、cursor 分页排序参数，排序字段由 store 校验
+func pageOptions(query url.Values) ([]meta.ListOption, error) {
+	var opts []meta.ListOption
+	for _, p := range []struct {
+		name string
+		with func(int64) meta.ListOption
+	}{
+		{"offset", meta.WithOffset},
+		{"limit", meta.WithLimit},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", p.name, err)
+		}
+		opts = append(opts, p.with(n))
+	}
+	if v := query.Get("order"); v != "" {
+		opts = append(opts, meta.WithOrder(v))
+	}
+	if v := query.Get("cursor"); v != "" {
+		opts = append(opts, meta.WithCursor(v))
+	}
+	return opts, nil
+}
+
+// splitValues 支持 ?status=A&status=B 和 ?status=A,B 两种写法
+func splitValues(values []string) []string {
+	var ret []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,153 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+func TestSplitValues(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{values: nil, want: nil},
+		{values: []string{"Normal"}, want: []string{"Normal"}},
+		{values: []string{"Normal", "Running"}, want: []string{"Normal", "Running"}},
+		{values: []string{"Normal, Running", "Failed"}, want: []string{"Normal", "Running", "Failed"}},
+		{values: []string{",, ", ""}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := splitValues(tt.values); !slices.Equal(got, tt.want) {
+			t.Fatalf("splitValues(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTaskRequestValidate(t *testing.T) {
+	valid := func() CreateTaskRequest {
+		return CreateTaskRequest{
+			Name:      "demo-task",
+			Namespace: "demo",
+			Info:      model.TaskInfo{Image: "busybox", Command: []string{"sleep"}, Args: []string{"60"}},
+		}
+	}
+
+	kubernetes := []model.ExecutorType{model.ExecutorKubernetes}
+	all := []model.ExecutorType{model.ExecutorKubernetes, model.ExecutorLocal}
+
+	tests := []struct {
+		name      string
+		modify    func(r *CreateTaskRequest)
+		executors []model.ExecutorType
+		wantErr   bool
+	}{
+		{name: "valid", modify: func(r *CreateTaskRequest) {}, executors: kubernetes},
+		{name: "invalid name", modify: func(r *CreateTaskRequest) { r.Name = "Demo_Task" }, executors: kubernetes, wantErr: true},
+		{name: "empty namespace", modify: func(r *CreateTaskRequest) { r.Namespace = "" }, executors: kubernetes, wantErr: true},
+		{name: "missing image", modify: func(r *CreateTaskRequest) { r.Info.Image = "" }, executors: kubernetes, wantErr: true},
+		{name: "unsupported executor", modify: func(r *CreateTaskRequest) { r.Executor = "docker" }, executors: all, wantErr: true},
+		{name: "local executor disabled", modify: func(r *CreateTaskRequest) { r.Executor = model.ExecutorLocal }, executors: kubernetes, wantErr: true},
+		{name: "local executor enabled", modify: func(r *CreateTaskRequest) { r.Executor = model.ExecutorLocal }, executors: all},
+		{name: "negative max attempts", modify: func(r *CreateTaskRequest) { r.MaxAttempts = -1 }, executors: kubernetes, wantErr: true},
+		{name: "unsupported backoff strategy", modify: func(r *CreateTaskRequest) { r.BackoffStrategy = "Linear" }, executors: kubernetes, wantErr: true},
+		{name: "depends on", modify: func(r *CreateTaskRequest) { r.DependsOn = []int64{1, 2} }, executors: kubernetes},
+		{name: "invalid upstream id", modify: func(r *CreateTaskRequest) { r.DependsOn = []int64{1, 0} }, executors: kubernetes, wantErr: true},
+		{name: "webhook notification", modify: func(r *CreateTaskRequest) {
+			r.Notifications = model.NotificationTargets{{Type: model.NotifierWebhook, URL: "https://example.com/hook", Secret: "s3cr3t"}}
+		}, executors: kubernetes},
+		{name: "invalid notification url", modify: func(r *CreateTaskRequest) {
+			r.Notifications = model.NotificationTargets{{Type: model.NotifierFeishu, URL: "open.feishu.cn/hook"}}
+		}, executors: kubernetes, wantErr: true},
+		{
+			name: "exponential backoff",
+			modify: func(r *CreateTaskRequest) {
+				r.MaxAttempts = 3
+				r.BackoffStrategy = model.BackoffStrategyExponential
+				r.BackoffSeconds = 10
+			},
+			executors: kubernetes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			if err := req.Validate(tt.executors); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryTaskName(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "demo-task", want: "demo-task-retry-1700000000"},
+		{name: strings.Repeat("a", 63), want: strings.Repeat("a", 46) + "-retry-1700000000"},
+		{name: strings.Repeat("a", 45) + "-b", want: strings.Repeat("a", 45) + "-retry-1700000000"},
+	}
+	for _, tt := range tests {
+		got := retryTaskName(tt.name, now)
+		if got != tt.want {
+			t.Fatalf("retryTaskName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > 63 {
+			t.Fatalf("retryTaskName(%q) is longer than 63 characters", tt.name)
+		}
+	}
+}
+
+func TestDeleteTask(t *testing.T) {
+	tests := []struct {
+		status   model.TaskStatus
+		wantCode int
+	}{
+		{status: model.TaskStatusNormal, wantCode: http.StatusNoContent},
+		{status: model.TaskStatusFailed, wantCode: http.StatusNoContent},
+		{status: model.TaskStatusRunning, wantCode: http.StatusConflict},
+		// 执行实例可能仍在运行
+		{status: model.TaskStatusUnknown, wantCode: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			ctx := context.Background()
+			st := store.NewMemoryStore()
+			task := &model.Task{Name: "task", Namespace: "demo", Status: tt.status}
+			if err := st.Tasks().Create(ctx, task); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			event := model.NewTaskEvent(task, model.TaskStatusNormal, model.TaskEventReasonSubmitted, "")
+			if err := st.TaskEvents().Create(ctx, event); err != nil {
+				t.Fatalf("Create() event error = %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/v1/tasks/"+strconv.FormatInt(task.ID, 10), nil)
+			New("", st, nil, nil).Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d, body = %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			// 删除任务时一起删除状态变化
+			events, err := st.TaskEvents().List(ctx, task.ID)
+			if err != nil {
+				t.Fatalf("List() events error = %v", err)
+			}
+			if deleted := tt.wantCode == http.StatusNoContent; deleted != (len(events) == 0) {
+				t.Errorf("deleted = %v, events = %d", deleted, len(events))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// UpdateWatcherRequest 修改 Watcher 配置请求
+type UpdateWatcherRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Spec    string `json:"spec"` // 为空时使用 Watcher 默认的定时周期
+}
+
+// Validate 校验修改 Watcher 配置请求
+func (r *UpdateWatcherRequest) Validate() error {
+	var allErrs field.ErrorList
+	if r.Enabled == nil {
+		allErrs = append(allErrs, field.Required(field.NewPath("enabled"), ""))
+	}
+	if r.Spec != "" {
+		if _, err := watcher.Parser.Parse(r.Spec); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec"), r.Spec, err.Error()))
+		}
+	}
+	return allErrs.ToAggregate()
+}
+
+// ListWatcherResponse Watcher 列表响应
+type ListWatcherResponse struct {
+	Watchers []watcher.Status `json:"watchers"`
+}
+
+func (s *Server) listWatchers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ListWatcherResponse{Watchers: s.watchers.Watchers()})
+}
+
+// updateWatcher 保存 Watcher 配置，获取到锁的实例在下次重新加载配置时生效
+func (s *Server) updateWatcher(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !slices.ContainsFunc(s.watchers.Watchers(), func(status watcher.Status) bool { return status.Name == name }) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("watcher %s not found", name))
+		return
+	}
+
+	var req UpdateWatcherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	config := &model.WatcherConfig{Name: name, Enabled: *req.Enabled, Spec: req.Spec}
+	if err := s.store.Watchers().Save(r.Context(), config); err != nil {
+		slog.Error("Failed to save watcher config", "err", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config)
+}
@@ -0,0 +1,25 @@
+package apiserver
+
+import "testing"
+
+func TestUpdateWatcherRequestValidate(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name    string
+		req     UpdateWatcherRequest
+		wantErr bool
+	}{
+		{name: "default spec", req: UpdateWatcherRequest{Enabled: &enabled}},
+		{name: "custom spec", req: UpdateWatcherRequest{Enabled: &enabled, Spec: "*/10 * * * * *"}},
+		{name: "descriptor spec", req: UpdateWatcherRequest{Enabled: &enabled, Spec: "@every 1m"}},
+		{name: "missing enabled", req: UpdateWatcherRequest{}, wantErr: true},
+		{name: "invalid spec", req: UpdateWatcherRequest{Enabled: &enabled, Spec: "every minute"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
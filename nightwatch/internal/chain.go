@@ -0,0 +1,50 @@
+package nightwatch
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/metrics"
+)
+
+// skipIfStillRunning 与 cron.SkipIfStillRunning 相同，额外按 Watcher 记录跳过次数
+func skipIfStillRunning(name string, logger cron.Logger) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		ch := make(chan struct{}, 1)
+		ch <- struct{}{}
+		return cron.FuncJob(func() {
+			select {
+			case v := <-ch:
+				defer func() { ch <- v }()
+				j.Run()
+			default:
+				metrics.WatcherSkips.WithLabelValues(name).Inc()
+				logger.Info("skip", "watcher", name)
+			}
+		})
+	}
+}
+
+// recoverPanic 与 cron.Recover 相同，额外按 Watcher 记录 panic 次数
+func recoverPanic(name string, logger cron.Logger) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					metrics.WatcherPanics.WithLabelValues(name).Inc()
+					const size = 64 << 10
+					buf := make([]byte, size)
+					buf = buf[:runtime.Stack(buf, false)]
+					err, ok := r.(error)
+					if !ok {
+						err = fmt.Errorf("%v", r)
+					}
+					logger.Error(err, "panic", "watcher", name, "stack", "...\n"+string(buf))
+				}
+			}()
+			j.Run()
+		})
+	}
+}
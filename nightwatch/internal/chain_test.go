@@ -0,0 +1,49 @@
+package nightwatch
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/robfig/cron/v3"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/metrics"
+)
+
+func TestSkipIfStillRunning(t *testing.T) {
+	const name = "skipWatcher"
+	var job cron.Job
+	runs := 0
+	job = cron.NewChain(skipIfStillRunning(name, cron.DiscardLogger)).Then(cron.FuncJob(func() {
+		runs++
+		if runs == 1 {
+			// 执行期间再次触发时应该被跳过
+			job.Run()
+		}
+	}))
+
+	job.Run()
+	if runs != 1 {
+		t.Fatalf("runs = %d, want 1", runs)
+	}
+	if got := testutil.ToFloat64(metrics.WatcherSkips.WithLabelValues(name)); got != 1 {
+		t.Fatalf("skips = %v, want 1", got)
+	}
+
+	job.Run()
+	if runs != 2 {
+		t.Fatalf("runs = %d, want 2 after the previous run finished", runs)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	const name = "panicWatcher"
+	job := cron.NewChain(recoverPanic(name, cron.DiscardLogger)).Then(cron.FuncJob(func() {
+		panic("boom")
+	}))
+
+	job.Run()
+	job.Run()
+	if got := testutil.ToFloat64(metrics.WatcherPanics.WithLabelValues(name)); got != 2 {
+		t.Fatalf("panics = %v, want 2", got)
+	}
+}
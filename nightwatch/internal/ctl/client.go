@@ -0,0 +1,116 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/apiserver"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// Client nightwatch 任务管理 API 的客户端
+type Client struct {
+	server string
+	client *http.Client
+}
+
+// NewClient 创建访问 server 地址的客户端，如 http://127.0.0.1:8080
+func NewClient(server string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{server: strings.TrimRight(server, "/"), client: client}
+}
+
+// do 发送请求并将响应解码到 out，非 2xx 响应返回 API 的错误信息
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Message == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, e.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func taskPath(id int64, sub ...string) string {
+	return "/" + strings.Join(append([]string{"v1", "tasks", strconv.FormatInt(id, 10)}, sub...), "/")
+}
+
+func (c *Client) CreateTask(ctx context.Context, req *apiserver.CreateTaskRequest) (*model.Task, error) {
+	var task model.Task
+	return &task, c.do(ctx, http.MethodPost, "/v1/tasks", nil, req, &task)
+}
+
+// ListTasks 查询任务列表，query 与 GET /v1/tasks 的参数相同
+func (c *Client) ListTasks(ctx context.Context, query url.Values) (*apiserver.ListTaskResponse, error) {
+	var resp apiserver.ListTaskResponse
+	return &resp, c.do(ctx, http.MethodGet, "/v1/tasks", query, nil, &resp)
+}
+
+func (c *Client) GetTask(ctx context.Context, id int64) (*model.Task, error) {
+	var task model.Task
+	return &task, c.do(ctx, http.MethodGet, taskPath(id), nil, nil, &task)
+}
+
+func (c *Client) ListTaskEvents(ctx context.Context, id int64) ([]*model.TaskEvent, error) {
+	var resp apiserver.ListTaskEventResponse
+	err := c.do(ctx, http.MethodGet, taskPath(id, "events"), nil, nil, &resp)
+	return resp.Events, err
+}
+
+func (c *Client) ListTaskResults(ctx context.Context, id int64) ([]*model.TaskResult, error) {
+	var resp apiserver.ListTaskResultResponse
+	err := c.do(ctx, http.MethodGet, taskPath(id, "results"), nil, nil, &resp)
+	return resp.Results, err
+}
+
+func (c *Client) CancelTask(ctx context.Context, id int64) (*model.Task, error) {
+	var task model.Task
+	return &task, c.do(ctx, http.MethodPost, taskPath(id, "cancel"), nil, nil, &task)
+}
+
+// RetryTask 以任务的配置创建名称为 name 的新任务，name 为空时由 API 生成
+func (c *Client) RetryTask(ctx context.Context, id int64, name string) (*model.Task, error) {
+	var task model.Task
+	return &task, c.do(ctx, http.MethodPost, taskPath(id, "retry"), nil, &apiserver.RetryTaskRequest{Name: name}, &task)
+}
@@ -0,0 +1,390 @@
+package ctl
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/apiserver"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+const (
+	// defaultServer 未指定 --server 和 NIGHTWATCH_SERVER 环境变量时访问的 API 地址
+	defaultServer = "http://127.0.0.1:8080"
+	// serverEnv 指定 API 地址的环境变量
+	serverEnv = "NIGHTWATCH_SERVER"
+)
+
+// options 所有子命令共用的参数
+type options struct {
+	in      io.Reader
+	out     io.Writer
+	server  string
+	output  string
+	timeout time.Duration
+}
+
+func (o *options) client() *Client {
+	return NewClient(o.server, &http.Client{Timeout: o.timeout})
+}
+
+func (o *options) printer() *printer {
+	return &printer{out: o.out, format: o.output}
+}
+
+// NewCommand 创建 nightwatchctl 命令，通过 nightwatch 的 API 管理任务，in、out 为标准输入、输出
+func NewCommand(in io.Reader, out io.Writer) *cobra.Command {
+	o := &options{in: in, out: out}
+	server := os.Getenv(serverEnv)
+	if server == "" {
+		server = defaultServer
+	}
+
+	cmd := &cobra.Command{
+		Use:           "nightwatchctl",
+		Short:         "Manage nightwatch tasks through the nightwatch API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(o.output)
+		},
+	}
+	cmd.SetIn(in)
+	cmd.SetOut(out)
+	cmd.PersistentFlags().StringVarP(&o.server, "server", "s", server, "Address of the nightwatch API, defaults to $"+serverEnv+" or "+defaultServer)
+	cmd.PersistentFlags().StringVarP(&o.output, "output", "o", outputTable, "Output format, one of table, json or yaml")
+	cmd.PersistentFlags().DurationVar(&o.timeout, "timeout", 30*time.Second, "Timeout of each API request")
+
+	cmd.AddCommand(
+		newCreateCommand(o),
+		newListCommand(o),
+		newGetCommand(o),
+		newEventsCommand(o),
+		newLogsCommand(o),
+		newCancelCommand(o),
+		newRetryCommand(o),
+	)
+	return cmd
+}
+
+// parseID 解析命令参数中的任务 ID
+func parseID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid task id %q", s)
+	}
+	return id, nil
+}
+
+func newCreateCommand(o *options) *cobra.Command {
+	var filename string
+	cmd := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create tasks from a YAML manifest",
+		Long: "Create tasks from a YAML manifest with the same fields as the body of POST /v1/tasks.\n" +
+			"Multiple tasks can be separated by ---, use -f - to read the manifest from stdin.",
+		Example: "  nightwatchctl create -f task.yaml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reqs, err := readManifest(o.in, filename)
+			if err != nil {
+				return err
+			}
+			var tasks []*model.Task
+			for _, req := range reqs {
+				task, err := o.client().CreateTask(cmd.Context(), req)
+				if err != nil {
+					return fmt.Errorf("create task %s/%s: %w", req.Namespace, req.Name, err)
+				}
+				tasks = append(tasks, task)
+			}
+			return o.printer().print(tasks, printTasks(tasks))
+		},
+	}
+	cmd.Flags().StringVarP(&filename, "filename", "f", "", "YAML manifest of the tasks to create, - for stdin")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// readManifest 读取以 --- 分隔的多个创建任务请求，不允许未知字段
+func readManifest(stdin io.Reader, filename string) ([]*apiserver.CreateTaskRequest, error) {
+	r := stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var ret []*apiserver.CreateTaskRequest
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		// 空文档或只有注释的文档
+		if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+			continue
+		}
+		req := new(apiserver.CreateTaskRequest)
+		if err := yaml.UnmarshalStrict(doc, req); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		ret = append(ret, req)
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no task found in manifest")
+	}
+	return ret, nil
+}
+
+func newListCommand(o *options) *cobra.Command {
+	var (
+		statuses        []string
+		namespace, name string
+		userID, limit   int64
+		watch           bool
+		interval        time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List tasks",
+		Example: "  nightwatchctl list --status Running,Failed -n demo\n" +
+			"  nightwatchctl list --user-id 1 --watch",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			if len(statuses) > 0 {
+				query.Set("status", strings.Join(statuses, ","))
+			}
+			if namespace != "" {
+				query.Set("namespace", namespace)
+			}
+			if name != "" {
+				query.Set("name_like", name)
+			}
+			if userID > 0 {
+				query.Set("user_id", strconv.FormatInt(userID, 10))
+			}
+			if limit > 0 {
+				query.Set("limit", strconv.FormatInt(limit, 10))
+			}
+
+			if watch {
+				return watchTasks(cmd.Context(), o, query, interval)
+			}
+			resp, err := o.client().ListTasks(cmd.Context(), query)
+			if err != nil {
+				return err
+			}
+			return o.printer().print(resp, printTasks(resp.Tasks))
+		},
+	}
+	cmd.Flags().StringSliceVar(&statuses, "status", nil, "Only list tasks in these statuses, e.g. Running,Failed")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Only list tasks in this namespace")
+	cmd.Flags().StringVar(&name, "name", "", "Only list tasks whose name contains this string")
+	cmd.Flags().Int64Var(&userID, "user-id", 0, "Only list tasks of this user")
+	cmd.Flags().Int64Var(&limit, "limit", 0, "Maximum number of tasks to list, defaults to the limit of the API")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "After listing, watch for status changes of the listed tasks")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Polling interval of --watch")
+	return cmd
+}
+
+// watchTasks 定期查询任务列表，打印新出现或发生变化的任务，直到 ctx 取消
+func watchTasks(ctx context.Context, o *options, query url.Values, interval time.Duration) error {
+	p := o.printer()
+	seen := make(map[int64]int64) // 任务 ID 对应的 resource_version
+	header := p.format == outputTable
+	for {
+		resp, err := o.client().ListTasks(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var changed []*model.Task
+		for _, t := range resp.Tasks {
+			if rv, ok := seen[t.ID]; !ok || rv != t.ResourceVersion {
+				seen[t.ID] = t.ResourceVersion
+				changed = append(changed, t)
+			}
+		}
+		slices.SortFunc(changed, func(a, b *model.Task) int {
+			if c := a.UpdatedAt.Compare(b.UpdatedAt); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.ID, b.ID)
+		})
+		for _, t := range changed {
+			err := p.printStream(t, func(w io.Writer) {
+				if header {
+					printTaskHeader(w)
+					header = false
+				}
+				printTaskRow(w, t, time.Now())
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func newGetCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID",
+		Short: "Show a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			task, err := o.client().GetTask(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return o.printer().print(task, printTasks([]*model.Task{task}))
+		},
+	}
+}
+
+func newEventsCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "events ID",
+		Aliases: []string{"timeline"},
+		Short:   "Show the status timeline of a task",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			events, err := o.client().ListTaskEvents(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return o.printer().print(events, printEvents(events))
+		},
+	}
+}
+
+func newLogsCommand(o *options) *cobra.Command {
+	var attempt int
+	cmd := &cobra.Command{
+		Use:   "logs ID",
+		Short: "Show the exit status and last logs of a finished attempt of a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			results, err := o.client().ListTaskResults(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				return fmt.Errorf("task %d has no finished attempt", id)
+			}
+			// 默认为最后一次执行
+			result := results[len(results)-1]
+			if attempt > 0 {
+				i := slices.IndexFunc(results, func(r *model.TaskResult) bool { return r.Attempt == attempt })
+				if i < 0 {
+					return fmt.Errorf("attempt %d of task %d has no result", attempt, id)
+				}
+				result = results[i]
+			}
+			return o.printer().print(result, func(w io.Writer) {
+				exitCode := "-"
+				if result.ExitCode != nil {
+					exitCode = strconv.Itoa(int(*result.ExitCode))
+				}
+				fmt.Fprintf(w, "# attempt %d, %s, reason: %s, exit code: %s\n", result.Attempt, result.Name, result.Reason, exitCode)
+				if result.LogsTruncated {
+					fmt.Fprintln(w, "# logs are truncated")
+				}
+				fmt.Fprint(w, result.Logs)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&attempt, "attempt", 0, "Attempt to show, defaults to the last finished attempt")
+	return cmd
+}
+
+func newCancelCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel ID",
+		Short: "Cancel a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			task, err := o.client().CancelTask(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return o.printer().print(task, printTasks([]*model.Task{task}))
+		},
+	}
+}
+
+func newRetryCommand(o *options) *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "retry ID",
+		Short: "Run a failed, cancelled or upstream failed task again as a new task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			task, err := o.client().RetryTask(cmd.Context(), id, name)
+			if err != nil {
+				return err
+			}
+			return o.printer().print(task, printTasks([]*model.Task{task}))
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the new task, defaults to the original name with a -retry-<timestamp> suffix")
+	return cmd
+}
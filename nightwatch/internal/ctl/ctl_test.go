@@ -0,0 +1,236 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/apiserver"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/db"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+const manifest = `
+# 两个任务
+name: task-a
+namespace: demo
+user_id: 1
+info:
+  image: busybox
+  command: ["sh", "-c"]
+  args: ["echo a"]
+---
+name: task-b
+namespace: other
+user_id: 2
+info:
+  image: busybox
+  command: ["sh", "-c"]
+  args: ["exit 1"]
+---
+`
+
+// forEachStore 分别使用内存和 SQLite 存储启动 API Server 执行 fn
+func forEachStore(t *testing.T, fn func(t *testing.T, s store.IStore, server string)) {
+	t.Run("memory", func(t *testing.T) {
+		s := store.NewMemoryStore()
+		fn(t, s, newTestServer(t, s))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		gormDB, err := db.NewSQLite(&db.SQLiteOptions{Path: ":memory:", Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("NewSQLite() error = %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := gormDB.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		})
+		s, err := store.NewSQLiteStore(gormDB)
+		if err != nil {
+			t.Fatalf("NewSQLiteStore() error = %v", err)
+		}
+		fn(t, s, newTestServer(t, s))
+	})
+}
+
+func newTestServer(t *testing.T, s store.IStore) string {
+	t.Helper()
+	srv := httptest.NewServer(apiserver.New("", s, []model.ExecutorType{model.ExecutorKubernetes}, nil).Handler())
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// run 执行 nightwatchctl 命令，返回标准输出
+func run(t *testing.T, ctx context.Context, server, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := NewCommand(strings.NewReader(stdin), &out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"--server", server}, args...))
+	err := cmd.ExecuteContext(ctx)
+	return out.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.IStore, server string) {
+		ctx := context.Background()
+
+		out, err := run(t, ctx, server, manifest, "create", "-f", "-")
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if !strings.HasPrefix(out, "ID") || !strings.Contains(out, "task-a") || !strings.Contains(out, "task-b") {
+			t.Fatalf("unexpected create output:\n%s", out)
+		}
+
+		out, err = run(t, ctx, server, "", "list", "-n", "demo", "-o", "json")
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var resp apiserver.ListTaskResponse
+		if err := json.Unmarshal([]byte(out), &resp); err != nil {
+			t.Fatalf("unmarshal list output: %v", err)
+		}
+		if len(resp.Tasks) != 1 || resp.Tasks[0].Name != "task-a" {
+			t.Fatalf("list -n demo returned %+v", resp.Tasks)
+		}
+		taskA := resp.Tasks[0]
+
+		out, err = run(t, ctx, server, "", "list", "--user-id", "2", "--status", "Normal,Running", "-o", "yaml")
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if !strings.Contains(out, "name: task-b") || strings.Contains(out, "name: task-a") {
+			t.Fatalf("unexpected list output:\n%s", out)
+		}
+
+		if _, err := run(t, ctx, server, "", "retry", "1"); err == nil || !strings.Contains(err.Error(), "cannot be retried") {
+			t.Fatalf("retry a normal task: err = %v", err)
+		}
+
+		out, err = run(t, ctx, server, "", "cancel", "1")
+		if err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if !strings.Contains(out, string(model.TaskStatusCancelled)) {
+			t.Fatalf("unexpected cancel output:\n%s", out)
+		}
+
+		out, err = run(t, ctx, server, "", "events", "1")
+		if err != nil {
+			t.Fatalf("events: %v", err)
+		}
+		if !strings.Contains(out, string(model.TaskStatusNormal)) || !strings.Contains(out, string(model.TaskStatusCancelled)) {
+			t.Fatalf("unexpected events output:\n%s", out)
+		}
+
+		out, err = run(t, ctx, server, "", "retry", "1", "--name", "task-a-again", "-o", "json")
+		if err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+		var rerun model.Task
+		if err := json.Unmarshal([]byte(out), &rerun); err != nil {
+			t.Fatalf("unmarshal retry output: %v", err)
+		}
+		if rerun.Name != "task-a-again" || rerun.Status != model.TaskStatusNormal || rerun.Info.Args[0] != taskA.Info.Args[0] {
+			t.Fatalf("retry created %+v", rerun)
+		}
+
+		if _, err := run(t, ctx, server, "", "logs", "2"); err == nil {
+			t.Fatal("logs of a task without result should fail")
+		}
+		exitCode := int32(1)
+		for _, r := range []*model.TaskResult{
+			{TaskID: 2, Attempt: 1, Name: "task-b-1-abcde", Reason: "Error", ExitCode: &exitCode, Logs: "first\n"},
+			{TaskID: 2, Attempt: 2, Name: "task-b-2-fghij", Reason: "Error", ExitCode: &exitCode, Logs: "second\n"},
+		} {
+			if err := s.Tasks().CreateResult(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		out, err = run(t, ctx, server, "", "logs", "2")
+		if err != nil {
+			t.Fatalf("logs: %v", err)
+		}
+		if !strings.HasSuffix(out, "second\n") || !strings.Contains(out, "exit code: 1") {
+			t.Fatalf("unexpected logs output:\n%s", out)
+		}
+		out, err = run(t, ctx, server, "", "logs", "2", "--attempt", "1")
+		if err != nil {
+			t.Fatalf("logs: %v", err)
+		}
+		if !strings.HasSuffix(out, "first\n") {
+			t.Fatalf("unexpected logs output:\n%s", out)
+		}
+
+		if _, err := run(t, ctx, server, "", "get", "100"); err == nil {
+			t.Fatal("get a missing task should fail")
+		}
+		if _, err := run(t, ctx, server, "", "get", "1", "-o", "xml"); err == nil {
+			t.Fatal("unsupported output format should fail")
+		}
+	})
+}
+
+func TestReadManifestStrict(t *testing.T) {
+	for _, data := range []string{
+		"name: task-a\nnamespace: demo\nimage: busybox\n",
+		"# only comments\n---\n",
+	} {
+		if _, err := readManifest(strings.NewReader(data), "-"); err == nil {
+			t.Fatalf("readManifest(%q) should fail", data)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.IStore, server string) {
+		if _, err := run(t, context.Background(), server, manifest, "create", "-f", "-"); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan string)
+		go func() {
+			out, err := run(t, ctx, server, "", "list", "--watch", "--interval", "10ms", "-o", "json")
+			if err != nil {
+				t.Errorf("list --watch: %v", err)
+			}
+			done <- out
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		task, err := s.Tasks().Get(context.Background(), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Status = model.TaskStatusPending
+		if err := s.Tasks().Update(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+
+		// 每次变化输出一行 JSON：两个任务的初始状态和 task-a 变为 Pending
+		var statuses []string
+		for _, line := range strings.Split(strings.TrimSpace(<-done), "\n") {
+			var got model.Task
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatalf("unmarshal %q: %v", line, err)
+			}
+			statuses = append(statuses, got.Name+"="+string(got.Status))
+		}
+		want := "task-a=Normal,task-b=Normal,task-a=Pending"
+		if got := strings.Join(statuses, ","); got != want {
+			t.Fatalf("watch output = %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/yaml"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// 表格中单元格的最大长度，超出时截断
+const maxCellLength = 80
+
+// validateOutput 校验输出格式
+func validateOutput(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of table, json or yaml", format)
+	}
+}
+
+// printer 按输出格式打印 API 返回的对象
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+// print 以 JSON、YAML 格式打印 v，表格格式时由 table 打印
+func (p *printer) print(v any, table func(w io.Writer)) error {
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = p.out.Write(data)
+		return err
+	default:
+		tw := newTabWriter(p.out)
+		table(tw)
+		return tw.Flush()
+	}
+}
+
+// printStream 打印持续输出中的一个对象，JSON 格式每行一个对象，YAML 格式以 --- 分隔
+func (p *printer) printStream(v any, table func(w io.Writer)) error {
+	switch p.format {
+	case outputJSON:
+		return json.NewEncoder(p.out).Encode(v)
+	case outputYAML:
+		if _, err := io.WriteString(p.out, "---\n"); err != nil {
+			return err
+		}
+	}
+	return p.print(v, table)
+}
+
+func newTabWriter(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 8, 0, 3, ' ', 0)
+}
+
+const taskHeader = "ID\tNAME\tNAMESPACE\tSTATUS\tUSER\tPRIORITY\tATTEMPT\tAGE"
+
+func printTaskHeader(w io.Writer) {
+	fmt.Fprintln(w, taskHeader)
+}
+
+func printTaskRow(w io.Writer, t *model.Task, now time.Time) {
+	attempt := strconv.Itoa(t.Attempt)
+	if t.MaxAttempts > 1 {
+		attempt += "/" + strconv.Itoa(t.MaxAttempts)
+	}
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+		t.ID, t.Name, t.Namespace, t.Status, t.UserID, t.Priority, attempt, age(t.CreatedAt, now))
+}
+
+// printTasks 以表格打印任务列表
+func printTasks(tasks []*model.Task) func(w io.Writer) {
+	return func(w io.Writer) {
+		printTaskHeader(w)
+		now := time.Now()
+		for _, t := range tasks {
+			printTaskRow(w, t, now)
+		}
+	}
+}
+
+// printEvents 以表格打印任务的状态变化时间线
+func printEvents(events []*model.TaskEvent) func(w io.Writer) {
+	return func(w io.Writer) {
+		fmt.Fprintln(w, "TIME\tATTEMPT\tFROM\tTO\tREASON\tMESSAGE")
+		for _, e := range events {
+			from := string(e.FromStatus)
+			if from == "" {
+				from = "-"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+				e.CreatedAt.Local().Format(time.RFC3339), e.Attempt, from, e.ToStatus, e.Reason, cell(e.Message))
+		}
+	}
+}
+
+// age 返回从 t 到 now 经过的时间，与 kubectl 的 AGE 列格式相同
+func age(t, now time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(now.Sub(t))
+}
+
+// cell 返回适合放在表格中的单行内容
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxCellLength {
+		s = string(r[:maxCellLength-3]) + "..."
+	}
+	return s
+}
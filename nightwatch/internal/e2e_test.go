@@ -0,0 +1,321 @@
+package nightwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+// e2e 测试中等待状态变化的超时时间，taskWatcher 每秒执行一次
+const e2eTimeout = 10 * time.Second
+
+func createTask(t *testing.T, env *testEnv, name string) *model.Task {
+	t.Helper()
+	task := &model.Task{
+		Name:        name,
+		Namespace:   "demo",
+		Info:        model.TaskInfo{Image: "alpine", Command: []string{"sleep"}, Args: []string{"1"}},
+		Status:      model.TaskStatusNormal,
+		UserID:      1,
+		MaxAttempts: 1,
+	}
+	if err := env.store.Tasks().Create(context.Background(), task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	return task
+}
+
+// waitForStatus 等待任务变为 status
+func waitForStatus(t *testing.T, env *testEnv, task *model.Task, status model.TaskStatus) {
+	t.Helper()
+	waitFor(t, e2eTimeout, "task "+task.Name+" to be "+string(status), func() bool {
+		got, err := env.store.Tasks().Get(context.Background(), strconv.FormatInt(task.ID, 10))
+		return err == nil && got.Status == status
+	})
+}
+
+// waitForJob 等待任务对应的 Job 被创建
+func waitForJob(t *testing.T, env *testEnv, task *model.Task) *batchv1.Job {
+	t.Helper()
+	var job *batchv1.Job
+	waitFor(t, e2eTimeout, "job "+task.Name, func() bool {
+		var err error
+		job, err = env.clientset.BatchV1().Jobs(task.Namespace).Get(context.Background(), task.Name, metav1.GetOptions{})
+		return err == nil
+	})
+	return job
+}
+
+// updateJobStatus 修改 Job 的状态，模拟 Job controller 更新 Pod 运行情况
+func updateJobStatus(t *testing.T, env *testEnv, job *batchv1.Job, mutate func(status *batchv1.JobStatus)) *batchv1.Job {
+	t.Helper()
+	job = job.DeepCopy()
+	mutate(&job.Status)
+	job, err := env.clientset.BatchV1().Jobs(job.Namespace).UpdateStatus(context.Background(), job, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update job status: %v", err)
+	}
+	return job
+}
+
+func completeJob(status *batchv1.JobStatus) {
+	now := metav1.Now()
+	status.Active, status.Succeeded = 0, 1
+	status.CompletionTime = &now
+	status.Conditions = append(status.Conditions,
+		batchv1.JobCondition{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+	)
+}
+
+// statusHistory 返回任务依次变化到的状态，忽略 Job 刚创建还没有 Pod 时短暂的 Unknown 状态
+func statusHistory(t *testing.T, env *testEnv, task *model.Task) []model.TaskStatus {
+	t.Helper()
+	events, err := env.store.TaskEvents().List(context.Background(), task.ID)
+	if err != nil {
+		t.Fatalf("Failed to list task events: %v", err)
+	}
+	var ret []model.TaskStatus
+	for _, e := range events {
+		if e.ToStatus != model.TaskStatusUnknown {
+			ret = append(ret, e.ToStatus)
+		}
+	}
+	return ret
+}
+
+func TestE2ETaskLifecycle(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) store.IStore{
+		"memory": func(t *testing.T) store.IStore { return store.NewMemoryStore() },
+		"sqlite": newSQLiteStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t, newStore(t))
+			nw := env.newInstances(t, 1)[0]
+			nw.start(t)
+			waitForLeader(t, nw)
+
+			task := createTask(t, env, "e2e-succeeded")
+			// Job 创建后还没有 Pod 时任务很快会从 Pending 变为 Unknown，只等待 Job 创建
+			job := waitForJob(t, env, task)
+
+			job = updateJobStatus(t, env, job, func(status *batchv1.JobStatus) { status.Active = 1 })
+			waitForStatus(t, env, task, model.TaskStatusRunning)
+
+			updateJobStatus(t, env, job, completeJob)
+			waitForStatus(t, env, task, model.TaskStatusSucceeded)
+
+			want := []model.TaskStatus{model.TaskStatusPending, model.TaskStatusRunning, model.TaskStatusSucceeded}
+			if got := statusHistory(t, env, task); !slices.Equal(got, want) {
+				t.Errorf("status history = %v, want %v", got, want)
+			}
+
+			// Job 失败时任务变为 Failed
+			task = createTask(t, env, "e2e-failed")
+			job = waitForJob(t, env, task)
+			updateJobStatus(t, env, job, func(status *batchv1.JobStatus) {
+				status.Failed = 1
+				status.Conditions = append(status.Conditions, batchv1.JobCondition{
+					Type:    batchv1.JobFailed,
+					Status:  corev1.ConditionTrue,
+					Reason:  "BackoffLimitExceeded",
+					Message: "Job has reached the specified backoff limit",
+				})
+			})
+			waitForStatus(t, env, task, model.TaskStatusFailed)
+		})
+	}
+}
+
+func TestE2ENotification(t *testing.T) {
+	payloads := make(chan model.NotificationPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload model.NotificationPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode notification: %v", err)
+		}
+		payloads <- payload
+	}))
+	defer srv.Close()
+
+	env := newTestEnv(t, store.NewMemoryStore())
+	nw := env.newInstances(t, 1)[0]
+	nw.start(t)
+	waitForLeader(t, nw)
+
+	task := &model.Task{
+		Name:          "e2e-notification",
+		Namespace:     "demo",
+		Info:          model.TaskInfo{Image: "alpine"},
+		Status:        model.TaskStatusNormal,
+		UserID:        1,
+		MaxAttempts:   1,
+		Notifications: model.NotificationTargets{{Type: model.NotifierWebhook, URL: srv.URL}},
+	}
+	if err := env.store.Tasks().Create(context.Background(), task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	job := waitForJob(t, env, task)
+	updateJobStatus(t, env, job, completeJob)
+	waitForStatus(t, env, task, model.TaskStatusSucceeded)
+
+	// 任务进入终态后由 notificationWatcher 发送通知
+	select {
+	case payload := <-payloads:
+		if payload.TaskID != task.ID || payload.Status != model.TaskStatusSucceeded {
+			t.Errorf("notification payload = %+v", payload)
+		}
+	case <-time.After(e2eTimeout):
+		t.Fatalf("Timed out waiting for notification")
+	}
+	waitFor(t, e2eTimeout, "notification to be sent", func() bool {
+		notifications, err := env.store.Notifications().List(context.Background(), task.ID)
+		return err == nil && len(notifications) == 1 && notifications[0].Status == model.NotificationStatusSent
+	})
+}
+
+func TestE2EGarbageCollection(t *testing.T) {
+	env := newTestEnv(t, store.NewMemoryStore())
+	ctx := context.Background()
+	if err := env.store.Watchers().Save(ctx, &model.WatcherConfig{Name: "gcWatcher", Enabled: true, Spec: "@every 1s"}); err != nil {
+		t.Fatalf("Failed to save watcher config: %v", err)
+	}
+
+	// 所属任务已经删除的 Job
+	orphan := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:        "e2e-orphan",
+		Namespace:   "demo",
+		Labels:      map[string]string{"app": "task-job", "task-id": "1000", "task-attempt": "1"},
+		Annotations: map[string]string{"nightwatch.io/task-id": "1000", "nightwatch.io/task-attempt": "1"},
+	}}
+	if _, err := env.clientset.BatchV1().Jobs("demo").Create(ctx, orphan, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	// 已经提交执行很久但 Job 已经消失的任务
+	lost := &model.Task{
+		Name:        "e2e-lost",
+		Namespace:   "demo",
+		Info:        model.TaskInfo{Image: "alpine"},
+		Status:      model.TaskStatusRunning,
+		UserID:      1,
+		MaxAttempts: 1,
+		Attempt:     1,
+		UpdatedAt:   time.Now().Add(-time.Hour),
+	}
+	if err := env.store.Tasks().Create(ctx, lost); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	nw := env.newInstances(t, 1)[0]
+	nw.start(t)
+	waitForLeader(t, nw)
+
+	waitFor(t, e2eTimeout, "orphan job to be deleted", func() bool {
+		_, err := env.clientset.BatchV1().Jobs("demo").Get(ctx, orphan.Name, metav1.GetOptions{})
+		return err != nil
+	})
+	waitForStatus(t, env, lost, model.TaskStatusFailed)
+	events, _ := env.store.TaskEvents().List(ctx, lost.ID)
+	if len(events) != 1 || events[0].Reason != model.TaskEventReasonExecutionLost {
+		t.Errorf("events of lost task = %+v, want one %s event", events, model.TaskEventReasonExecutionLost)
+	}
+}
+
+func TestE2ELockTakeover(t *testing.T) {
+	env := newTestEnv(t, store.NewMemoryStore())
+	instances := env.newInstances(t, 2)
+	for _, i := range instances {
+		i.start(t)
+	}
+
+	leader, token := waitForLeader(t, instances...)
+	standby := instances[0]
+	if standby == leader {
+		standby = instances[1]
+	}
+	if _, ok := standby.token(); ok {
+		t.Fatalf("both instances are leaders")
+	}
+
+	// leader 启动的任务在其退出后由新的 leader 继续同步状态
+	task := createTask(t, env, "e2e-takeover")
+	job := waitForJob(t, env, task)
+
+	leader.shutdown()
+	newLeader, newToken := waitForLeader(t, standby)
+	if newToken <= token {
+		t.Errorf("fencing token of new leader = %d, want greater than %d", newToken, token)
+	}
+
+	updateJobStatus(t, env, job, completeJob)
+	waitForStatus(t, env, task, model.TaskStatusSucceeded)
+
+	// 锁过期后 leader 续约失败，立即结束任期，旧任期的写入被拒绝
+	termCtx := newLeader.termCtx()
+	env.redis.expire(DefaultLockName)
+	waitFor(t, e2eTimeout, "leader to step down", func() bool { return termCtx.Err() != nil })
+	err := env.store.Tasks().Create(termCtx, &model.Task{Name: "stale", Namespace: "demo"})
+	if err == nil {
+		t.Errorf("write with the fencing token of an ended term succeeded")
+	}
+
+	// 没有其他实例时重新获取锁
+	_, token = waitForLeader(t, newLeader)
+	if token <= newToken {
+		t.Errorf("fencing token after reacquiring = %d, want greater than %d", token, newToken)
+	}
+}
+
+func TestE2EShutdown(t *testing.T) {
+	env := newTestEnv(t, store.NewMemoryStore())
+	nw := env.newInstances(t, 1)[0]
+	nw.start(t)
+	_, token := waitForLeader(t, nw)
+	if _, ok := env.redis.value(DefaultLockName); !ok {
+		t.Fatalf("lock is not held by the leader")
+	}
+
+	termCtx := nw.termCtx()
+
+	done := make(chan struct{})
+	go func() {
+		nw.shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(e2eTimeout):
+		t.Fatalf("Run did not return after stop")
+	}
+
+	if termCtx.Err() == nil {
+		t.Errorf("term context is not cancelled after stop")
+	}
+	if _, ok := nw.token(); ok {
+		t.Errorf("instance is still the leader after stop")
+	}
+	if _, ok := env.redis.value(DefaultLockName); ok {
+		t.Errorf("lock is not released after stop")
+	}
+	// 任期结束后的写入被拒绝，其他实例获取锁前不会被旧 leader 修改数据
+	next, err := env.store.Fencing().Next(context.Background(), DefaultLockName)
+	if err != nil || next != token+1 {
+		t.Fatalf("Next() = %d, %v, want %d", next, err, token+1)
+	}
+	if err := env.store.Tasks().Create(termCtx, &model.Task{Name: "stale"}); !errors.Is(err, store.ErrStaleFencingToken) {
+		t.Errorf("write after stop error = %v, want ErrStaleFencingToken", err)
+	}
+}
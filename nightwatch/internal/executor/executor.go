@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+var (
+	// ErrInvalidTask 任务信息不合法，重试也无法执行成功
+	ErrInvalidTask = errors.New("invalid task")
+	// ErrNotFound 找不到任务当前执行次数对应的执行实例
+	ErrNotFound = errors.New("task execution not found")
+	// ErrConflict 同名的执行实例已经存在，且不属于任务当前的执行次数
+	ErrConflict = errors.New("task execution already exists")
+)
+
+// Executor 任务执行器，负责在具体的运行环境中执行任务
+// 同一个任务的每次执行通过 task.Attempt 区分
+type Executor interface {
+	// Submit 提交任务当前执行次数的执行
+	Submit(ctx context.Context, task *model.Task) error
+	// Status 返回任务当前执行次数的执行状态
+	Status(ctx context.Context, task *model.Task) (*State, error)
+	// Cancel 删除任务当前执行次数的执行实例，并等待删除完成，执行实例不存在时返回 nil
+	// ctx 结束前删除未完成时返回错误，可以重复调用
+	Cancel(ctx context.Context, task *model.Task) error
+	// Result 返回任务当前执行次数结束后的退出原因、退出码和最后的日志，执行实例已被清理时返回 ErrNotFound
+	Result(ctx context.Context, task *model.Task) (*model.TaskResult, error)
+}
+
+// State 任务单次执行的状态
+type State struct {
+	Status  model.TaskStatus
+	Name    string // 执行实例名称，如 Job 名称
+	Message string // 执行结束的原因
+	// 执行实例的状况，如 Job 的 Conditions，记录到任务的状态变化中
+	Conditions []model.TaskCondition
+}
+
+// Event 执行器主动推送的任务状态变化
+type Event struct {
+	TaskID  int64
+	Attempt int
+	State
+}
+
+// EventHandler 处理执行器推送的任务状态变化
+type EventHandler func(ctx context.Context, event *Event)
+
+// Terminator 由在 nightwatch 进程内执行任务的执行器实现
+// nightwatch 停止时需要终止所有执行，避免与新的 leader 重复执行
+type Terminator interface {
+	// TerminateAll 终止所有正在进行的执行，此方法会阻塞直到执行结束且状态变化已推送
+	TerminateAll()
+}
+
+// EventSource 由能够主动推送任务状态变化的执行器实现
+type EventSource interface {
+	// Watch 持续推送任务状态变化，此方法会阻塞直到 ctx 取消
+	Watch(ctx context.Context, handler EventHandler)
+}
+
+// Execution 执行器中由 nightwatch 创建的执行实例
+type Execution struct {
+	Namespace string
+	Name      string
+	TaskID    int64 // 所属任务 ID
+	Attempt   int   // 所属任务的执行次数
+	CreatedAt time.Time
+}
+
+// Collector 由执行实例独立于 nightwatch 进程存在的执行器实现，用于回收所属任务已经删除的执行实例
+type Collector interface {
+	// ListExecutions 返回执行器中所有由 nightwatch 创建的执行实例
+	ListExecutions(ctx context.Context) ([]Execution, error)
+	// DeleteExecution 删除执行实例，不等待删除完成，执行实例不存在时返回 nil
+	DeleteExecution(ctx context.Context, execution Execution) error
+}
@@ -0,0 +1,332 @@
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// Job 标签，informer 通过此标签过滤出 nightwatch 创建的 Job
+var jobLabels = map[string]string{
+	"app": "task-job",
+}
+
+// 记录 Job 所属任务 ID 和执行次数的标签
+const (
+	labelTaskID      = "task-id"
+	labelTaskAttempt = "task-attempt"
+)
+
+// 记录 Job 所属任务 ID 和执行次数的注解，提交时据此判断同名 Job 是否由同一次执行创建，回收时据此查找所属任务
+const (
+	annotationTaskID      = "nightwatch.io/task-id"
+	annotationTaskAttempt = "nightwatch.io/task-attempt"
+)
+
+// 执行任务的容器名称
+const taskContainerName = "task"
+
+// jobName 返回任务当前执行次数对应的 Job 名称，重试时会加上执行次数后缀
+func jobName(task *model.Task) string {
+	if task.Attempt <= 1 {
+		return task.Name
+	}
+
+	suffix := fmt.Sprintf("-attempt-%d", task.Attempt)
+	name := task.Name
+	if len(name)+len(suffix) > validation.DNS1123LabelMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// toJob 将任务转换为 K8s Job，任务信息不合法时返回错误
+func toJob(task *model.Task) (*batchv1.Job, error) {
+	info := &task.Info
+	if errs := info.Validate(field.NewPath("info"), model.ExecutorKubernetes); len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTask, errs.ToAggregate())
+	}
+
+	resources, err := toResourceRequirements(info.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTask, err)
+	}
+
+	container := corev1.Container{
+		Name:      taskContainerName,
+		Image:     info.Image,
+		Command:   info.Command,
+		Args:      info.Args,
+		Resources: resources,
+	}
+	for _, env := range info.Env {
+		container.Env = append(container.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	for _, name := range info.ConfigMapRefs {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		})
+	}
+	for _, name := range info.SecretRefs {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		})
+	}
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy:      corev1.RestartPolicyNever,
+		NodeSelector:       info.NodeSelector,
+		ServiceAccountName: info.ServiceAccount,
+	}
+	for _, v := range info.Volumes {
+		podSpec.Volumes = append(podSpec.Volumes, toVolume(v))
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      v.Name,
+			MountPath: v.MountPath,
+			ReadOnly:  v.ReadOnly,
+		})
+	}
+	for _, t := range info.Tolerations {
+		podSpec.Tolerations = append(podSpec.Tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	podSpec.Containers = []corev1.Container{container}
+
+	// 重试由 nightwatch 控制，每次执行只运行一个 Pod
+	backoffLimit := int32(0)
+	labels := maps.Clone(jobLabels)
+	labels[labelTaskID] = strconv.FormatInt(task.ID, 10)
+	labels[labelTaskAttempt] = strconv.Itoa(task.Attempt)
+	jobSpec := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName(task),
+			Labels: labels,
+			Annotations: map[string]string{
+				annotationTaskID:      strconv.FormatInt(task.ID, 10),
+				annotationTaskAttempt: strconv.Itoa(task.Attempt),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: maps.Clone(jobLabels),
+				},
+				Spec: podSpec,
+			},
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   info.ActiveDeadlineSeconds,
+			TTLSecondsAfterFinished: info.TTLSecondsAfterFinished,
+		},
+	}
+	return jobSpec, nil
+}
+
+// jobOwner 返回 Job 所属的任务 ID 和执行次数，非 nightwatch 创建的 Job 返回 false
+// 没有注解时使用标签，兼容添加注解之前创建的 Job
+func jobOwner(job *batchv1.Job) (taskID int64, attempt int, ok bool) {
+	owner := job.Annotations
+	if _, found := owner[annotationTaskID]; !found {
+		owner = map[string]string{
+			annotationTaskID:      job.Labels[labelTaskID],
+			annotationTaskAttempt: job.Labels[labelTaskAttempt],
+		}
+	}
+
+	taskID, err := strconv.ParseInt(owner[annotationTaskID], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	attempt, err = strconv.Atoi(owner[annotationTaskAttempt])
+	if err != nil {
+		return 0, 0, false
+	}
+	return taskID, attempt, true
+}
+
+func toVolume(v model.Volume) corev1.Volume {
+	volume := corev1.Volume{Name: v.Name}
+	switch {
+	case v.ConfigMap != "":
+		volume.ConfigMap = &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: v.ConfigMap}}
+	case v.Secret != "":
+		volume.Secret = &corev1.SecretVolumeSource{SecretName: v.Secret}
+	case v.PersistentVolumeClaim != "":
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim, ReadOnly: v.ReadOnly}
+	case v.EmptyDir:
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+	return volume
+}
+
+func toResourceRequirements(r model.Resources) (corev1.ResourceRequirements, error) {
+	var (
+		ret corev1.ResourceRequirements
+		err error
+	)
+	fldPath := field.NewPath("info", "resources")
+	if ret.Requests, err = toResourceList(r.Requests, fldPath.Child("requests")); err != nil {
+		return ret, err
+	}
+	if ret.Limits, err = toResourceList(r.Limits, fldPath.Child("limits")); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+func toResourceList(rl model.ResourceList, fldPath *field.Path) (corev1.ResourceList, error) {
+	quantities, errs := rl.Parse(fldPath)
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	if len(quantities) == 0 {
+		return nil, nil
+	}
+
+	ret := make(corev1.ResourceList, len(quantities))
+	for name, q := range quantities {
+		ret[corev1.ResourceName(name)] = q
+	}
+	return ret, nil
+}
+
+func toTaskStatus(job *batchv1.Job) model.TaskStatus {
+	// 检查 Job 状态
+	switch {
+	case isJobCompleted(job):
+		return model.TaskStatusSucceeded
+	case isJobFailed(job):
+		return model.TaskStatusFailed
+	case isJobSuspended(job):
+		return model.TaskStatusPending
+	case isJobFailureTarget(job):
+		return model.TaskStatusFailed
+	case isJobSuccessCriteriaMet(job):
+		return model.TaskStatusSucceeded
+	case isJobActive(job):
+		return model.TaskStatusRunning
+	default:
+		return model.TaskStatusUnknown
+	}
+}
+
+// jobConditions 将 Job 的 Conditions 转换为任务执行实例的状况
+func jobConditions(job *batchv1.Job) []model.TaskCondition {
+	var ret []model.TaskCondition
+	for _, condition := range job.Status.Conditions {
+		ret = append(ret, model.TaskCondition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime.Time,
+		})
+	}
+	return ret
+}
+
+// jobMessage 返回 Job 结束时的原因
+func jobMessage(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			if condition.Message == "" {
+				return condition.Reason
+			}
+			return condition.Reason + ": " + condition.Message
+		}
+	}
+	return ""
+}
+
+// 判断 Job 是否完成
+func isJobCompleted(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断 Job 是否失败
+func isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断 Job 是否挂起
+func isJobSuspended(job *batchv1.Job) bool {
+	return job.Spec.Suspend != nil && *job.Spec.Suspend
+}
+
+// 判断 Job 是否即将失败
+func isJobFailureTarget(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailureTarget && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断 Job 成功条件是否已满足
+func isJobSuccessCriteriaMet(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobSuccessCriteriaMet && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断 Job 是否正在运行
+func isJobActive(job *batchv1.Job) bool {
+	return job.Status.Active > 0
+}
+
+// latestPod 返回最后创建的 Pod，Job 只运行一个 Pod，被驱逐等情况下会有多个
+func latestPod(pods []corev1.Pod) *corev1.Pod {
+	var ret *corev1.Pod
+	for i := range pods {
+		if ret == nil || ret.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			ret = &pods[i]
+		}
+	}
+	return ret
+}
+
+// podResult 从任务容器的状态中获取退出原因和退出码，容器未启动时使用等待原因
+func podResult(pod *corev1.Pod) *model.TaskResult {
+	result := &model.TaskResult{Name: pod.Name, Reason: pod.Status.Reason, Message: pod.Status.Message}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != taskContainerName {
+			continue
+		}
+		switch {
+		case status.State.Terminated != nil:
+			terminated := status.State.Terminated
+			exitCode := terminated.ExitCode
+			result.Reason, result.Message, result.ExitCode = terminated.Reason, terminated.Message, &exitCode
+		case status.State.Waiting != nil:
+			result.Reason, result.Message = status.State.Waiting.Reason, status.State.Waiting.Message
+		}
+	}
+	return result
+}
@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func TestJobName(t *testing.T) {
+	long := strings.Repeat("a", 60) + "-bc"
+	tests := []struct {
+		name    string
+		task    string
+		attempt int
+		want    string
+	}{
+		{name: "first attempt", task: "demo-task", attempt: 1, want: "demo-task"},
+		{name: "not submitted", task: "demo-task", attempt: 0, want: "demo-task"},
+		{name: "retry", task: "demo-task", attempt: 3, want: "demo-task-attempt-3"},
+		{name: "long name first attempt", task: long, attempt: 1, want: long},
+		{name: "long name truncated", task: long, attempt: 2, want: strings.Repeat("a", 53) + "-attempt-2"},
+		{name: "truncated without trailing dash", task: strings.Repeat("a", 52) + "-bcdefghij", attempt: 12, want: strings.Repeat("a", 52) + "-attempt-12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jobName(&model.Task{Name: tt.task, Attempt: tt.attempt})
+			if got != tt.want {
+				t.Fatalf("jobName() = %q, want %q", got, tt.want)
+			}
+			if errs := validation.IsDNS1123Label(got); len(errs) > 0 {
+				t.Fatalf("jobName() = %q is not a valid DNS-1123 label: %v", got, errs)
+			}
+		})
+	}
+}
+
+func TestToJob(t *testing.T) {
+	deadline := int64(600)
+	task := &model.Task{
+		ID:        7,
+		Name:      "demo-task",
+		Namespace: "demo",
+		Attempt:   2,
+		Info: model.TaskInfo{
+			Image:         "busybox",
+			Command:       []string{"sh", "-c"},
+			Args:          []string{"echo $GREETING"},
+			Env:           []model.EnvVar{{Name: "GREETING", Value: "hello"}},
+			ConfigMapRefs: []string{"config"},
+			SecretRefs:    []string{"secret"},
+			Volumes: []model.Volume{
+				{Name: "data", MountPath: "/data", PersistentVolumeClaim: "data", ReadOnly: true},
+				{Name: "tmp", MountPath: "/tmp", EmptyDir: true},
+			},
+			Resources: model.Resources{
+				Requests: model.ResourceList{CPU: "100m", Memory: "64Mi"},
+				Limits:   model.ResourceList{Memory: "128Mi"},
+			},
+			NodeSelector:          map[string]string{"kubernetes.io/os": "linux"},
+			Tolerations:           []model.Toleration{{Key: "dedicated", Operator: "Equal", Value: "batch", Effect: "NoSchedule"}},
+			ServiceAccount:        "runner",
+			ActiveDeadlineSeconds: &deadline,
+		},
+	}
+
+	job, err := toJob(task)
+	if err != nil {
+		t.Fatalf("toJob() error = %v", err)
+	}
+
+	if job.Name != "demo-task-attempt-2" {
+		t.Errorf("job name = %q, want demo-task-attempt-2", job.Name)
+	}
+	if job.Labels[labelTaskID] != "7" || job.Labels[labelTaskAttempt] != "2" || job.Labels["app"] != "task-job" {
+		t.Errorf("unexpected job labels: %v", job.Labels)
+	}
+	if taskID, attempt, ok := jobOwner(job); !ok || taskID != 7 || attempt != 2 {
+		t.Errorf("jobOwner() = %d, %d, %v, want 7, 2, true; annotations: %v", taskID, attempt, ok, job.Annotations)
+	}
+	if *job.Spec.BackoffLimit != 0 {
+		t.Errorf("backoff limit = %d, want 0", *job.Spec.BackoffLimit)
+	}
+	if job.Spec.ActiveDeadlineSeconds == nil || *job.Spec.ActiveDeadlineSeconds != deadline {
+		t.Errorf("active deadline seconds = %v, want %d", job.Spec.ActiveDeadlineSeconds, deadline)
+	}
+
+	podSpec := job.Spec.Template.Spec
+	if podSpec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("restart policy = %s, want Never", podSpec.RestartPolicy)
+	}
+	if podSpec.ServiceAccountName != "runner" || podSpec.NodeSelector["kubernetes.io/os"] != "linux" {
+		t.Errorf("unexpected scheduling: service account %q, node selector %v", podSpec.ServiceAccountName, podSpec.NodeSelector)
+	}
+	if len(podSpec.Tolerations) != 1 || podSpec.Tolerations[0].Effect != corev1.TaintEffectNoSchedule {
+		t.Errorf("unexpected tolerations: %v", podSpec.Tolerations)
+	}
+	if len(podSpec.Volumes) != 2 || podSpec.Volumes[0].PersistentVolumeClaim == nil || podSpec.Volumes[1].EmptyDir == nil {
+		t.Errorf("unexpected volumes: %v", podSpec.Volumes)
+	}
+
+	container := podSpec.Containers[0]
+	if container.Image != "busybox" || len(container.Env) != 1 || len(container.EnvFrom) != 2 || len(container.VolumeMounts) != 2 {
+		t.Errorf("unexpected container: %+v", container)
+	}
+	if !container.VolumeMounts[0].ReadOnly {
+		t.Errorf("volume mount %s should be read only", container.VolumeMounts[0].Name)
+	}
+	if q := container.Resources.Requests[corev1.ResourceCPU]; !q.Equal(resource.MustParse("100m")) {
+		t.Errorf("cpu request = %s, want 100m", q.String())
+	}
+	if q := container.Resources.Limits[corev1.ResourceMemory]; !q.Equal(resource.MustParse("128Mi")) {
+		t.Errorf("memory limit = %s, want 128Mi", q.String())
+	}
+	if _, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("cpu limit should not be set")
+	}
+}
+
+func TestToJobInvalidTask(t *testing.T) {
+	tests := []model.TaskInfo{
+		{},
+		{Image: "busybox", Resources: model.Resources{Requests: model.ResourceList{CPU: "abc"}}},
+		{Image: "busybox", Volumes: []model.Volume{{Name: "data", MountPath: "/data"}}},
+	}
+	for _, info := range tests {
+		if _, err := toJob(&model.Task{Name: "demo-task", Info: info}); !errors.Is(err, ErrInvalidTask) {
+			t.Fatalf("toJob() with info %+v error = %v, want %v", info, err, ErrInvalidTask)
+		}
+	}
+}
+
+func TestToState(t *testing.T) {
+	now := metav1.Now()
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-task"},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailureTarget, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", LastTransitionTime: now},
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit", LastTransitionTime: now},
+			},
+		},
+	}
+
+	state := toState(job)
+	if state.Status != model.TaskStatusFailed || state.Name != "demo-task" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	if want := "BackoffLimitExceeded: Job has reached the specified backoff limit"; state.Message != want {
+		t.Fatalf("message = %q, want %q", state.Message, want)
+	}
+	if len(state.Conditions) != 2 {
+		t.Fatalf("conditions = %+v, want 2", state.Conditions)
+	}
+	got := state.Conditions[1]
+	if got.Type != "Failed" || got.Status != "True" || got.Reason != "BackoffLimitExceeded" || !got.LastTransitionTime.Equal(now.Time) {
+		t.Fatalf("unexpected condition: %+v", got)
+	}
+
+	if state := toState(&batchv1.Job{}); state.Conditions != nil {
+		t.Fatalf("conditions of new job = %+v, want nil", state.Conditions)
+	}
+}
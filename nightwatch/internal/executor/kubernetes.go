@@ -0,0 +1,351 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/metrics"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+const (
+	// informer 全量重新同步周期，作为事件丢失时的兜底
+	jobResyncPeriod = 10 * time.Minute
+	// 取消任务时检查 Job 是否删除完成的周期
+	jobDeletePollInterval = time.Second
+)
+
+var (
+	_ Executor    = (*kubernetesExecutor)(nil)
+	_ EventSource = (*kubernetesExecutor)(nil)
+	_ Collector   = (*kubernetesExecutor)(nil)
+)
+
+// kubernetesExecutor 以 K8s Job 的形式执行任务
+type kubernetesExecutor struct {
+	clientset kubernetes.Interface
+
+	mu        sync.RWMutex
+	jobLister batchv1listers.JobLister // informer 就绪后才有值
+}
+
+// NewKubernetes 创建 K8s Job 执行器
+func NewKubernetes(clientset kubernetes.Interface) Executor {
+	return &kubernetesExecutor{clientset: clientset}
+}
+
+func (e *kubernetesExecutor) Submit(ctx context.Context, task *model.Task) error {
+	job, err := toJob(task)
+	if err != nil {
+		return err
+	}
+
+	created, err := e.clientset.BatchV1().Jobs(task.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	metrics.JobRequests.WithLabelValues("create", metrics.Result(err)).Inc()
+	if apierrors.IsAlreadyExists(err) {
+		return e.adopt(ctx, task, job.Name)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Successfully created job", "namespace", created.Namespace, "name", created.Name)
+	return nil
+}
+
+// adopt 处理同名 Job 已经存在的情况：Job 属于任务当前的执行次数时，说明之前的提交已经创建了 Job，
+// 只是随后更新任务状态失败或 leader 在此期间退出，直接接管该 Job；否则返回 ErrConflict
+func (e *kubernetesExecutor) adopt(ctx context.Context, task *model.Task, name string) error {
+	job, err := e.clientset.BatchV1().Jobs(task.Namespace).Get(ctx, name, metav1.GetOptions{})
+	metrics.JobRequests.WithLabelValues("get", requestResult(err)).Inc()
+	if err != nil {
+		return err
+	}
+	if err := checkOwner(job, task); err != nil {
+		return err
+	}
+	slog.Info("Adopted existing job", "namespace", task.Namespace, "name", name, "taskID", task.ID, "attempt", task.Attempt)
+	return nil
+}
+
+// checkOwner 检查 Job 是否由任务当前的执行次数创建，否则返回 ErrConflict
+// 任务名称过长被截断或者同名任务删除后重新创建时，不同任务的 Job 名称可能相同
+func checkOwner(job *batchv1.Job, task *model.Task) error {
+	taskID, attempt, ok := jobOwner(job)
+	if !ok {
+		return fmt.Errorf("%w: job %s/%s is not created by nightwatch", ErrConflict, job.Namespace, job.Name)
+	}
+	if taskID != task.ID || attempt != task.Attempt {
+		return fmt.Errorf("%w: job %s/%s belongs to task %d attempt %d", ErrConflict, job.Namespace, job.Name, taskID, attempt)
+	}
+	return nil
+}
+
+func (e *kubernetesExecutor) Status(ctx context.Context, task *model.Task) (*State, error) {
+	job, err := e.getJob(ctx, task.Namespace, jobName(task))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: job %s/%s", ErrNotFound, task.Namespace, jobName(task))
+		}
+		return nil, err
+	}
+	// 同名 Job 属于其他执行时，任务当前执行次数的 Job 已经不存在
+	if err := checkOwner(job, task); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, err)
+	}
+	return toState(job), nil
+}
+
+func (e *kubernetesExecutor) Cancel(ctx context.Context, task *model.Task) error {
+	name := jobName(task)
+	job, err := e.getJob(ctx, task.Namespace, name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 不删除属于其他执行的同名 Job
+	if err := checkOwner(job, task); err != nil {
+		slog.Info("Skip deleting job of another execution", "err", err, "taskID", task.ID, "attempt", task.Attempt)
+		return nil
+	}
+
+	// 前台删除，Pod 都删除后才会删除 Job，Job 不存在即表示执行已经停止
+	// 以 UID 作为前提条件，避免删除检查之后重新创建的同名 Job
+	propagation := metav1.DeletePropagationForeground
+	err = e.clientset.BatchV1().Jobs(task.Namespace).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+		Preconditions:     metav1.NewUIDPreconditions(string(job.UID)),
+	})
+	metrics.JobRequests.WithLabelValues("delete", requestResult(err)).Inc()
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Deleting job", "namespace", task.Namespace, "name", name)
+
+	return wait.PollUntilContextCancel(ctx, jobDeletePollInterval, true, func(ctx context.Context) (bool, error) {
+		current, err := e.getJob(ctx, task.Namespace, name)
+		if apierrors.IsNotFound(err) || (err == nil && current.UID != job.UID) {
+			return true, nil
+		}
+		if err != nil {
+			slog.Debug("Failed to get deleting job", "err", err, "namespace", task.Namespace, "name", name)
+		}
+		return false, nil
+	})
+}
+
+func (e *kubernetesExecutor) Result(ctx context.Context, task *model.Task) (*model.TaskResult, error) {
+	name := jobName(task)
+	pods, err := e.clientset.CoreV1().Pods(task.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"job-name": name}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pod := latestPod(pods.Items)
+	if pod == nil {
+		// Pod 已经随 Job 过期被清理
+		return nil, fmt.Errorf("%w: pod of job %s/%s", ErrNotFound, task.Namespace, name)
+	}
+
+	result := podResult(pod)
+	result.TaskID, result.Attempt = task.ID, task.Attempt
+
+	// 多读取一行和一个字节，用于判断日志是否超出上限
+	tailLines, limitBytes := int64(model.MaxTaskResultLogLines+1), int64(model.MaxTaskResultLogBytes+1)
+	logs, err := e.clientset.CoreV1().Pods(task.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  taskContainerName,
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}).Do(ctx).Raw()
+	if err != nil {
+		// 容器未启动时没有日志，仍然返回退出原因
+		slog.Warn("Failed to get pod logs", "err", err, "namespace", task.Namespace, "pod", pod.Name)
+		return result, nil
+	}
+	result.Logs, result.LogsTruncated = tailLogs(string(logs))
+	return result, nil
+}
+
+// tailLogs 截断按 MaxTaskResultLogLines+1 行、MaxTaskResultLogBytes+1 字节读取的日志，超出任一上限时加上截断标记，返回是否截断
+func tailLogs(logs string) (string, bool) {
+	linesExceeded := strings.Count(strings.TrimSuffix(logs, "\n"), "\n") >= model.MaxTaskResultLogLines
+	bytesExceeded := len(logs) > model.MaxTaskResultLogBytes
+	if linesExceeded {
+		// 多读取的一行说明还有更早的日志，丢弃这一行
+		_, logs, _ = strings.Cut(logs, "\n")
+		logs = model.TruncatedLogsMarker + logs
+	}
+	if bytesExceeded {
+		// kubelet 读取到字节数上限时停止，丢弃的是之后的日志，截断后为结尾的标记留出空间
+		n := min(len(logs), model.MaxTaskResultLogBytes-2*len(model.TruncatedLogsMarker))
+		for n > 0 && !utf8.RuneStart(logs[n]) {
+			n--
+		}
+		logs = logs[:n]
+		if !strings.HasSuffix(logs, "\n") {
+			logs += "\n"
+		}
+		logs += model.TruncatedLogsMarker
+	}
+	return logs, linesExceeded || bytesExceeded
+}
+
+// Watch 启动 Job informer，将 Job 状态变化实时推送给 handler，ctx 取消时退出
+func (e *kubernetesExecutor) Watch(ctx context.Context, handler EventHandler) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		e.clientset,
+		jobResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = labels.SelectorFromSet(jobLabels).String()
+		}),
+	)
+	informer := factory.Batch().V1().Jobs()
+
+	onJobChanged := func(obj any) {
+		if event := toEvent(obj); event != nil {
+			handler(ctx, event)
+		}
+	}
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onJobChanged,
+		UpdateFunc: func(_, obj any) {
+			onJobChanged(obj)
+		},
+	})
+	if err != nil {
+		slog.Error("Failed to add job event handler", "err", err)
+		return
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		slog.Error("Failed to wait for job informer cache to sync")
+		return
+	}
+	e.setJobLister(informer.Lister())
+	defer e.setJobLister(nil)
+	slog.Info("Successfully started job informer")
+
+	<-ctx.Done()
+	slog.Info("Job informer is stopped")
+}
+
+// ListExecutions 返回所有 namespace 中由 nightwatch 创建的 Job
+func (e *kubernetesExecutor) ListExecutions(ctx context.Context) ([]Execution, error) {
+	jobs, err := e.clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(jobLabels).String(),
+	})
+	metrics.JobRequests.WithLabelValues("list", metrics.Result(err)).Inc()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Execution
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		taskID, attempt, ok := jobOwner(job)
+		if !ok {
+			continue
+		}
+		ret = append(ret, Execution{
+			Namespace: job.Namespace,
+			Name:      job.Name,
+			TaskID:    taskID,
+			Attempt:   attempt,
+			CreatedAt: job.CreationTimestamp.Time,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteExecution 后台删除 Job，Pod 由 K8s 垃圾回收删除
+func (e *kubernetesExecutor) DeleteExecution(ctx context.Context, execution Execution) error {
+	propagation := metav1.DeletePropagationBackground
+	err := e.clientset.BatchV1().Jobs(execution.Namespace).Delete(ctx, execution.Name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	metrics.JobRequests.WithLabelValues("delete", requestResult(err)).Inc()
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (e *kubernetesExecutor) setJobLister(lister batchv1listers.JobLister) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobLister = lister
+}
+
+// getJob 优先从 informer 缓存中获取 Job，informer 未就绪或缓存中没有时请求 API Server
+// 刚创建的 Job 可能还没有同步到缓存中，缓存中没有不能说明 Job 不存在
+func (e *kubernetesExecutor) getJob(ctx context.Context, namespace, name string) (*batchv1.Job, error) {
+	e.mu.RLock()
+	lister := e.jobLister
+	e.mu.RUnlock()
+
+	if lister != nil {
+		job, err := lister.Jobs(namespace).Get(name)
+		if !apierrors.IsNotFound(err) {
+			return job, err
+		}
+	}
+	job, err := e.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	metrics.JobRequests.WithLabelValues("get", requestResult(err)).Inc()
+	return job, err
+}
+
+// requestResult 返回 Job 请求的结果标签，Job 不存在是正常的查询结果，不计为错误
+func requestResult(err error) string {
+	if apierrors.IsNotFound(err) {
+		return metrics.ResultSuccess
+	}
+	return metrics.Result(err)
+}
+
+// toEvent 将 Job 转换为任务状态变化事件，非 nightwatch 创建的 Job 返回 nil
+func toEvent(obj any) *Event {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return nil
+	}
+
+	taskID, attempt, ok := jobOwner(job)
+	if !ok {
+		return nil
+	}
+
+	return &Event{TaskID: taskID, Attempt: attempt, State: *toState(job)}
+}
+
+func toState(job *batchv1.Job) *State {
+	return &State{
+		Status:     toTaskStatus(job),
+		Name:       job.Name,
+		Message:    jobMessage(job),
+		Conditions: jobConditions(job),
+	}
+}
@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func TestKubernetesExecutorResult(t *testing.T) {
+	task := &model.Task{ID: 3, Name: "demo-task", Namespace: "demo", Attempt: 2}
+	newPod := func(name string, created time.Time, state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "demo",
+				Labels:            map[string]string{"job-name": jobName(task)},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: taskContainerName, State: state}},
+			},
+		}
+	}
+	now := time.Now()
+	clientset := fake.NewClientset(
+		newPod("evicted", now.Add(-time.Minute), corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"},
+		}),
+		newPod("latest", now, corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, Message: "out of memory"},
+		}),
+	)
+
+	result, err := NewKubernetes(clientset).Result(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	if result.TaskID != 3 || result.Attempt != 2 || result.Name != "latest" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Reason != "OOMKilled" || result.ExitCode == nil || *result.ExitCode != 137 || result.Message != "out of memory" {
+		t.Fatalf("unexpected exit status: %+v", result)
+	}
+	// fake clientset 返回固定的日志内容
+	if result.Logs != "fake logs" || result.LogsTruncated {
+		t.Fatalf("logs = %q, truncated = %v", result.Logs, result.LogsTruncated)
+	}
+
+	other := &model.Task{ID: 4, Name: "other-task", Namespace: "demo", Attempt: 1}
+	if _, err := NewKubernetes(clientset).Result(context.Background(), other); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Result() of missing pod error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestTailLogs(t *testing.T) {
+	lines := func(n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		return b.String()
+	}
+	long := strings.Repeat("x", model.MaxTaskResultLogBytes+1)
+
+	tests := []struct {
+		name          string
+		logs          string
+		wantPrefix    string
+		wantSuffix    string
+		wantTruncated bool
+	}{
+		{name: "within limits", logs: lines(model.MaxTaskResultLogLines), wantPrefix: "line 0\n", wantSuffix: "line 199\n"},
+		// 多读取的一行被丢弃
+		{name: "line limit", logs: lines(model.MaxTaskResultLogLines + 1), wantPrefix: model.TruncatedLogsMarker + "line 1\n", wantSuffix: "line 200\n", wantTruncated: true},
+		// 之后的日志已经被 kubelet 丢弃
+		{name: "byte limit", logs: long, wantPrefix: "xxx", wantSuffix: "x\n" + model.TruncatedLogsMarker, wantTruncated: true},
+		{name: "both limits", logs: lines(model.MaxTaskResultLogLines) + long, wantPrefix: model.TruncatedLogsMarker + "line 1\n", wantSuffix: "x\n" + model.TruncatedLogsMarker, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := tailLogs(tt.logs)
+			if truncated != tt.wantTruncated || !strings.HasPrefix(got, tt.wantPrefix) || !strings.HasSuffix(got, tt.wantSuffix) {
+				t.Fatalf("tailLogs() = %.32q...%q, %v, want prefix %q, suffix %q, truncated %v",
+					got, got[max(0, len(got)-32):], truncated, tt.wantPrefix, tt.wantSuffix, tt.wantTruncated)
+			}
+			if len(got) > model.MaxTaskResultLogBytes {
+				t.Fatalf("len(tailLogs()) = %d, want at most %d", len(got), model.MaxTaskResultLogBytes)
+			}
+		})
+	}
+}
+
+func TestKubernetesExecutorCancel(t *testing.T) {
+	task := &model.Task{ID: 3, Name: "demo-task", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}}
+	clientset := fake.NewClientset()
+	e := NewKubernetes(clientset)
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := e.Cancel(ctx, task); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if _, err := e.Status(ctx, task); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Status() of cancelled task error = %v, want %v", err, ErrNotFound)
+	}
+
+	var deletes []metav1.DeletionPropagation
+	for _, action := range clientset.Actions() {
+		if action, ok := action.(k8stesting.DeleteAction); ok {
+			deletes = append(deletes, *action.GetDeleteOptions().PropagationPolicy)
+		}
+	}
+	if len(deletes) != 1 || deletes[0] != metav1.DeletePropagationForeground {
+		t.Fatalf("delete propagation = %v, want [%s]", deletes, metav1.DeletePropagationForeground)
+	}
+
+	// Job 已经不存在时直接返回
+	if err := e.Cancel(ctx, task); err != nil {
+		t.Fatalf("Cancel() again error = %v", err)
+	}
+}
+
+func TestKubernetesExecutorListerMiss(t *testing.T) {
+	ctx := context.Background()
+	task := &model.Task{ID: 3, Name: "demo-task", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}}
+	clientset := fake.NewClientset()
+	e := NewKubernetes(clientset).(*kubernetesExecutor)
+	// informer 已经就绪，但还没有同步到刚创建的 Job
+	e.setJobLister(batchv1listers.NewJobLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})))
+	if err := e.Submit(ctx, task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	if _, err := e.Status(ctx, task); err != nil {
+		t.Fatalf("Status() of job missing in cache error = %v", err)
+	}
+
+	// 前台删除期间 Job 仍然存在，Cancel 需要等待删除完成
+	clientset.PrependReactor("delete", "jobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	timeoutCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancel()
+	if err := e.Cancel(timeoutCtx, task); err == nil {
+		t.Fatal("Cancel() returned before the job was deleted")
+	}
+}
+
+func TestKubernetesExecutorSubmitAdopt(t *testing.T) {
+	ctx := context.Background()
+	task := &model.Task{ID: 3, Name: "demo-task", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}}
+	foreign := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "demo"}}
+	clientset := fake.NewClientset(foreign)
+	e := NewKubernetes(clientset)
+
+	if err := e.Submit(ctx, task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	// 之前的提交已经创建了 Job，重新提交时接管已有的 Job
+	if err := e.Submit(ctx, task); err != nil {
+		t.Fatalf("Submit() of existing job error = %v", err)
+	}
+
+	// 同名 Job 属于其他任务或不是 nightwatch 创建的
+	reused := &model.Task{ID: 4, Name: "demo-task", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}}
+	if err := e.Submit(ctx, reused); !errors.Is(err, ErrConflict) {
+		t.Errorf("Submit() of job owned by another task error = %v, want %v", err, ErrConflict)
+	}
+	// 不能把其他任务的 Job 当作自己的执行，也不能删除它
+	if _, err := e.Status(ctx, reused); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Status() of job owned by another task error = %v, want %v", err, ErrNotFound)
+	}
+	if err := e.Cancel(ctx, reused); err != nil {
+		t.Errorf("Cancel() of job owned by another task error = %v", err)
+	}
+	if _, err := e.Status(ctx, task); err != nil {
+		t.Errorf("Status() after cancelling another task error = %v", err)
+	}
+	other := &model.Task{ID: 5, Name: "foreign", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}}
+	if err := e.Submit(ctx, other); !errors.Is(err, ErrConflict) {
+		t.Errorf("Submit() of foreign job error = %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestKubernetesExecutorCollector(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewClientset(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "demo"}})
+	e := NewKubernetes(clientset).(Collector)
+	tasks := []*model.Task{
+		{ID: 3, Name: "a", Namespace: "demo", Attempt: 1, Info: model.TaskInfo{Image: "busybox"}},
+		{ID: 4, Name: "b", Namespace: "other", Attempt: 2, Info: model.TaskInfo{Image: "busybox"}},
+	}
+	for _, task := range tasks {
+		if err := NewKubernetes(clientset).Submit(ctx, task); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+
+	executions, err := e.ListExecutions(ctx)
+	if err != nil {
+		t.Fatalf("ListExecutions() error = %v", err)
+	}
+	got := map[string]Execution{}
+	for _, execution := range executions {
+		got[execution.Namespace+"/"+execution.Name] = execution
+	}
+	if len(got) != 2 || got["demo/a"].TaskID != 3 || got["other/b-attempt-2"].Attempt != 2 {
+		t.Fatalf("ListExecutions() = %+v, want jobs of task 3 and 4", executions)
+	}
+
+	if err := e.DeleteExecution(ctx, got["demo/a"]); err != nil {
+		t.Fatalf("DeleteExecution() error = %v", err)
+	}
+	if err := e.DeleteExecution(ctx, got["demo/a"]); err != nil {
+		t.Fatalf("DeleteExecution() of deleted job error = %v", err)
+	}
+	if executions, _ := e.ListExecutions(ctx); len(executions) != 1 {
+		t.Errorf("ListExecutions() after delete = %+v, want 1 execution", executions)
+	}
+}
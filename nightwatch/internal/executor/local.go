@@ -0,0 +1,344 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+const (
+	// 每个进程最多保留的输出字节数
+	maxLocalOutputBytes = 1 << 20
+	// 进程结束后在内存中保留的时间，便于 Status 和 Logs 查询
+	localProcessRetention = time.Hour
+	// 进程退出或被取消后，等待输出管道关闭的最长时间
+	localProcessWaitDelay = 5 * time.Second
+)
+
+var (
+	_ Executor    = (*localExecutor)(nil)
+	_ EventSource = (*localExecutor)(nil)
+	_ Terminator  = (*localExecutor)(nil)
+)
+
+var (
+	// errTerminated 进程因 nightwatch 停止被终止
+	errTerminated = errors.New("nightwatch is stopping")
+	// errCancelled 进程因任务被取消被终止
+	errCancelled = errors.New("task is cancelled")
+)
+
+// localExecutor 以 nightwatch 本地进程的形式执行任务，忽略镜像以及 K8s 相关配置
+// 进程信息只保存在内存中，nightwatch 停止时会终止所有进程，重启后无法恢复
+type localExecutor struct {
+	mu        sync.Mutex
+	processes map[int64]*process // key 为任务 ID
+	notify    func(event *Event) // Watch 期间才有值
+}
+
+type process struct {
+	attempt int
+	cmd     *exec.Cmd
+	cancel  context.CancelCauseFunc
+	output  *tailBuffer   // 保留最后的输出，便于查询日志
+	done    chan struct{} // 进程结束且状态变化已推送后关闭
+
+	mu    sync.Mutex
+	state State
+}
+
+// NewLocal 创建本地进程执行器
+func NewLocal() Executor {
+	return &localExecutor{processes: make(map[int64]*process)}
+}
+
+func (e *localExecutor) Submit(ctx context.Context, task *model.Task) error {
+	if errs := task.Info.Validate(field.NewPath("info"), model.ExecutorLocal); len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidTask, errs.ToAggregate())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if p, ok := e.processes[task.ID]; ok && p.attempt == task.Attempt {
+		return nil
+	}
+
+	// 进程生命周期与提交请求无关，不使用调用方的 ctx
+	baseCtx, stopTimeout := context.Background(), func() {}
+	if task.Info.ActiveDeadlineSeconds != nil {
+		baseCtx, stopTimeout = context.WithTimeout(baseCtx, time.Duration(*task.Info.ActiveDeadlineSeconds)*time.Second)
+	}
+	runCtx, cancel := context.WithCancelCause(baseCtx)
+
+	cmd := exec.CommandContext(runCtx, task.Info.Command[0], slices.Concat(task.Info.Command[1:], task.Info.Args)...)
+	// 输出写入内存而不是文件，Wait 需要等所有子进程关闭管道才会返回
+	// 因此需要终止整个进程组，并限制等待管道关闭的时间
+	setProcessGroup(cmd)
+	cmd.WaitDelay = localProcessWaitDelay
+	// 只传入任务指定的环境变量，避免泄露 nightwatch 进程的环境变量
+	cmd.Env = make([]string, 0, len(task.Info.Env))
+	for _, env := range task.Info.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	output := &tailBuffer{limit: maxLocalOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		cancel(nil)
+		stopTimeout()
+		return err
+	}
+
+	p := &process{
+		attempt: task.Attempt,
+		cmd:     cmd,
+		cancel:  cancel,
+		output:  output,
+		done:    make(chan struct{}),
+		state: State{
+			Status: model.TaskStatusRunning,
+			Name:   fmt.Sprintf("pid-%d", cmd.Process.Pid),
+		},
+	}
+	e.processes[task.ID] = p
+	slog.Info("Successfully started local process", "taskID", task.ID, "name", p.state.Name)
+
+	go func() {
+		defer close(p.done)
+		defer stopTimeout()
+		defer cancel(nil)
+		err := cmd.Wait()
+		// 清理进程退出后遗留的子进程
+		_ = killProcessGroup(cmd)
+
+		p.mu.Lock()
+		switch {
+		// ErrWaitDelay 表示进程已经成功退出，只是遗留的子进程没有及时关闭输出管道
+		case err == nil || errors.Is(err, exec.ErrWaitDelay):
+			p.state.Status = model.TaskStatusSucceeded
+			p.state.Message = "Completed"
+		case errors.Is(context.Cause(runCtx), errTerminated):
+			p.state.Status = model.TaskStatusFailed
+			p.state.Message = "Terminated: " + errTerminated.Error()
+		case errors.Is(context.Cause(runCtx), errCancelled):
+			p.state.Status = model.TaskStatusFailed
+			p.state.Message = "Cancelled: " + errCancelled.Error()
+		case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+			p.state.Status = model.TaskStatusFailed
+			p.state.Message = "DeadlineExceeded: process was active longer than specified deadline"
+		default:
+			p.state.Status = model.TaskStatusFailed
+			p.state.Message = "ProcessFailed: " + err.Error()
+		}
+		state := p.state
+		p.mu.Unlock()
+
+		e.emit(&Event{TaskID: task.ID, Attempt: p.attempt, State: state})
+		time.AfterFunc(localProcessRetention, func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if e.processes[task.ID] == p {
+				delete(e.processes, task.ID)
+			}
+		})
+	}()
+
+	return nil
+}
+
+func (e *localExecutor) Status(_ context.Context, task *model.Task) (*State, error) {
+	p, err := e.get(task)
+	if err != nil {
+		// 进程信息只保存在内存中，找不到说明进程不是由当前 nightwatch 启动的
+		// 之前的 leader 停止时已经终止了所有进程，与执行实例被清理一样返回 ErrNotFound，由 gcWatcher 按执行失败处理
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.state
+	return &state, nil
+}
+
+func (e *localExecutor) Cancel(ctx context.Context, task *model.Task) error {
+	p, err := e.get(task)
+	if err != nil {
+		// 进程不是由当前 nightwatch 启动的，之前的 leader 停止时已经终止了所有进程
+		return nil
+	}
+
+	select {
+	case <-p.done:
+		// 进程已经结束，避免向被复用的进程组发送信号
+		return nil
+	default:
+	}
+	p.cancel(errCancelled)
+	_ = killProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *localExecutor) Result(_ context.Context, task *model.Task) (*model.TaskResult, error) {
+	p, err := e.get(task)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-p.done:
+	default:
+		return nil, fmt.Errorf("local process of task %d attempt %d is still running", task.ID, task.Attempt)
+	}
+
+	p.mu.Lock()
+	state := p.state
+	p.mu.Unlock()
+
+	result := &model.TaskResult{
+		TaskID:  task.ID,
+		Attempt: task.Attempt,
+		Name:    state.Name,
+	}
+	result.Logs, result.LogsTruncated = p.output.Tail(model.MaxTaskResultLogLines)
+	if result.LogsTruncated {
+		result.Logs = model.TruncatedLogsMarker + result.Logs
+	}
+	result.Reason, result.Message, _ = strings.Cut(state.Message, ": ")
+	// 被信号终止时 ExitCode 返回 -1，视为没有退出码
+	if code := p.cmd.ProcessState.ExitCode(); code >= 0 {
+		exitCode := int32(code)
+		result.ExitCode = &exitCode
+	}
+	return result, nil
+}
+
+// TerminateAll 终止所有正在运行的进程，并等待进程结束的状态推送完成
+func (e *localExecutor) TerminateAll() {
+	e.mu.Lock()
+	var running []*process
+	for _, p := range e.processes {
+		p.mu.Lock()
+		if p.state.Status == model.TaskStatusRunning {
+			running = append(running, p)
+		}
+		p.mu.Unlock()
+	}
+	e.mu.Unlock()
+
+	for _, p := range running {
+		p.cancel(errTerminated)
+		_ = killProcessGroup(p.cmd)
+	}
+	for _, p := range running {
+		<-p.done
+	}
+	if len(running) > 0 {
+		slog.Info("Successfully terminated local processes", "count", len(running))
+	}
+}
+
+// Watch 注册 handler，进程结束时推送状态变化，此方法会阻塞直到 ctx 取消
+func (e *localExecutor) Watch(ctx context.Context, handler EventHandler) {
+	e.mu.Lock()
+	e.notify = func(event *Event) {
+		handler(ctx, event)
+	}
+	e.mu.Unlock()
+
+	<-ctx.Done()
+
+	e.mu.Lock()
+	e.notify = nil
+	e.mu.Unlock()
+}
+
+func (e *localExecutor) get(task *model.Task) (*process, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	p, ok := e.processes[task.ID]
+	if !ok || p.attempt != task.Attempt {
+		return nil, fmt.Errorf("%w: local process of task %d attempt %d", ErrNotFound, task.ID, task.Attempt)
+	}
+	return p, nil
+}
+
+func (e *localExecutor) emit(event *Event) {
+	e.mu.Lock()
+	notify := e.notify
+	e.mu.Unlock()
+
+	if notify != nil {
+		notify(event)
+	}
+}
+
+// tailBuffer 只保留最后 limit 字节的输出
+type tailBuffer struct {
+	mu      sync.Mutex
+	limit   int
+	buf     []byte
+	dropped bool // 是否丢弃过超出 limit 的输出
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+		b.dropped = true
+	}
+	return len(p), nil
+}
+
+// Tail 返回最后 lines 行输出，lines 小于等于 0 时返回全部输出
+// 第二个返回值表示是否丢弃了更早的输出，丢弃时缓冲区中可能不完整的第一行也不返回
+func (b *tailBuffer) Tail(lines int64) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := bytes.TrimRight(b.buf, "\n")
+	if lines <= 0 {
+		return b.head(), b.dropped
+	}
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			lines--
+			if lines == 0 {
+				return string(data[i+1:]) + "\n", true
+			}
+		}
+	}
+	return b.head(), b.dropped
+}
+
+// head 返回缓冲区中的全部输出，丢弃过输出时去掉不完整的第一行，调用方需要持有锁
+func (b *tailBuffer) head() string {
+	if !b.dropped {
+		return string(b.buf)
+	}
+	if i := bytes.IndexByte(b.buf, '\n'); i >= 0 && i < len(b.buf)-1 {
+		return string(b.buf[i+1:])
+	}
+	return string(b.buf)
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"cmp"
+	"strings"
+	"testing"
+)
+
+func TestTailBufferWrite(t *testing.T) {
+	b := &tailBuffer{limit: 8}
+	for _, s := range []string{"abc", "defgh", "ijk"} {
+		n, err := b.Write([]byte(s))
+		if err != nil || n != len(s) {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got := string(b.buf); got != "defghijk" {
+		t.Fatalf("buffer = %q, want %q", got, "defghijk")
+	}
+
+	// 单次写入超过上限时只保留最后 limit 字节
+	_, _ = b.Write([]byte(strings.Repeat("x", 10) + "12345678"))
+	if got := string(b.buf); got != "12345678" {
+		t.Fatalf("buffer = %q, want %q", got, "12345678")
+	}
+}
+
+func TestTailBufferTail(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		limit         int
+		lines         int64
+		want          string
+		wantTruncated bool
+	}{
+		{name: "empty", output: "", lines: 2, want: ""},
+		{name: "all lines", output: "a\nb\nc\n", lines: 0, want: "a\nb\nc\n"},
+		{name: "last line", output: "a\nb\nc\n", lines: 1, want: "c\n", wantTruncated: true},
+		{name: "last two lines", output: "a\nb\nc\n", lines: 2, want: "b\nc\n", wantTruncated: true},
+		{name: "exact lines", output: "a\nb\nc\n", lines: 3, want: "a\nb\nc\n"},
+		{name: "without trailing newline", output: "a\nb\nc", lines: 2, want: "b\nc\n", wantTruncated: true},
+		{name: "more lines than output", output: "a\nb\n", lines: 5, want: "a\nb\n"},
+		// 缓冲区丢弃了 aaa 和 bbb 的开头，不完整的行也不返回
+		{name: "dropped output", output: "aaa\nbbb\nc\nd\n", limit: 6, lines: 5, want: "c\nd\n", wantTruncated: true},
+		{name: "dropped output all lines", output: "aaa\nbbb\nc\nd\n", limit: 6, lines: 0, want: "c\nd\n", wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tailBuffer{limit: cmp.Or(tt.limit, 1024)}
+			_, _ = b.Write([]byte(tt.output))
+			if got, truncated := b.Tail(tt.lines); got != tt.want || truncated != tt.wantTruncated {
+				t.Fatalf("Tail(%d) = %q, %v, want %q, %v", tt.lines, got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
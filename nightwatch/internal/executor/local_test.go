@@ -0,0 +1,194 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// waitForStatus 等待本地进程结束，返回最终状态
+func waitForStatus(t *testing.T, e Executor, task *model.Task, timeout time.Duration) *State {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		state, err := e.Status(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if state.Status != model.TaskStatusRunning {
+			return state
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("task is still running after %s", timeout)
+	return nil
+}
+
+func TestLocalExecutorDeadlineKillsProcessGroup(t *testing.T) {
+	deadline := int64(1)
+	task := &model.Task{
+		ID:      1,
+		Attempt: 1,
+		Info: model.TaskInfo{
+			Command:               []string{"sh", "-c"},
+			Args:                  []string{"echo hello && sleep 5"},
+			ActiveDeadlineSeconds: &deadline,
+		},
+	}
+
+	e := NewLocal()
+	start := time.Now()
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	state := waitForStatus(t, e, task, 3*time.Second)
+	if state.Status != model.TaskStatusFailed || !strings.HasPrefix(state.Message, "DeadlineExceeded") {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("process group was not killed at deadline, took %s", elapsed)
+	}
+}
+
+func TestLocalExecutorEnv(t *testing.T) {
+	t.Setenv("NIGHTWATCH_TEST_SECRET", "secret")
+	task := &model.Task{
+		ID:      2,
+		Attempt: 1,
+		Info: model.TaskInfo{
+			Command: []string{"sh", "-c"},
+			Args:    []string{`test -z "$NIGHTWATCH_TEST_SECRET" && test "$GREETING" = hello`},
+			Env:     []model.EnvVar{{Name: "GREETING", Value: "hello"}},
+		},
+	}
+
+	e := NewLocal()
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if state := waitForStatus(t, e, task, 3*time.Second); state.Status != model.TaskStatusSucceeded {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+}
+
+func TestLocalExecutorTerminateAll(t *testing.T) {
+	task := &model.Task{
+		ID:      3,
+		Attempt: 1,
+		Info:    model.TaskInfo{Command: []string{"sh", "-c"}, Args: []string{"sleep 30"}},
+	}
+
+	e := NewLocal()
+	events := make(chan *Event, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.(EventSource).Watch(ctx, func(_ context.Context, event *Event) {
+		events <- event
+	})
+	// 等待 Watch 注册 handler
+	time.Sleep(50 * time.Millisecond)
+
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	start := time.Now()
+	e.(Terminator).TerminateAll()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("TerminateAll() took %s", elapsed)
+	}
+
+	select {
+	case event := <-events:
+		if event.TaskID != task.ID || event.Status != model.TaskStatusFailed || !strings.HasPrefix(event.Message, "Terminated") {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	default:
+		t.Fatal("TerminateAll() returned before the event was delivered")
+	}
+}
+
+func TestLocalExecutorLostProcessNotFound(t *testing.T) {
+	if _, err := NewLocal().Status(context.Background(), &model.Task{ID: 4, Attempt: 1}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Status() of lost process error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLocalExecutorResult(t *testing.T) {
+	task := &model.Task{
+		ID:      5,
+		Attempt: 1,
+		Info: model.TaskInfo{
+			Command: []string{"sh", "-c"},
+			Args:    []string{"for i in $(seq 1 300); do echo line-$i; done; exit 3"},
+		},
+	}
+
+	e := NewLocal()
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	waitForStatus(t, e, task, 3*time.Second)
+
+	result, err := e.Result(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	if result.ExitCode == nil || *result.ExitCode != 3 || result.Reason != "ProcessFailed" {
+		t.Fatalf("unexpected exit status: %+v", result)
+	}
+	logs, ok := strings.CutPrefix(result.Logs, model.TruncatedLogsMarker)
+	if !ok || !result.LogsTruncated {
+		t.Fatalf("logs of 300 lines were not marked as truncated: truncated = %v, logs = %.32q", result.LogsTruncated, result.Logs)
+	}
+	lines := strings.Split(strings.TrimSuffix(logs, "\n"), "\n")
+	if len(lines) != model.MaxTaskResultLogLines || lines[0] != "line-101" || lines[len(lines)-1] != "line-300" {
+		t.Fatalf("unexpected logs: %d lines, first %q", len(lines), lines[0])
+	}
+
+	if _, err := e.Result(context.Background(), &model.Task{ID: 5, Attempt: 2}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Result() of unknown attempt error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLocalExecutorCancel(t *testing.T) {
+	task := &model.Task{
+		ID:      6,
+		Attempt: 1,
+		Info:    model.TaskInfo{Command: []string{"sh", "-c"}, Args: []string{"sleep 30"}},
+	}
+
+	e := NewLocal()
+	if err := e.Submit(context.Background(), task); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := e.Cancel(ctx, task); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	state, err := e.Status(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if state.Status != model.TaskStatusFailed || !strings.HasPrefix(state.Message, "Cancelled") {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	// 重复取消以及取消不存在的进程都直接返回
+	if err := e.Cancel(ctx, task); err != nil {
+		t.Fatalf("Cancel() again error = %v", err)
+	}
+	if err := e.Cancel(ctx, &model.Task{ID: 6, Attempt: 2}); err != nil {
+		t.Fatalf("Cancel() of unknown attempt error = %v", err)
+	}
+}
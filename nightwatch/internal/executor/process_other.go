@@ -0,0 +1,16 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// setProcessGroup 非 unix 平台不支持进程组，取消时只终止直接创建的进程
+func setProcessGroup(*exec.Cmd) {}
+
+// killProcessGroup 终止进程
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package executor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让进程运行在独立的进程组中，取消时连同其创建的子进程一起终止
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+}
+
+// killProcessGroup 终止进程所在的整个进程组
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	// 负数 pid 表示向整个进程组发送信号
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,271 @@
+package nightwatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redsyncredis "github.com/go-redsync/redsync/v4/redis"
+	"gorm.io/gorm/logger"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/db"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+// fakeRedis 进程内的 Redis 替身，只实现 redsync 分布式锁使用的命令和脚本
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]fakeRedisValue
+}
+
+type fakeRedisValue struct {
+	value    string
+	expireAt time.Time // 零值表示不过期
+}
+
+var _ redsyncredis.Pool = (*fakeRedis)(nil)
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{data: make(map[string]fakeRedisValue)}
+}
+
+func (r *fakeRedis) Get(ctx context.Context) (redsyncredis.Conn, error) {
+	return &fakeRedisConn{r}, nil
+}
+
+// load 返回 name 对应的值，已经过期时删除，调用方需要持有锁
+func (r *fakeRedis) load(name string) (fakeRedisValue, bool) {
+	v, ok := r.data[name]
+	if ok && !v.expireAt.IsZero() && !time.Now().Before(v.expireAt) {
+		delete(r.data, name)
+		return fakeRedisValue{}, false
+	}
+	return v, ok
+}
+
+// value 返回 name 当前的值，不存在或已经过期时返回 false
+func (r *fakeRedis) value(name string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.load(name)
+	return v.value, ok
+}
+
+// expire 使 name 立即过期，模拟 leader 长时间无法续约
+func (r *fakeRedis) expire(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, name)
+}
+
+type fakeRedisConn struct {
+	r *fakeRedis
+}
+
+func (c *fakeRedisConn) Get(name string) (string, error) {
+	v, _ := c.r.value(name)
+	return v, nil
+}
+
+func (c *fakeRedisConn) Set(name string, value string) (bool, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.data[name] = fakeRedisValue{value: value}
+	return true, nil
+}
+
+func (c *fakeRedisConn) SetNX(name string, value string, expiry time.Duration) (bool, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	if _, ok := c.r.load(name); ok {
+		return false, nil
+	}
+	c.r.data[name] = fakeRedisValue{value: value, expireAt: time.Now().Add(expiry)}
+	return true, nil
+}
+
+func (c *fakeRedisConn) PTTL(name string) (time.Duration, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	v, ok := c.r.load(name)
+	switch {
+	case !ok:
+		return -2, nil
+	case v.expireAt.IsZero():
+		return -1, nil
+	}
+	return time.Until(v.expireAt), nil
+}
+
+// Eval 按脚本内容模拟 redsync 的释放锁和续约脚本
+func (c *fakeRedisConn) Eval(script *redsyncredis.Script, keysAndArgs ...any) (any, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+
+	name, value := keysAndArgs[0].(string), keysAndArgs[1].(string)
+	current, ok := c.r.load(name)
+	switch {
+	case strings.Contains(script.Src, `"DEL"`):
+		if !ok {
+			return int64(-1), nil
+		}
+		if current.value != value {
+			return int64(0), nil
+		}
+		delete(c.r.data, name)
+		return int64(1), nil
+	case strings.Contains(script.Src, `"PEXPIRE"`):
+		expiry := time.Duration(keysAndArgs[2].(int)) * time.Millisecond
+		if (ok && current.value == value) || (!ok && strings.Contains(script.Src, `"NX"`)) {
+			c.r.data[name] = fakeRedisValue{value: value, expireAt: time.Now().Add(expiry)}
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, fmt.Errorf("unsupported script: %s", script.Src)
+}
+
+func (c *fakeRedisConn) Close() error {
+	return nil
+}
+
+// testEnv 多个 nightWatch 实例共享的存储、K8s 集群和 Redis
+type testEnv struct {
+	store     store.IStore
+	clientset *fake.Clientset
+	redis     *fakeRedis
+	config    *watcher.Config
+}
+
+// newSQLiteStore 创建数据只保存在内存中的 SQLite 存储
+func newSQLiteStore(t *testing.T) store.IStore {
+	t.Helper()
+	gormDB, err := db.NewSQLite(&db.SQLiteOptions{Path: ":memory:", Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	s, err := store.NewSQLiteStore(gormDB)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	return s
+}
+
+// newTestEnv 使用存储 s 创建测试环境，缩短 taskWatcher 的执行周期
+func newTestEnv(t *testing.T, s store.IStore) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		store:     s,
+		clientset: fake.NewClientset(),
+		redis:     newFakeRedis(),
+	}
+	env.config = &watcher.Config{Store: env.store, Clientset: env.clientset}
+	err := env.store.Watchers().Save(context.Background(), &model.WatcherConfig{Name: "taskWatcher", Enabled: true, Spec: "@every 1s"})
+	if err != nil {
+		t.Fatalf("Failed to save watcher config: %v", err)
+	}
+	return env
+}
+
+// testInstance 运行中的 nightWatch 实例
+type testInstance struct {
+	nw     *nightWatch
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// newInstances 创建 n 个共享环境、缩短锁续约和竞争周期的实例，Watcher 为全局注册的单例，需要在启动任何实例之前全部创建
+func (env *testEnv) newInstances(t *testing.T, n int) []*testInstance {
+	t.Helper()
+	var instances []*testInstance
+	for range n {
+		cfg := &Config{
+			HTTPAddr: "127.0.0.1:0",
+			Lock:     LockOptions{Expiry: 600 * time.Millisecond, ExtendInterval: 200 * time.Millisecond, AcquireInterval: 50 * time.Millisecond},
+		}
+		nw, err := cfg.newNightWatch(env.config, env.redis)
+		if err != nil {
+			t.Fatalf("newNightWatch() error = %v", err)
+		}
+		instances = append(instances, &testInstance{nw: nw, stopCh: make(chan struct{}), done: make(chan struct{})})
+	}
+	return instances
+}
+
+// start 在后台运行实例，测试结束时自动退出
+func (i *testInstance) start(t *testing.T) {
+	t.Cleanup(i.shutdown)
+	go func() {
+		defer close(i.done)
+		i.nw.Run(i.stopCh)
+	}()
+}
+
+// shutdown 通知实例退出并等待 Run 返回，可以重复调用
+func (i *testInstance) shutdown() {
+	select {
+	case <-i.stopCh:
+	default:
+		close(i.stopCh)
+	}
+	<-i.done
+}
+
+// termCtx 返回实例当前任期的 ctx，不是 leader 时返回 nil
+func (i *testInstance) termCtx() context.Context {
+	i.nw.mu.Lock()
+	defer i.nw.mu.Unlock()
+	return i.nw.termCtx
+}
+
+// token 返回实例作为 leader 的 fencing token，不是 leader 时返回 false
+func (i *testInstance) token() (int64, bool) {
+	ctx := i.termCtx()
+	if ctx == nil || ctx.Err() != nil {
+		return 0, false
+	}
+	return store.FencingTokenFrom(ctx)
+}
+
+// waitFor 在 timeout 内轮询直到 cond 返回 true
+func waitFor(t *testing.T, timeout time.Duration, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", msg)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForLeader 等待 instances 中的某个实例成为 leader
+func waitForLeader(t *testing.T, instances ...*testInstance) (*testInstance, int64) {
+	t.Helper()
+	var (
+		leader *testInstance
+		token  int64
+	)
+	waitFor(t, 5*time.Second, "leader election", func() bool {
+		for _, i := range instances {
+			if tk, ok := i.token(); ok {
+				leader, token = i, tk
+				return true
+			}
+		}
+		return false
+	})
+	return leader, token
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "nightwatch"
+
+// 执行结果标签值
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// WatcherRuns Watcher 执行次数
+	WatcherRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_runs_total",
+		Help:      "Total number of watcher runs.",
+	}, []string{"watcher"})
+
+	// WatcherRunDuration Watcher 单次执行耗时
+	WatcherRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "watcher_run_duration_seconds",
+		Help:      "Duration of watcher runs in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"watcher"})
+
+	// WatcherSkips 上一次执行还未结束而跳过的次数
+	WatcherSkips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_skips_total",
+		Help:      "Total number of watcher runs skipped because the previous run is still running.",
+	}, []string{"watcher"})
+
+	// WatcherPanics Watcher 执行时发生 panic 的次数
+	WatcherPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_panics_total",
+		Help:      "Total number of panics recovered from watcher runs.",
+	}, []string{"watcher"})
+
+	// JobRequests 请求 K8s API Server 创建、查询、删除 Job 的次数
+	JobRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_requests_total",
+		Help:      "Total number of Kubernetes Job requests by operation and result.",
+	}, []string{"operation", "result"})
+
+	// Leader 当前实例是否持有分布式锁
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this instance holds the leader lock (1) or not (0).",
+	})
+
+	// FencingToken 当前实例最近一次获取锁时的 fencing token
+	FencingToken = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fencing_token",
+		Help:      "Fencing token of the latest leader term of this instance.",
+	})
+)
+
+// Result 根据 err 返回执行结果标签值
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
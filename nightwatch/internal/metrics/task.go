@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+// 采集任务数量时查询数据库的超时时间
+const collectTimeout = 5 * time.Second
+
+var taskCountDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "tasks"),
+	"Number of tasks by status.",
+	[]string{"status"}, nil,
+)
+
+// taskCollector 在采集时从数据库中统计各个状态的任务数量
+type taskCollector struct {
+	store store.IStore
+}
+
+// NewTaskCollector 创建按状态统计任务数量的 Collector
+func NewTaskCollector(store store.IStore) prometheus.Collector {
+	return &taskCollector{store: store}
+}
+
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- taskCountDesc
+}
+
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	counts, err := c.store.Tasks().CountByStatus(ctx)
+	if err != nil {
+		slog.Error("Failed to count tasks by status", "err", err)
+		ch <- prometheus.NewInvalidMetric(taskCountDesc, err)
+		return
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(taskCountDesc, prometheus.GaugeValue, float64(count), string(status))
+	}
+}
+
+// RegisterTaskCollector 在默认 Registry 中注册按状态统计任务数量的 Collector
+// 重复创建 nightwatch 时替换之前注册的 Collector，统计最新的 store
+func RegisterTaskCollector(store store.IStore) error {
+	collector := NewTaskCollector(store)
+	err := prometheus.Register(collector)
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) {
+		return err
+	}
+	prometheus.Unregister(are.ExistingCollector)
+	return prometheus.Register(collector)
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+func TestRegisterTaskCollector(t *testing.T) {
+	old := store.NewMemoryStore()
+	latest := store.NewMemoryStore()
+	if err := latest.Tasks().Create(context.Background(), &model.Task{Name: "task", Namespace: "demo", Status: model.TaskStatusNormal}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 同一进程中多次创建 nightwatch 时重复注册
+	for _, s := range []store.IStore{old, latest} {
+		if err := RegisterTaskCollector(s); err != nil {
+			t.Fatalf("RegisterTaskCollector() error = %v", err)
+		}
+	}
+
+	want := `
+# HELP nightwatch_tasks Number of tasks by status.
+# TYPE nightwatch_tasks gauge
+nightwatch_tasks{status="Normal"} 1
+`
+	if err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(want), "nightwatch_tasks"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,162 +1,455 @@
 package nightwatch
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/go-redsync/redsync/v4"
+	redsyncredis "github.com/go-redsync/redsync/v4/redis"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	"github.com/redis/go-redis/v9"
 	"github.com/robfig/cron/v3"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/apiserver"
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/metrics"
 	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/db"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
 	// 触发 init 函数
 	_ "github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher/all"
 )
 
-var (
-	lockName          = "nightwatch-lock"
-	jobStopTimeout    = 3 * time.Minute
-	extendExpiration  = 5 * time.Second
-	defaultExpiration = 2 * extendExpiration
+// 未配置时使用的默认值
+const (
+	DefaultLockName           = "nightwatch-lock"
+	DefaultLockExpiry         = 10 * time.Second
+	DefaultLockExtendInterval = 5 * time.Second
+	// 备用实例尝试获取锁的周期，leader 异常退出后，锁过期后的一个周期内即可接管
+	DefaultLockAcquireInterval = time.Second
+	DefaultJobStopTimeout      = 3 * time.Minute
+	// 重新加载 Watcher 配置的周期
+	DefaultReloadPeriod = 30 * time.Second
 )
 
+// LockOptions leader 选举使用的分布式锁配置，零值字段使用默认值
+type LockOptions struct {
+	// Redis 中锁的名称，同时作为 fencing token 的名称，同一个 Redis 中的多套 nightwatch 需要使用不同的名称
+	Name string
+	// 锁的过期时间，leader 异常退出后最多经过这段时间备用实例才能接管
+	Expiry time.Duration
+	// 锁的续约周期，需要小于过期时间
+	ExtendInterval time.Duration
+	// 备用实例尝试获取锁的周期
+	AcquireInterval time.Duration
+}
+
+// withDefaults 返回零值字段替换为默认值后的配置
+func (o LockOptions) withDefaults() LockOptions {
+	o.Name = cmp.Or(o.Name, DefaultLockName)
+	o.Expiry = cmp.Or(o.Expiry, DefaultLockExpiry)
+	o.ExtendInterval = cmp.Or(o.ExtendInterval, DefaultLockExtendInterval)
+	o.AcquireInterval = cmp.Or(o.AcquireInterval, DefaultLockAcquireInterval)
+	return o
+}
+
+// Validate 校验锁配置，零值字段视为使用默认值
+func (o LockOptions) Validate() error {
+	o = o.withDefaults()
+	if o.Expiry < 0 || o.ExtendInterval < 0 || o.AcquireInterval < 0 {
+		return errors.New("lock durations must not be negative")
+	}
+	if o.ExtendInterval >= o.Expiry {
+		return fmt.Errorf("lock extend interval %s must be less than expiry %s", o.ExtendInterval, o.Expiry)
+	}
+	return nil
+}
+
 // Watcher 管理器
 type nightWatch struct {
 	runner *cron.Cron     // 执行器
 	locker *redsync.Mutex // 分布式锁
 	config *watcher.Config
+	server *apiserver.Server // 任务管理 API
+
+	lock           LockOptions
+	jobStopTimeout time.Duration
+	reloadPeriod   time.Duration
+	specs          map[string]string // 部署时指定的 Watcher 默认定时周期
+
+	mu      sync.Mutex
+	entries map[string]watcherEntry // Watcher 名称到定时任务的映射
+	termCtx context.Context         // 当前 leader 任期的 ctx，携带 fencing token，未获取到锁时为 nil
 }
 
+// 存储后端
+const (
+	StorageMySQL  = "mysql"  // 默认使用 MySQL
+	StorageMemory = "memory" // 数据保存在进程内存中，重启后丢失，只用于本地运行和测试
+	StorageSQLite = "sqlite" // 数据保存在本地 SQLite 文件中，启动时自动建表，用于本地运行和单实例部署
+)
+
 // Config 配置信息，用于创建 nightWatch 对象
 type Config struct {
-	MySQLOptions *db.MySQLOptions
-	RedisOptions *db.RedisOptions
-	Clientset    kubernetes.Interface
+	// 存储后端，默认为 mysql，为 memory 或 sqlite 时不需要 MySQLOptions
+	Storage       string
+	MySQLOptions  *db.MySQLOptions
+	SQLiteOptions *db.SQLiteOptions
+	RedisOptions  *db.RedisOptions
+	Clientset     kubernetes.Interface
+	// 是否允许使用本地执行器，默认关闭
+	EnableLocalExecutor bool
+	// API Server 监听地址
+	HTTPAddr string
+	// 任务并发配额，默认不限制
+	TaskQuota model.TaskQuota
+	// 等待启动的任务的调度策略以及 fair-share 策略中各用户的权重
+	SchedulingPolicy string
+	UserWeights      map[int64]float64
+	// 分布式锁配置
+	Lock LockOptions
+	// 失去锁或退出时等待执行中的 Watcher 结束的最长时间，默认 3 分钟
+	JobStopTimeout time.Duration
+	// 重新加载表中 Watcher 配置的周期，默认 30 秒
+	ReloadPeriod time.Duration
+	// Watcher 名称到默认定时周期的映射，覆盖代码中的默认值，表中的配置优先级更高
+	WatcherSpecs map[string]string
 }
 
 // CreateWatcherConfig 创建 nightWatch 需要的配置
 func (c *Config) CreateWatcherConfig() (*watcher.Config, error) {
-	gormDB, err := db.NewMySQL(c.MySQLOptions)
+	datastore, err := c.newStore()
 	if err != nil {
-		slog.Error(err.Error(), "Failed to create MySQL client")
 		return nil, err
 	}
-	datastore := store.NewStore(gormDB)
 
-	return &watcher.Config{Store: datastore, Clientset: c.Clientset}, nil
+	return &watcher.Config{
+		Store:               datastore,
+		Clientset:           c.Clientset,
+		EnableLocalExecutor: c.EnableLocalExecutor,
+		TaskQuota:           c.TaskQuota,
+		SchedulingPolicy:    c.SchedulingPolicy,
+		UserWeights:         c.UserWeights,
+	}, nil
+}
+
+// storage 返回使用的存储后端名称
+func (c *Config) storage() string {
+	if c.Storage == "" {
+		return StorageMySQL
+	}
+	return c.Storage
+}
+
+// newStore 根据 Storage 创建存储
+func (c *Config) newStore() (store.IStore, error) {
+	switch c.storage() {
+	case StorageMySQL:
+		gormDB, err := db.NewMySQL(c.MySQLOptions)
+		if err != nil {
+			slog.Error("Failed to create MySQL client", "err", err)
+			return nil, err
+		}
+		return store.NewStore(gormDB), nil
+	case StorageMemory:
+		slog.Warn("Using in-memory storage, all data will be lost on exit")
+		return store.NewMemoryStore(), nil
+	case StorageSQLite:
+		gormDB, err := db.NewSQLite(c.SQLiteOptions)
+		if err != nil {
+			slog.Error("Failed to open SQLite database", "err", err)
+			return nil, err
+		}
+		return store.NewSQLiteStore(gormDB)
+	default:
+		return nil, fmt.Errorf("unsupported storage: %s", c.Storage)
+	}
 }
 
 // New 通过配置构造一个 nightWatch 对象
 func (c *Config) New() (*nightWatch, error) {
 	rdb, err := db.NewRedis(c.RedisOptions)
 	if err != nil {
-		slog.Error(err.Error(), "Failed to create Redis client")
+		slog.Error("Failed to create Redis client", "err", err)
+		return nil, err
+	}
+
+	cfg, err := c.CreateWatcherConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	nw, err := c.newNightWatch(cfg, goredis.NewPool(rdb), c.healthChecks(cfg, rdb)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := metrics.RegisterTaskCollector(cfg.Store); err != nil {
+		slog.Error("Failed to register task collector", "err", err)
+		return nil, err
+	}
+
+	return nw, nil
+}
+
+// newNightWatch 使用 pool 中的 Redis 连接作为分布式锁构造 nightWatch，测试中可以替换为进程内的实现
+func (c *Config) newNightWatch(cfg *watcher.Config, pool redsyncredis.Pool, checks ...apiserver.HealthCheck) (*nightWatch, error) {
+	if err := c.Lock.Validate(); err != nil {
 		return nil, err
 	}
+	lock := c.Lock.withDefaults()
 
 	logger := newCronLogger()
+	// 在 addWatchers 中为每个 Watcher 单独包装跳过和 panic 恢复逻辑，重新调度后仍然不会并发执行，并按 Watcher 记录指标
 	runner := cron.New(
-		cron.WithSeconds(),
+		cron.WithParser(watcher.Parser),
 		cron.WithLogger(logger),
-		cron.WithChain(cron.SkipIfStillRunning(logger), cron.Recover(logger)),
 	)
 
-	pool := goredis.NewPool(rdb)
 	lockOpts := []redsync.Option{
 		redsync.WithRetryDelay(50 * time.Microsecond),
 		redsync.WithTries(3),
-		redsync.WithExpiry(defaultExpiration),
+		redsync.WithExpiry(lock.Expiry),
 	}
-	locker := redsync.New(pool).NewMutex(lockName, lockOpts...)
+	locker := redsync.New(pool).NewMutex(lock.Name, lockOpts...)
 
-	cfg, err := c.CreateWatcherConfig()
-	if err != nil {
-		return nil, err
+	nw := &nightWatch{
+		runner:         runner,
+		locker:         locker,
+		config:         cfg,
+		lock:           lock,
+		jobStopTimeout: cmp.Or(c.JobStopTimeout, DefaultJobStopTimeout),
+		reloadPeriod:   cmp.Or(c.ReloadPeriod, DefaultReloadPeriod),
+		specs:          c.WatcherSpecs,
+		entries:        make(map[string]watcherEntry),
 	}
-
-	nw := &nightWatch{runner: runner, locker: locker, config: cfg}
-	if err := nw.addWatchers(); err != nil {
+	nw.server = apiserver.New(c.HTTPAddr, cfg.Store, cfg.Executors(), nw, checks...)
+	if err := nw.addWatchers(logger); err != nil {
 		return nil, err
 	}
 
 	return nw, nil
 }
 
-// 注册所有 Watcher 实例到 nightWatch
-func (nw *nightWatch) addWatchers() error {
-	for n, w := range watcher.ListWatchers() {
-		if err := w.Init(context.Background(), nw.config); err != nil {
-			slog.Error(err.Error(), "Failed to construct watcher", "watcher", n)
-			return err
+// healthChecks 返回 nightwatch 依赖的外部服务的健康检查
+func (c *Config) healthChecks(cfg *watcher.Config, rdb *redis.Client) []apiserver.HealthCheck {
+	checks := []apiserver.HealthCheck{
+		{Name: c.storage(), Check: cfg.Store.Ping},
+		{Name: "redis", Check: func(ctx context.Context) error { return rdb.Ping(ctx).Err() }},
+	}
+	if cfg.Clientset != nil {
+		checks = append(checks, apiserver.HealthCheck{Name: "kubernetes", Check: func(ctx context.Context) error {
+			return cfg.Clientset.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+		}})
+	}
+	return checks
+}
+
+// 注册所有 Watcher 实例到 nightWatch，先按默认配置调度，获取锁之前再从表中加载配置
+func (nw *nightWatch) addWatchers(logger cron.Logger) error {
+	watchers := watcher.ListWatchers()
+	for n := range nw.specs {
+		if _, ok := watchers[n]; !ok {
+			return fmt.Errorf("unknown watcher in watcher specs: %s", n)
 		}
+	}
 
-		spec := watcher.Every3Seconds
-		if obj, ok := w.(watcher.ISpec); ok {
-			spec = obj.Spec()
+	for n, w := range watchers {
+		if err := w.Init(context.Background(), nw.config); err != nil {
+			slog.Error("Failed to construct watcher", "err", err, "watcher", n)
+			return err
 		}
 
-		if _, err := nw.runner.AddJob(spec, w); err != nil {
-			slog.Error(err.Error(), "Failed to add job to the cron", "watcher", n)
+		if _, err := watcher.Parser.Parse(nw.defaultSpec(n, w)); err != nil {
+			slog.Error("Failed to parse watcher spec", "err", err, "watcher", n)
 			return err
 		}
+		chain := cron.NewChain(skipIfStillRunning(n, logger), recoverPanic(n, logger))
+		nw.entries[n] = watcherEntry{job: chain.Then(nw.job(n, w))}
 	}
+	nw.apply(nil)
 
 	return nil
 }
 
+// defaultSpec 返回 Watcher 没有表中配置时的定时周期，部署时指定的优先于代码中的默认值
+func (nw *nightWatch) defaultSpec(name string, w watcher.Watcher) string {
+	if spec := nw.specs[name]; spec != "" {
+		return spec
+	}
+	return watcher.DefaultSpec(w)
+}
+
+// job 将 Watcher 包装为 cron.Job，执行时传入当前任期的 ctx，任期已经结束时跳过
+func (nw *nightWatch) job(name string, w watcher.Watcher) cron.Job {
+	return cron.FuncJob(func() {
+		nw.mu.Lock()
+		ctx := nw.termCtx
+		nw.mu.Unlock()
+
+		if ctx == nil || ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			metrics.WatcherRuns.WithLabelValues(name).Inc()
+			metrics.WatcherRunDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		}()
+		w.Run(ctx)
+	})
+}
+
 // Run 执行异步任务，此方法会阻塞直到关闭 stopCh
 func (nw *nightWatch) Run(stopCh <-chan struct{}) {
 	ctx := wait.ContextForChannel(stopCh)
 
-	// 循环加锁，直到加锁成功，再去启动任务
-	ticker := time.NewTicker(defaultExpiration + (5 * time.Second))
-	defer ticker.Stop()
+	// API Server 不依赖分布式锁，每个实例都提供服务
+	go nw.server.Run(stopCh)
+
+	// 定期重新加载 Watcher 配置，未获取到锁的实例同样加载，以便获取锁后立即按最新配置执行
+	nw.reconcile(ctx)
+	go func() {
+		ticker := time.NewTicker(nw.reloadPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				nw.reconcile(ctx)
+			}
+		}
+	}()
+
+	// 失去锁后回到备用状态重新竞争，直到收到退出信号
 	for {
-		err := nw.locker.LockContext(ctx)
-		if err == nil {
-			slog.Info("Successfully acquired lock", "lockName", lockName)
-			break
+		token, ok := nw.acquire(ctx)
+		if !ok {
+			return
+		}
+		nw.lead(ctx, token)
+		if ctx.Err() != nil {
+			return
 		}
-		slog.Debug("Failed to acquire lock", "lockName", lockName, "err", err)
-		<-ticker.C
 	}
+}
 
-	// 看门狗，实现锁自动续约
-	ticker = time.NewTicker(extendExpiration)
+// acquire 循环加锁直到成功，并递增 fencing token，收到退出信号时返回 false
+func (nw *nightWatch) acquire(ctx context.Context) (int64, bool) {
+	ticker := time.NewTicker(nw.lock.AcquireInterval)
 	defer ticker.Stop()
-	go func() {
-		for {
-			<-ticker.C
-			if ok, err := nw.locker.ExtendContext(ctx); !ok || err != nil {
-				slog.Debug("Failed to extend lock", "err", err, "status", ok)
+	for {
+		if err := nw.locker.LockContext(ctx); err != nil {
+			slog.Debug("Failed to acquire lock", "lockName", nw.lock.Name, "err", err)
+		} else {
+			token, err := nw.config.Store.Fencing().Next(ctx, nw.lock.Name)
+			if err == nil {
+				slog.Info("Successfully acquired lock", "lockName", nw.lock.Name, "fencingToken", token)
+				return token, true
 			}
+			slog.Error("Failed to increase fencing token", "err", err)
+			nw.unlock()
 		}
-	}()
+
+		select {
+		case <-ctx.Done():
+			return 0, false
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead 以 leader 身份执行 Watcher，直到收到退出信号或锁续约失败
+func (nw *nightWatch) lead(ctx context.Context, token int64) {
+	// 任期 ctx 不随退出信号取消，使正常退出时执行中的任务仍能将状态写入表中
+	termCtx, cancel := context.WithCancel(store.WithFencingToken(context.Background(), nw.lock.Name, token))
+	nw.mu.Lock()
+	nw.termCtx = termCtx
+	nw.mu.Unlock()
+
+	// 启动后台常驻的 Watcher
+	var wg sync.WaitGroup
+	for n, w := range watcher.ListWatchers() {
+		if obj, ok := w.(watcher.IStarter); ok {
+			slog.Debug("Starting background watcher", "watcher", n)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				obj.Start(termCtx)
+			}()
+		}
+	}
 
 	// 启动定时任务
 	nw.runner.Start()
-	slog.Info("Successfully started nightwatch server")
+	metrics.Leader.Set(1)
+	metrics.FencingToken.Set(float64(token))
+	slog.Info("Successfully started nightwatch server", "fencingToken", token)
 
-	// 阻塞等待退出信号
-	<-stopCh
+	if nw.watchdog(ctx) {
+		// 锁可能已经被新的 leader 获取，立即结束任期，执行中的写入会因为 fencing token 过期而失败
+		slog.Error("Lost leadership, stop running watchers", "lockName", nw.lock.Name, "fencingToken", token)
+		cancel()
+	}
 
-	nw.stop()
+	nw.stop(cancel)
+	wg.Wait()
 }
 
-// 停止异步任务
-func (nw *nightWatch) stop() {
+// watchdog 实现锁自动续约，续约失败时返回 true，收到退出信号时返回 false
+func (nw *nightWatch) watchdog(ctx context.Context) bool {
+	ticker := time.NewTicker(nw.lock.ExtendInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if ok, err := nw.locker.ExtendContext(ctx); !ok || err != nil {
+				if ctx.Err() != nil {
+					return false
+				}
+				slog.Error("Failed to extend lock", "err", err, "status", ok)
+				return true
+			}
+		}
+	}
+}
+
+// 停止异步任务并结束当前任期
+func (nw *nightWatch) stop(cancel context.CancelFunc) {
 	ctx := nw.runner.Stop()
 	select {
 	case <-ctx.Done():
-	case <-time.After(jobStopTimeout):
-		slog.Error("Context was not done immediately", "timeout", jobStopTimeout.String())
+	case <-time.After(nw.jobStopTimeout):
+		slog.Error("Context was not done immediately", "timeout", nw.jobStopTimeout.String())
+	}
+
+	// 在停止后台常驻的 Watcher 之前终止执行，使执行结束的状态变化仍能同步到表中
+	for n, w := range watcher.ListWatchers() {
+		if obj, ok := w.(watcher.IStopper); ok {
+			slog.Debug("Stopping watcher", "watcher", n)
+			obj.Stop()
+		}
 	}
+	cancel()
+
+	nw.mu.Lock()
+	nw.termCtx = nil
+	nw.mu.Unlock()
+
+	metrics.Leader.Set(0)
+	nw.unlock()
+}
 
+func (nw *nightWatch) unlock() {
 	if ok, err := nw.locker.Unlock(); !ok || err != nil {
 		slog.Debug("Failed to unlock", "err", err, "status", ok)
 	}
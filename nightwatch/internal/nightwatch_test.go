@@ -0,0 +1,68 @@
+package nightwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+type fakeWatcher struct {
+	runs   int
+	tokens []int64
+}
+
+func (w *fakeWatcher) Init(ctx context.Context, config *watcher.Config) error { return nil }
+
+func (w *fakeWatcher) Run(ctx context.Context) {
+	w.runs++
+	if token, ok := store.FencingTokenFrom(ctx); ok {
+		w.tokens = append(w.tokens, token)
+	}
+}
+
+func TestJobRunsWithinTerm(t *testing.T) {
+	nw := &nightWatch{}
+	w := &fakeWatcher{}
+	job := nw.job("fakeWatcher", w)
+
+	// 未获取到锁时不执行
+	job.Run()
+	if w.runs != 0 {
+		t.Fatalf("watcher ran without leadership")
+	}
+
+	ctx, cancel := context.WithCancel(store.WithFencingToken(context.Background(), DefaultLockName, 7))
+	nw.termCtx = ctx
+	job.Run()
+	if w.runs != 1 || len(w.tokens) != 1 || w.tokens[0] != 7 {
+		t.Fatalf("runs = %d, tokens = %v, want 1 run with token 7", w.runs, w.tokens)
+	}
+
+	// 任期结束后即使 runner 还未停止也不再执行
+	cancel()
+	job.Run()
+	if w.runs != 1 {
+		t.Fatalf("watcher ran after the term was cancelled")
+	}
+}
+
+func TestLockOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    LockOptions
+		wantErr bool
+	}{
+		{name: "defaults", opts: LockOptions{}},
+		{name: "custom", opts: LockOptions{Name: "staging", Expiry: 30 * time.Second, ExtendInterval: 10 * time.Second}},
+		{name: "extend interval not less than expiry", opts: LockOptions{Expiry: 5 * time.Second}, wantErr: true},
+		{name: "negative", opts: LockOptions{AcquireInterval: -time.Second}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// feishuMessage 飞书群机器人的文本消息
+type feishuMessage struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Sign      string `json:"sign,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Content   struct {
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// feishuResult 飞书群机器人的响应，code 不为 0 时发送失败
+type feishuResult struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// FeishuSignature 返回飞书群机器人签名校验使用的签名：以 "时间戳\n密钥" 为密钥对空字符串计算 HMAC-SHA256 后进行 Base64 编码
+func FeishuSignature(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// feishuText 返回通知的文本内容
+func feishuText(p *model.NotificationPayload) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[nightwatch] Task %s/%s (ID %d) %s\n", p.Namespace, p.Name, p.TaskID, p.Status)
+	fmt.Fprintf(&b, "Attempt: %d\n", p.Attempt)
+	fmt.Fprintf(&b, "Reason: %s\n", p.Reason)
+	if p.Message != "" {
+		fmt.Fprintf(&b, "Message: %s\n", p.Message)
+	}
+	fmt.Fprintf(&b, "Finished at: %s", p.FinishedAt.Format(time.RFC3339))
+	return b.String()
+}
+
+// sendFeishu 将通知以文本消息发送到飞书群机器人
+func (s *Sender) sendFeishu(ctx context.Context, n *model.TaskNotification) error {
+	msg := feishuMessage{MsgType: "text"}
+	msg.Content.Text = feishuText(&n.Payload)
+	if n.Target.Secret != "" {
+		msg.Timestamp = s.timestamp()
+		msg.Sign = FeishuSignature(n.Target.Secret, msg.Timestamp)
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.post(ctx, n.Target.URL, body, nil)
+	if err != nil {
+		return err
+	}
+	var result feishuResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid feishu response: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("code: %d, error: %s", result.Code, result.Msg)
+	}
+	return nil
+}
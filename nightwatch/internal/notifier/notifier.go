@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 通知请求的超时时间
+const requestTimeout = 10 * time.Second
+
+// 发送失败时错误信息中最多保留的响应内容长度
+const maxErrorBody = 512
+
+// Sender 将任务通知发送到通知目标，按通知目标类型选择请求格式
+type Sender struct {
+	client *http.Client
+	// now 返回签名使用的当前时间，测试时替换
+	now func() time.Time
+}
+
+// NewSender 创建使用 client 发送请求的 Sender，client 为空时使用带超时的默认 client
+func NewSender(client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Sender{client: client, now: time.Now}
+}
+
+// Send 发送一条通知，通知目标返回非 2xx 状态码或业务错误时返回错误
+// 发送成功但记录结果失败时通知会被再次发送，接收方可以使用通知 ID 去重
+func (s *Sender) Send(ctx context.Context, n *model.TaskNotification) error {
+	switch n.Target.Type {
+	case model.NotifierWebhook:
+		return s.sendWebhook(ctx, n)
+	case model.NotifierFeishu:
+		return s.sendFeishu(ctx, n)
+	default:
+		return fmt.Errorf("unsupported notifier type %q", n.Target.Type)
+	}
+}
+
+// post 以 JSON 格式发送 body，返回 2xx 响应的内容
+func (s *Sender) post(ctx context.Context, url string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := model.TruncateHead(string(data), maxErrorBody)
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, msg)
+	}
+	return data, nil
+}
+
+func (s *Sender) timestamp() string {
+	return strconv.FormatInt(s.now().Unix(), 10)
+}
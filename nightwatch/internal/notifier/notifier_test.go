@@ -0,0 +1,141 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+var testNow = time.Unix(1767225600, 0)
+
+func newTestSender() *Sender {
+	s := NewSender(nil)
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+func newNotification(target model.NotificationTarget) *model.TaskNotification {
+	return &model.TaskNotification{
+		ID:     7,
+		TaskID: 1,
+		Target: target,
+		Payload: model.NotificationPayload{
+			TaskID:     1,
+			Name:       "demo",
+			Namespace:  "default",
+			Attempt:    1,
+			FromStatus: model.TaskStatusRunning,
+			Status:     model.TaskStatusFailed,
+			Reason:     model.TaskEventReasonStatusSynced,
+			Message:    "BackoffLimitExceeded",
+			FinishedAt: testNow,
+		},
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n := newNotification(model.NotificationTarget{Type: model.NotifierWebhook, URL: srv.URL, Secret: "s3cr3t"})
+	if err := newTestSender().Send(context.Background(), n); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var payload model.NotificationPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.TaskID != 1 || payload.Status != model.TaskStatusFailed {
+		t.Errorf("body = %s, %v", body, err)
+	}
+	if got := header.Get(HeaderDelivery); got != "7" {
+		t.Errorf("%s = %q, want 7", HeaderDelivery, got)
+	}
+	if got := header.Get(HeaderTimestamp); got != "1767225600" {
+		t.Errorf("%s = %q, want 1767225600", HeaderTimestamp, got)
+	}
+	if got, want := header.Get(HeaderSignature), WebhookSignature("s3cr3t", "1767225600", body); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if WebhookSignature("other", "1767225600", body) == header.Get(HeaderSignature) {
+		t.Errorf("signature does not depend on the secret")
+	}
+}
+
+func TestSendWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderSignature) != "" {
+			t.Errorf("request without secret is signed")
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	n := newNotification(model.NotificationTarget{Type: model.NotifierWebhook, URL: srv.URL})
+	err := newTestSender().Send(context.Background(), n)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Send() error = %v, want status code 503", err)
+	}
+}
+
+func TestSendFeishu(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		response string
+		wantErr  string
+	}{
+		{name: "success", response: `{"code":0,"msg":"success","data":{}}`},
+		{name: "signed", secret: "s3cr3t", response: `{"code":0,"msg":"success","data":{}}`},
+		{name: "business error", response: `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`, wantErr: "19021"},
+		{name: "invalid response", response: `not json`, wantErr: "invalid feishu response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg feishuMessage
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&msg)
+				_, _ = io.WriteString(w, tt.response)
+			}))
+			defer srv.Close()
+
+			n := newNotification(model.NotificationTarget{Type: model.NotifierFeishu, URL: srv.URL, Secret: tt.secret})
+			err := newTestSender().Send(context.Background(), n)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+			}
+
+			if msg.MsgType != "text" || !strings.Contains(msg.Content.Text, "default/demo (ID 1) Failed") {
+				t.Errorf("message = %+v", msg)
+			}
+			if tt.secret == "" && (msg.Timestamp != "" || msg.Sign != "") {
+				t.Errorf("message without secret is signed: %+v", msg)
+			}
+			if tt.secret != "" && (msg.Timestamp != "1767225600" || msg.Sign != FeishuSignature(tt.secret, msg.Timestamp)) {
+				t.Errorf("timestamp, sign = %q, %q", msg.Timestamp, msg.Sign)
+			}
+		})
+	}
+}
+
+func TestSendUnsupported(t *testing.T) {
+	n := newNotification(model.NotificationTarget{Type: "email", URL: "mailto:a@example.com"})
+	if err := newTestSender().Send(context.Background(), n); err == nil {
+		t.Errorf("Send() with unsupported type succeeded")
+	}
+}
@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// webhook 请求头
+const (
+	HeaderDelivery  = "X-Nightwatch-Delivery"  // 通知 ID，重试时不变，用于去重
+	HeaderTimestamp = "X-Nightwatch-Timestamp" // 发送时的 Unix 时间戳（秒）
+	HeaderSignature = "X-Nightwatch-Signature" // 配置密钥时的签名，格式为 sha256=<hex>
+)
+
+// WebhookSignature 返回 webhook 请求的签名：以密钥对 "时间戳.请求体" 计算 HMAC-SHA256
+// 接收方使用相同方式计算并比较签名，同时校验时间戳避免重放
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook 将通知内容以 JSON 格式发送到 webhook 地址
+func (s *Sender) sendWebhook(ctx context.Context, n *model.TaskNotification) error {
+	body, err := json.Marshal(n.Payload)
+	if err != nil {
+		return err
+	}
+
+	timestamp := s.timestamp()
+	header := http.Header{}
+	header.Set(HeaderDelivery, strconv.FormatInt(n.ID, 10))
+	header.Set(HeaderTimestamp, timestamp)
+	if n.Target.Secret != "" {
+		header.Set(HeaderSignature, WebhookSignature(n.Target.Secret, timestamp, body))
+	}
+	_, err = s.post(ctx, n.Target.URL, body, header)
+	return err
+}
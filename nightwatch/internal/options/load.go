@@ -0,0 +1,223 @@
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigFlag 指定配置文件路径的参数名称
+	ConfigFlag = "config"
+	// EnvPrefix 环境变量前缀，参数 mysql.host 对应环境变量 NIGHTWATCH_MYSQL_HOST
+	EnvPrefix = "NIGHTWATCH_"
+)
+
+// Load 解析 args 并按照 命令行参数 > 环境变量 > 配置文件 > 默认值 的优先级设置 fs 中的参数
+// 配置文件为 YAML 格式，键为参数名称，以 . 分隔的参数名称可以写为嵌套的键，不允许未知的键
+// 列表只能用于切片类型的参数，映射只能用于 user-weights、watcher.specs 这类 key=value 格式的参数，每个元素分别设置一次
+func Load(fs *pflag.FlagSet, args []string, lookupEnv func(string) (string, bool)) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		if v, ok := lookupEnv(EnvName(f.Name)); ok {
+			if e := fs.Set(f.Name, v); e != nil {
+				err = fmt.Errorf("invalid environment variable %s: %w", EnvName(f.Name), e)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	f := fs.Lookup(ConfigFlag)
+	if f == nil || f.Value.String() == "" {
+		return nil
+	}
+	values, err := readConfigFile(f.Value.String())
+	if err != nil {
+		return err
+	}
+	// 命令行参数和环境变量已经设置的参数不再使用配置文件中的值
+	changed := make(map[string]bool)
+	fs.Visit(func(f *pflag.Flag) { changed[f.Name] = true })
+	return setValues(fs, changed, "", values)
+}
+
+// EnvName 返回参数对应的环境变量名称
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flag))
+}
+
+// readConfigFile 读取 YAML 配置文件，数字保留原始格式
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// setValues 将配置文件中 prefix 下的值设置到对应的参数，不是参数名称的映射继续展开
+func setValues(fs *pflag.FlagSet, changed map[string]bool, prefix string, values map[string]any) error {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		name, value := prefix+key, values[key]
+		if fs.Lookup(name) == nil {
+			nested, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("unknown option in config file: %s", name)
+			}
+			if err := setValues(fs, changed, name+".", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if changed[name] || value == nil {
+			continue
+		}
+
+		var items []string
+		switch v := value.(type) {
+		case []any:
+			if _, ok := fs.Lookup(name).Value.(pflag.SliceValue); !ok {
+				return fmt.Errorf("invalid %s in config file: list is not allowed", name)
+			}
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		case map[string]any:
+			if !isMapValue(fs.Lookup(name).Value) {
+				return fmt.Errorf("invalid %s in config file: map is not allowed", name)
+			}
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				items = append(items, k+"="+fmt.Sprint(v[k]))
+			}
+		default:
+			items = []string{fmt.Sprint(v)}
+		}
+		for _, item := range items {
+			if err := fs.Set(name, item); err != nil {
+				return fmt.Errorf("invalid %s in config file: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isMapValue 返回参数是否以 key=value 格式设置映射中的元素
+func isMapValue(v pflag.Value) bool {
+	switch v.(type) {
+	case weightsValue, specsValue:
+		return true
+	}
+	return false
+}
+
+// levelValue 以 debug、info、warn、error 设置的日志级别参数
+type levelValue slog.Level
+
+func (v *levelValue) String() string {
+	return strings.ToLower((*slog.Level)(v).String())
+}
+
+func (v *levelValue) Set(s string) error {
+	return (*slog.Level)(v).UnmarshalText([]byte(s))
+}
+
+func (v *levelValue) Type() string {
+	return "level"
+}
+
+// weightsValue 以 userID=weight 格式设置的用户权重参数，多个权重以逗号分隔
+type weightsValue map[int64]float64
+
+func (v weightsValue) String() string {
+	var pairs []string
+	for _, userID := range slices.Sorted(maps.Keys(v)) {
+		pairs = append(pairs, fmt.Sprintf("%d=%s", userID, strconv.FormatFloat(v[userID], 'f', -1, 64)))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v weightsValue) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		user, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("invalid user weight: %s", pair)
+		}
+		userID, err := strconv.ParseInt(user, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid user id: %w", err)
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || w <= 0 {
+			return fmt.Errorf("invalid weight of user %d: %s", userID, weight)
+		}
+		v[userID] = w
+	}
+	return nil
+}
+
+func (v weightsValue) Type() string {
+	return "weights"
+}
+
+// specsValue 以 name=spec 格式设置的 Watcher 定时周期参数，cron 表达式中包含逗号，多个定时周期以分号分隔
+type specsValue map[string]string
+
+func (v specsValue) String() string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		pairs = append(pairs, name+"="+v[name])
+	}
+	return strings.Join(pairs, ";")
+}
+
+func (v specsValue) Set(s string) error {
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(pair, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid watcher spec: %s", pair)
+		}
+		// spec 为空时使用代码中的默认值
+		if spec == "" {
+			delete(v, name)
+			continue
+		}
+		v[name] = spec
+	}
+	return nil
+}
+
+func (v specsValue) Type() string {
+	return "specs"
+}
@@ -0,0 +1,176 @@
+// Package options 定义 nightwatch 的所有配置项，支持从配置文件、环境变量和命令行参数加载
+package options
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+
+	nightwatch "github.com/jianghushinian/blog-go-example/nightwatch/internal"
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/db"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// Options nightwatch 的配置项，每个字段对应一个命令行参数，配置文件和环境变量以参数名称指定
+type Options struct {
+	// 配置文件路径
+	ConfigFile string
+	// 日志级别
+	LogLevel slog.Level
+	// kubeconfig 路径，无法连接 K8s 集群时只能使用本地执行器
+	Kubeconfig string
+	// API Server 监听地址
+	HTTPAddr string
+	// 存储后端，mysql、sqlite 或 memory
+	Storage string
+	MySQL   *db.MySQLOptions
+	SQLite  *db.SQLiteOptions
+	Redis   *db.RedisOptions
+	// 是否允许使用本地执行器
+	EnableLocalExecutor bool
+	// 任务并发配额
+	TaskQuota model.TaskQuota
+	// 等待启动的任务的调度策略以及 fair-share 策略中各用户的权重
+	SchedulingPolicy string
+	UserWeights      map[int64]float64
+	// 分布式锁配置
+	Lock nightwatch.LockOptions
+	// 失去锁或退出时等待执行中的 Watcher 结束的最长时间
+	JobStopTimeout time.Duration
+	// 重新加载表中 Watcher 配置的周期
+	ReloadPeriod time.Duration
+	// Watcher 名称到默认定时周期的映射
+	WatcherSpecs map[string]string
+}
+
+// NewOptions 返回默认配置
+func NewOptions() *Options {
+	o := &Options{
+		LogLevel:         slog.LevelDebug,
+		HTTPAddr:         ":8080",
+		Storage:          nightwatch.StorageMySQL,
+		MySQL:            db.NewMySQLOptions(),
+		SQLite:           db.NewSQLiteOptions(),
+		Redis:            db.NewRedisOptions(),
+		SchedulingPolicy: "fair-share",
+		UserWeights:      make(map[int64]float64),
+		Lock: nightwatch.LockOptions{
+			Name:            nightwatch.DefaultLockName,
+			Expiry:          nightwatch.DefaultLockExpiry,
+			ExtendInterval:  nightwatch.DefaultLockExtendInterval,
+			AcquireInterval: nightwatch.DefaultLockAcquireInterval,
+		},
+		JobStopTimeout: nightwatch.DefaultJobStopTimeout,
+		ReloadPeriod:   nightwatch.DefaultReloadPeriod,
+		WatcherSpecs:   make(map[string]string),
+	}
+	if home := homedir.HomeDir(); home != "" {
+		o.Kubeconfig = filepath.Join(home, ".kube", "config")
+	}
+	return o
+}
+
+// AddFlags 将所有配置项注册为命令行参数
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.ConfigFile, ConfigFlag, "c", o.ConfigFile, "Path to the YAML config file, keys are the flag names, e.g. mysql: {host: 127.0.0.1:3306}")
+	fs.Var((*levelValue)(&o.LogLevel), "log-level", "Log level, one of debug, info, warn or error")
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig, "Path to kubeconfig, kubernetes executor is not available if the cluster cannot be connected")
+	fs.StringVar(&o.HTTPAddr, "http-addr", o.HTTPAddr, "Address the API server listens on")
+	fs.StringVar(&o.Storage, "storage", o.Storage, "Storage backend, one of mysql, sqlite or memory, memory storage loses all data on exit")
+	o.MySQL.AddFlags(fs)
+	o.SQLite.AddFlags(fs)
+	o.Redis.AddFlags(fs)
+
+	fs.BoolVar(&o.EnableLocalExecutor, "enable-local-executor", o.EnableLocalExecutor, "Allow tasks to run as local processes on the nightwatch host, only enable it in trusted environments")
+	fs.Int64Var(&o.TaskQuota.MaxRunning, "max-running-tasks", o.TaskQuota.MaxRunning, "Maximum number of tasks running at the same time, 0 means unlimited")
+	fs.Int64Var(&o.TaskQuota.MaxRunningPerUser, "max-running-tasks-per-user", o.TaskQuota.MaxRunningPerUser, "Maximum number of tasks of a user running at the same time, 0 means unlimited")
+	fs.Int64Var(&o.TaskQuota.MaxRunningPerNamespace, "max-running-tasks-per-namespace", o.TaskQuota.MaxRunningPerNamespace, "Maximum number of tasks in a namespace running at the same time, 0 means unlimited")
+	fs.StringVar(&o.SchedulingPolicy, "scheduling-policy", o.SchedulingPolicy, "Order to start waiting tasks in, one of fifo, priority or fair-share")
+	fs.Var(weightsValue(o.UserWeights), "user-weights", "Comma separated user weights of the fair-share policy, e.g. 1=2,3=0.5, users not listed have weight 1")
+
+	fs.StringVar(&o.Lock.Name, "lock.name", o.Lock.Name, "Name of the distributed lock for leader election, instances sharing a Redis must use different names for different deployments")
+	fs.DurationVar(&o.Lock.Expiry, "lock.expiry", o.Lock.Expiry, "Expiry of the lock, a standby instance takes over at most this long after the leader crashed")
+	fs.DurationVar(&o.Lock.ExtendInterval, "lock.extend-interval", o.Lock.ExtendInterval, "Interval the leader extends the lock at, must be less than lock.expiry")
+	fs.DurationVar(&o.Lock.AcquireInterval, "lock.acquire-interval", o.Lock.AcquireInterval, "Interval standby instances try to acquire the lock at")
+	fs.DurationVar(&o.JobStopTimeout, "watcher.stop-timeout", o.JobStopTimeout, "Maximum time to wait for running watchers when losing the lock or exiting")
+	fs.DurationVar(&o.ReloadPeriod, "watcher.reload-period", o.ReloadPeriod, "Interval to reload watcher configs from the database")
+	fs.Var(specsValue(o.WatcherSpecs), "watcher.specs", "Default spec of a watcher in name=spec format, can be repeated or separated by semicolons, e.g. taskWatcher=@every 10s")
+}
+
+// Validate 校验配置，返回所有不合法的配置项
+func (o *Options) Validate() error {
+	var errs []error
+	if o.HTTPAddr == "" {
+		errs = append(errs, errors.New("http-addr is required"))
+	}
+	switch o.Storage {
+	case nightwatch.StorageMySQL:
+		errs = append(errs, o.MySQL.Validate())
+	case nightwatch.StorageSQLite:
+		errs = append(errs, o.SQLite.Validate())
+	case nightwatch.StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported storage: %s", o.Storage))
+	}
+	// Redis 用于分布式锁，任何存储后端都需要
+	errs = append(errs, o.Redis.Validate(), o.Lock.Validate())
+	if o.TaskQuota.MaxRunning < 0 || o.TaskQuota.MaxRunningPerUser < 0 || o.TaskQuota.MaxRunningPerNamespace < 0 {
+		errs = append(errs, errors.New("task quotas must not be negative"))
+	}
+	if o.JobStopTimeout < 0 {
+		errs = append(errs, errors.New("watcher.stop-timeout must not be negative"))
+	}
+	if o.ReloadPeriod < 0 {
+		errs = append(errs, errors.New("watcher.reload-period must not be negative"))
+	}
+	for name, spec := range o.WatcherSpecs {
+		if _, err := watcher.Parser.Parse(spec); err != nil {
+			errs = append(errs, fmt.Errorf("invalid spec of watcher %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Config 根据配置创建 nightwatch 的 Config，无法连接 K8s 集群时仍然可以使用本地执行器运行任务
+func (o *Options) Config() *nightwatch.Config {
+	cfg := &nightwatch.Config{
+		Storage:             o.Storage,
+		MySQLOptions:        o.MySQL,
+		SQLiteOptions:       o.SQLite,
+		RedisOptions:        o.Redis,
+		EnableLocalExecutor: o.EnableLocalExecutor,
+		HTTPAddr:            o.HTTPAddr,
+		TaskQuota:           o.TaskQuota,
+		SchedulingPolicy:    o.SchedulingPolicy,
+		UserWeights:         o.UserWeights,
+		Lock:                o.Lock,
+		JobStopTimeout:      o.JobStopTimeout,
+		ReloadPeriod:        o.ReloadPeriod,
+		WatcherSpecs:        o.WatcherSpecs,
+	}
+	clientset, err := newClientset(o.Kubeconfig)
+	if err != nil {
+		slog.Warn("Failed to create kubernetes clientset, kubernetes executor is not available", "err", err)
+		return cfg
+	}
+	cfg.Clientset = clientset
+	return cfg
+}
+
+func newClientset(kubecfg string) (*kubernetes.Clientset, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubecfg)
+	if err != nil {
+		return nil, err
+	}
+	config.QPS = 50
+	config.Burst = 100
+	return kubernetes.NewForConfig(config)
+}
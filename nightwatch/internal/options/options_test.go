@@ -0,0 +1,171 @@
+package options
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// load 使用 env 中的环境变量和 config 配置文件加载参数
+func load(t *testing.T, config string, env map[string]string, args ...string) (*Options, error) {
+	t.Helper()
+	if config != "" {
+		path := filepath.Join(t.TempDir(), "nightwatch.yaml")
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, "--config", path)
+	}
+	opts := NewOptions()
+	fs := pflag.NewFlagSet("nightwatch", pflag.ContinueOnError)
+	opts.AddFlags(fs)
+	err := Load(fs, args, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	return opts, err
+}
+
+func TestLoad(t *testing.T) {
+	config := `
+log-level: info
+storage: memory
+mysql:
+  host: mysql:3306
+  max-connection-life-time: 1m
+redis:
+  addr: redis:6379
+  pool-size: 20
+lock.name: from-file
+lock:
+  expiry: 30s
+max-running-tasks: 1000000
+user-weights:
+  1: 2
+  3: 0.5
+watcher:
+  specs:
+    taskWatcher: "@every 10s"
+    scheduleWatcher: "0 0,30 * * * *"
+`
+	env := map[string]string{
+		"NIGHTWATCH_REDIS_ADDR":           "redis-env:6379",
+		"NIGHTWATCH_LOCK_NAME":            "from-env",
+		"NIGHTWATCH_LOCK_EXTEND_INTERVAL": "15s",
+	}
+	opts, err := load(t, config, env, "--lock.name=from-flag", "--watcher.specs", "notificationWatcher=@every 5s")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if opts.LogLevel != slog.LevelInfo || opts.Storage != "memory" || opts.HTTPAddr != ":8080" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if opts.MySQL.Host != "mysql:3306" || opts.MySQL.MaxConnectionLifeTime != time.Minute || opts.MySQL.Database != "nightwatch" {
+		t.Fatalf("unexpected mysql options: %+v", opts.MySQL)
+	}
+	// 环境变量优先于配置文件，命令行参数优先于环境变量
+	if opts.Redis.Addr != "redis-env:6379" || opts.Redis.PoolSize != 20 {
+		t.Fatalf("unexpected redis options: %+v", opts.Redis)
+	}
+	if opts.Lock.Name != "from-flag" || opts.Lock.Expiry != 30*time.Second || opts.Lock.ExtendInterval != 15*time.Second {
+		t.Fatalf("unexpected lock options: %+v", opts.Lock)
+	}
+	if opts.TaskQuota.MaxRunning != 1000000 {
+		t.Fatalf("max running tasks = %d", opts.TaskQuota.MaxRunning)
+	}
+	if want := map[int64]float64{1: 2, 3: 0.5}; !maps.Equal(opts.UserWeights, want) {
+		t.Fatalf("user weights = %v, want %v", opts.UserWeights, want)
+	}
+	// 命令行参数指定后不再使用配置文件中的值
+	if want := map[string]string{"notificationWatcher": "@every 5s"}; !maps.Equal(opts.WatcherSpecs, want) {
+		t.Fatalf("watcher specs = %v, want %v", opts.WatcherSpecs, want)
+	}
+}
+
+func TestLoadConfigFileSpecs(t *testing.T) {
+	opts, err := load(t, "watcher:\n  specs:\n    taskWatcher: \"@every 10s\"\n    scheduleWatcher: \"0 0,30 * * * *\"\n", nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := map[string]string{"taskWatcher": "@every 10s", "scheduleWatcher": "0 0,30 * * * *"}
+	if !maps.Equal(opts.WatcherSpecs, want) {
+		t.Fatalf("watcher specs = %v, want %v", opts.WatcherSpecs, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown key", config: "mysql:\n  hostname: mysql\n", wantErr: "unknown option in config file: mysql.hostname"},
+		{name: "map of scalar option", config: "lock:\n  name:\n    value: x\n", wantErr: "invalid lock.name"},
+		{name: "list of scalar option", config: "http-addr: [\":8080\"]\n", wantErr: "invalid http-addr"},
+		{name: "invalid duration", config: "lock:\n  expiry: 10\n", wantErr: "invalid lock.expiry"},
+		{name: "invalid yaml", config: "mysql: [\n", wantErr: "invalid config file"},
+		{name: "invalid env", env: map[string]string{"NIGHTWATCH_REDIS_POOL_SIZE": "ten"}, wantErr: "NIGHTWATCH_REDIS_POOL_SIZE"},
+		{name: "invalid flag", args: []string{"--user-weights=1=0"}, wantErr: "invalid weight of user 1"},
+		{name: "missing config file", args: []string{"--config", "/nonexistent/nightwatch.yaml"}, wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		_, err := load(t, tt.config, tt.env, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: Load() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	opts, err := load(t, "", nil, "--config", "../../assets/nightwatch.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(o *Options)
+		wantErr bool
+	}{
+		{name: "defaults", modify: func(o *Options) {}},
+		{name: "unsupported storage", modify: func(o *Options) { o.Storage = "postgres" }, wantErr: true},
+		{name: "missing sqlite path", modify: func(o *Options) { o.Storage = "sqlite"; o.SQLite.Path = "" }, wantErr: true},
+		{name: "sqlite storage ignores mysql", modify: func(o *Options) { o.Storage = "sqlite"; o.MySQL.Host = "" }},
+		{name: "missing mysql host", modify: func(o *Options) { o.MySQL.Host = "" }, wantErr: true},
+		{name: "memory storage ignores mysql", modify: func(o *Options) { o.Storage = "memory"; o.MySQL.Host = "" }},
+		{name: "missing redis addr", modify: func(o *Options) { o.Redis.Addr = "" }, wantErr: true},
+		{name: "extend interval not less than expiry", modify: func(o *Options) { o.Lock.ExtendInterval = o.Lock.Expiry }, wantErr: true},
+		{name: "negative quota", modify: func(o *Options) { o.TaskQuota.MaxRunningPerUser = -1 }, wantErr: true},
+		{name: "invalid watcher spec", modify: func(o *Options) { o.WatcherSpecs["taskWatcher"] = "every 10s" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		opts := NewOptions()
+		tt.modify(opts)
+		if err := opts.Validate(); (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("mysql.max-idle-connections"); got != "NIGHTWATCH_MYSQL_MAX_IDLE_CONNECTIONS" {
+		t.Fatalf("EnvName() = %s", got)
+	}
+}
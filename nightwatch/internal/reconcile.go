@@ -0,0 +1,97 @@
+package nightwatch
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// watcherEntry 记录 Watcher 在 runner 中的定时任务，未启用时 id 为 0
+type watcherEntry struct {
+	job     cron.Job
+	id      cron.EntryID
+	enabled bool
+	spec    string
+}
+
+// reconcile 从表中加载 Watcher 配置并应用到 runner，加载失败时保持当前配置
+func (nw *nightWatch) reconcile(ctx context.Context) {
+	configs, err := nw.config.Store.Watchers().List(ctx)
+	if err != nil {
+		slog.Error("Failed to load watcher configs", "err", err)
+		return
+	}
+	nw.apply(configs)
+}
+
+// apply 按照配置添加、移除或重新调度 runner 中的定时任务，没有配置的 Watcher 使用默认配置
+func (nw *nightWatch) apply(configs []*model.WatcherConfig) {
+	byName := make(map[string]*model.WatcherConfig, len(configs))
+	for _, c := range configs {
+		byName[c.Name] = c
+	}
+
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	for n, w := range watcher.ListWatchers() {
+		enabled, spec := true, nw.defaultSpec(n, w)
+		if c, ok := byName[n]; ok {
+			enabled = c.Enabled
+			if c.Spec != "" {
+				spec = c.Spec
+			}
+		}
+
+		entry := nw.entries[n]
+		switch {
+		case !enabled:
+			if entry.enabled {
+				nw.runner.Remove(entry.id)
+				slog.Info("Disabled watcher", "watcher", n)
+			}
+			entry.id, entry.enabled, entry.spec = 0, false, spec
+		case entry.enabled && entry.spec == spec:
+			continue
+		default:
+			// 先添加再移除，新的定时周期无效时保留原来的定时任务
+			id, err := nw.runner.AddJob(spec, entry.job)
+			if err != nil {
+				slog.Error("Invalid watcher spec, keep the current schedule", "err", err, "watcher", n, "spec", spec)
+				continue
+			}
+			if entry.enabled {
+				nw.runner.Remove(entry.id)
+			}
+			slog.Info("Scheduled watcher", "watcher", n, "spec", spec)
+			entry.id, entry.enabled, entry.spec = id, true, spec
+		}
+		nw.entries[n] = entry
+	}
+}
+
+// Watchers 返回所有 Watcher 当前的调度状态，按名称排序
+func (nw *nightWatch) Watchers() []watcher.Status {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	ret := make([]watcher.Status, 0, len(nw.entries))
+	for n, entry := range nw.entries {
+		status := watcher.Status{Name: n, Enabled: entry.enabled, Spec: entry.spec}
+		if entry.enabled {
+			e := nw.runner.Entry(entry.id)
+			status.Next, status.Prev = e.Next, e.Prev
+		}
+		ret = append(ret, status)
+	}
+	slices.SortFunc(ret, func(a, b watcher.Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return ret
+}
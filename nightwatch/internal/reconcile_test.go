@@ -0,0 +1,93 @@
+package nightwatch
+
+import (
+	"testing"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func newTestNightWatch() *nightWatch {
+	nw := &nightWatch{runner: cron.New(cron.WithParser(watcher.Parser)), entries: make(map[string]watcherEntry)}
+	for n, w := range watcher.ListWatchers() {
+		nw.entries[n] = watcherEntry{job: nw.job(n, w)}
+	}
+	return nw
+}
+
+func TestApply(t *testing.T) {
+	nw := newTestNightWatch()
+	nw.apply(nil)
+	if got, want := len(nw.runner.Entries()), len(watcher.ListWatchers()); got != want {
+		t.Fatalf("entries = %d, want %d", got, want)
+	}
+	entry := nw.entries["scheduleWatcher"]
+	if !entry.enabled || entry.spec != watcher.Every3Seconds {
+		t.Fatalf("unexpected default entry: %+v", entry)
+	}
+
+	tests := []struct {
+		name        string
+		config      *model.WatcherConfig
+		wantEnabled bool
+		wantSpec    string
+		wantNewID   bool
+	}{
+		{name: "unchanged", config: &model.WatcherConfig{Name: "scheduleWatcher", Enabled: true}, wantEnabled: true, wantSpec: watcher.Every3Seconds},
+		{name: "reschedule", config: &model.WatcherConfig{Name: "scheduleWatcher", Enabled: true, Spec: "@every 10s"}, wantEnabled: true, wantSpec: "@every 10s", wantNewID: true},
+		{name: "invalid spec keeps current", config: &model.WatcherConfig{Name: "scheduleWatcher", Enabled: true, Spec: "every 10s"}, wantEnabled: true, wantSpec: "@every 10s"},
+		{name: "disable", config: &model.WatcherConfig{Name: "scheduleWatcher", Enabled: false}, wantSpec: watcher.Every3Seconds},
+		{name: "enable", config: &model.WatcherConfig{Name: "scheduleWatcher", Enabled: true}, wantEnabled: true, wantSpec: watcher.Every3Seconds, wantNewID: true},
+	}
+	for _, tt := range tests {
+		before := nw.entries["scheduleWatcher"]
+		nw.apply([]*model.WatcherConfig{tt.config})
+		after := nw.entries["scheduleWatcher"]
+		if after.enabled != tt.wantEnabled || after.spec != tt.wantSpec {
+			t.Fatalf("%s: entry = %+v, want enabled %v spec %q", tt.name, after, tt.wantEnabled, tt.wantSpec)
+		}
+		if (after.id != before.id) != tt.wantNewID && after.enabled {
+			t.Fatalf("%s: entry id changed from %d to %d", tt.name, before.id, after.id)
+		}
+		// runner 中只保留启用的 Watcher
+		enabled := 0
+		for _, e := range nw.entries {
+			if e.enabled {
+				enabled++
+				if !nw.runner.Entry(e.id).Valid() {
+					t.Fatalf("%s: entry %d is not in runner", tt.name, e.id)
+				}
+			}
+		}
+		if got := len(nw.runner.Entries()); got != enabled {
+			t.Fatalf("%s: runner entries = %d, want %d", tt.name, got, enabled)
+		}
+	}
+
+	for _, status := range nw.Watchers() {
+		if status.Name == "scheduleWatcher" && (!status.Enabled || status.Spec != watcher.Every3Seconds) {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	}
+}
+
+func TestApplyDeploymentSpec(t *testing.T) {
+	nw := newTestNightWatch()
+	nw.specs = map[string]string{"scheduleWatcher": "@every 1m"}
+	nw.apply(nil)
+	if entry := nw.entries["scheduleWatcher"]; entry.spec != "@every 1m" {
+		t.Fatalf("spec = %q, want the deployment spec", entry.spec)
+	}
+
+	// 表中的配置优先，Spec 为空时回到部署时指定的定时周期
+	nw.apply([]*model.WatcherConfig{{Name: "scheduleWatcher", Enabled: true, Spec: "@every 10s"}})
+	if entry := nw.entries["scheduleWatcher"]; entry.spec != "@every 10s" {
+		t.Fatalf("spec = %q, want the spec in table", entry.spec)
+	}
+	nw.apply([]*model.WatcherConfig{{Name: "scheduleWatcher", Enabled: true}})
+	if entry := nw.entries["scheduleWatcher"]; entry.spec != "@every 1m" {
+		t.Fatalf("spec = %q, want the deployment spec", entry.spec)
+	}
+}
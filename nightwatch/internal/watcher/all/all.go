@@ -2,5 +2,7 @@ package all
 
 import (
 	// 触发所有 Watcher 的 init 函数进行注册
+	_ "github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher/notification"
+	_ "github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher/schedule"
 	_ "github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher/task"
 )
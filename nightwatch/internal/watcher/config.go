@@ -3,6 +3,7 @@ package watcher
 import (
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
 )
 
@@ -10,4 +11,24 @@ type Config struct {
 	Store store.IStore
 
 	Clientset kubernetes.Interface
+
+	// 是否允许以 nightwatch 本地进程的形式执行任务，默认关闭
+	// 本地执行器能够在 nightwatch 所在主机上执行任意命令，只应在受信任的环境中开启
+	EnableLocalExecutor bool
+
+	// 任务并发配额，超出配额的任务进入 Queued 状态排队
+	TaskQuota model.TaskQuota
+	// 等待启动的任务的调度策略：fifo、priority、fair-share，为空时使用 fair-share
+	SchedulingPolicy string
+	// fair-share 策略中各用户的权重，未指定的用户权重为 1
+	UserWeights map[int64]float64
+}
+
+// Executors 返回允许使用的任务执行器
+func (c *Config) Executors() []model.ExecutorType {
+	executors := []model.ExecutorType{model.ExecutorKubernetes}
+	if c.EnableLocalExecutor {
+		executors = append(executors, model.ExecutorLocal)
+	}
+	return executors
 }
@@ -0,0 +1,66 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/notifier"
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+var _ watcher.Watcher = (*notificationWatcher)(nil)
+
+// 每次执行最多发送的通知数量，剩余的通知在下个周期发送
+const batchSize = 100
+
+// notificationWatcher 发送到达发送时间的任务通知，发送失败时按指数退避重试，超过最大发送次数后标记为 Failed
+// 通知保存在 task_notification 表中，nightwatch 重启或 leader 切换后由新的 leader 继续发送
+type notificationWatcher struct {
+	store  store.IStore
+	sender *notifier.Sender
+}
+
+func (w *notificationWatcher) Init(ctx context.Context, config *watcher.Config) error {
+	w.store = config.Store
+	w.sender = notifier.NewSender(nil)
+	return nil
+}
+
+// Run 运行 notification watcher 任务
+func (w *notificationWatcher) Run(ctx context.Context) {
+	notifications, err := w.store.Notifications().ListDue(ctx, time.Now(), batchSize)
+	if err != nil {
+		slog.Error("Failed to list due notifications", "err", err)
+		return
+	}
+
+	for _, n := range notifications {
+		if ctx.Err() != nil {
+			return
+		}
+		w.send(ctx, n)
+	}
+}
+
+// send 发送一条通知并记录结果
+func (w *notificationWatcher) send(ctx context.Context, n *model.TaskNotification) {
+	if err := w.sender.Send(ctx, n); err != nil {
+		n.MarkFailed(err, time.Now())
+		slog.Warn("Failed to send notification", "err", err, "notificationID", n.ID, "taskID", n.TaskID,
+			"type", n.Target.Type, "attempts", n.Attempts, "status", n.Status)
+	} else {
+		n.MarkSent(time.Now())
+		slog.Info("Successfully sent notification", "notificationID", n.ID, "taskID", n.TaskID, "type", n.Target.Type)
+	}
+
+	if err := w.store.Notifications().Update(ctx, n); err != nil {
+		slog.Error("Failed to update notification", "err", err, "notificationID", n.ID)
+	}
+}
+
+func init() {
+	watcher.Register(&notificationWatcher{})
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/notifier"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+func TestNotificationWatcherRun(t *testing.T) {
+	var fail atomic.Bool
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	w := &notificationWatcher{store: s, sender: notifier.NewSender(nil)}
+
+	task := &model.Task{
+		ID:            1,
+		Name:          "demo",
+		Status:        model.TaskStatusSucceeded,
+		Notifications: model.NotificationTargets{{Type: model.NotifierWebhook, URL: srv.URL}},
+	}
+	event := model.NewTaskEvent(task, model.TaskStatusRunning, model.TaskEventReasonStatusSynced, "")
+	if err := s.Notifications().Create(ctx, model.NewTaskNotifications(task, event)...); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 发送失败后等待重试，到达重试时间前不会再次发送
+	fail.Store(true)
+	w.Run(ctx)
+	w.Run(ctx)
+	notifications, _ := s.Notifications().List(ctx, task.ID)
+	if len(notifications) != 1 {
+		t.Fatalf("List() = %d notifications, want 1", len(notifications))
+	}
+	n := notifications[0]
+	if requests.Load() != 1 || n.Status != model.NotificationStatusPending || n.Attempts != 1 || n.LastError == "" || !n.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("after failure: %d requests, notification = %+v", requests.Load(), n)
+	}
+
+	// 到达重试时间后重新发送
+	fail.Store(false)
+	n.NextAttemptAt = time.Now()
+	if err := s.Notifications().Update(ctx, n); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	w.Run(ctx)
+	w.Run(ctx)
+	notifications, _ = s.Notifications().List(ctx, task.ID)
+	n = notifications[0]
+	if requests.Load() != 2 || n.Status != model.NotificationStatusSent || n.Attempts != 2 || n.SentAt == nil {
+		t.Errorf("after retry: %d requests, notification = %+v", requests.Load(), n)
+	}
+	if due, _ := s.Notifications().ListDue(ctx, time.Now(), batchSize); len(due) != 0 {
+		t.Errorf("ListDue() = %d notifications, want 0", len(due))
+	}
+}
@@ -0,0 +1,186 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+var _ watcher.Watcher = (*scheduleWatcher)(nil)
+
+// errScheduleChanged 定时任务在本次调度期间被修改，放弃本次调度等待下个周期重新计算
+var errScheduleChanged = errors.New("schedule has been changed concurrently")
+
+// 定时任务创建的任务处于这些状态时，认为任务还未结束
+var activeStatuses = []model.TaskStatus{
+	model.TaskStatusNormal,
+	model.TaskStatusPending,
+	model.TaskStatusRunning,
+	model.TaskStatusRetrying,
+	model.TaskStatusQueued,
+	model.TaskStatusUnknown,
+	model.TaskStatusCancelling,
+}
+
+// scheduleWatcher 为到达执行时间的定时任务创建 Normal 状态的任务，任务由 taskWatcher 负责启动
+type scheduleWatcher struct {
+	store store.IStore
+}
+
+func (w *scheduleWatcher) Init(ctx context.Context, config *watcher.Config) error {
+	w.store = config.Store
+	return nil
+}
+
+// Run 运行 schedule watcher 任务
+func (w *scheduleWatcher) Run(ctx context.Context) {
+	_, schedules, err := w.store.Schedules().List(ctx, meta.WithFilter(map[string]any{"suspend": false}))
+	if err != nil {
+		slog.Error("Failed to list schedules", "err", err)
+		return
+	}
+
+	now := time.Now()
+	for _, s := range schedules {
+		w.schedule(ctx, s, now)
+	}
+}
+
+// schedule 根据并发策略为定时任务创建到达执行时间的任务，并记录最近一次执行时间
+func (w *scheduleWatcher) schedule(ctx context.Context, s *model.TaskSchedule, now time.Time) {
+	spec, err := watcher.Parser.Parse(s.Spec)
+	if err != nil {
+		slog.Error("Invalid schedule spec", "err", err, "scheduleID", s.ID, "spec", s.Spec)
+		return
+	}
+
+	from := s.CreatedAt
+	if s.LastScheduleAt != nil {
+		from = *s.LastScheduleAt
+	}
+	due, skipped := dueTimes(spec, from, now, s.CatchUpLimit+1)
+	if len(due) == 0 {
+		return
+	}
+	lastScheduleAt := due[len(due)-1]
+
+	// 不允许并发时，补偿执行的任务也会被跳过或替换，只需要创建最近一次
+	if s.ConcurrencyPolicy != model.ConcurrencyPolicyAllow {
+		skipped += len(due) - 1
+		due = due[len(due)-1:]
+	}
+	if skipped > 0 {
+		slog.Warn("Skip missed schedules", "scheduleID", s.ID, "count", skipped)
+	}
+
+	_, active, err := w.store.Tasks().List(ctx, meta.WithFilter(map[string]any{
+		"schedule_id": s.ID,
+		"status":      activeStatuses,
+	}))
+	if err != nil {
+		slog.Error("Failed to list active tasks", "err", err, "scheduleID", s.ID)
+		return
+	}
+	if s.ConcurrencyPolicy == model.ConcurrencyPolicyForbid && len(active) > 0 {
+		slog.Info("Previous task is still active, skip schedule", "scheduleID", s.ID, "scheduledAt", lastScheduleAt)
+		due = nil
+	}
+
+	conds := map[string]any{"last_schedule_at": s.LastScheduleAt, "updated_at": s.UpdatedAt}
+	var replaced []*model.Task
+	err = w.store.TX(ctx, func(ctx context.Context) error {
+		// 与创建新任务在同一个事务中取消上一次的任务，定时任务被并发修改放弃本次调度时不会只取消不创建
+		replaced = nil
+		if s.ConcurrencyPolicy == model.ConcurrencyPolicyReplace {
+			for _, task := range active {
+				ok, err := w.replace(ctx, task)
+				if err != nil {
+					return err
+				}
+				if ok {
+					replaced = append(replaced, task)
+				}
+			}
+		}
+
+		for _, t := range due {
+			task := s.NewTask(t)
+			if err := w.store.Tasks().Create(ctx, task); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					slog.Warn("Task already exists, skip schedule", "scheduleID", s.ID, "name", task.Name)
+					continue
+				}
+				return err
+			}
+			slog.Info("Successfully created scheduled task", "scheduleID", s.ID, "taskID", task.ID, "scheduledAt", t)
+		}
+
+		s.LastScheduleAt = &lastScheduleAt
+		ok, err := w.store.Schedules().UpdateWhere(ctx, s, conds)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errScheduleChanged
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to schedule tasks", "err", err, "scheduleID", s.ID)
+		return
+	}
+	for _, task := range replaced {
+		slog.Info("Successfully cancelled replaced task", "taskID", task.ID, "status", task.Status)
+	}
+}
+
+// replace 在调用方的事务中取消定时任务上一次创建的任务，已经启动的任务由 taskWatcher 删除执行实例后完成取消
+// 任务已经在取消中或者已经被并发修改时返回 false
+func (w *scheduleWatcher) replace(ctx context.Context, task *model.Task) (bool, error) {
+	to, ok := task.Status.CancelStatus()
+	if !ok {
+		// 已经在取消中
+		return false, nil
+	}
+
+	from := task.Status
+	task.Status = to
+	ok, err := w.store.Tasks().UpdateWhere(ctx, task, map[string]any{"status": from})
+	if err != nil || !ok {
+		return false, err
+	}
+	event := model.NewTaskEvent(task, from, model.TaskEventReasonReplaced, "")
+	if err := w.store.TaskEvents().Create(ctx, event); err != nil {
+		return false, err
+	}
+	if err := w.store.Notifications().Create(ctx, model.NewTaskNotifications(task, event)...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// dueTimes 返回 (from, now] 之间到达的执行时间，最多保留最近的 limit 个，skipped 为被丢弃的个数
+func dueTimes(spec cron.Schedule, from, now time.Time, limit int) (due []time.Time, skipped int) {
+	limit = max(limit, 1)
+	for t := spec.Next(from); !t.IsZero() && !t.After(now); t = spec.Next(t) {
+		due = append(due, t)
+		if len(due) > limit {
+			due = due[1:]
+			skipped++
+		}
+	}
+	return due, skipped
+}
+
+func init() {
+	watcher.Register(&scheduleWatcher{})
+}
@@ -0,0 +1,120 @@
+package schedule
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+func TestDueTimes(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name        string
+		spec        string
+		from, now   time.Time
+		limit       int
+		wantDue     []time.Time
+		wantSkipped int
+	}{
+		{name: "not due", spec: "0 */10 * * * *", from: at(0), now: at(9), limit: 1},
+		{name: "due exactly now", spec: "0 */10 * * * *", from: at(0), now: at(10), limit: 1, wantDue: []time.Time{at(10)}},
+		{name: "keep latest", spec: "0 */10 * * * *", from: at(0), now: at(35), limit: 1, wantDue: []time.Time{at(30)}, wantSkipped: 2},
+		{name: "catch up", spec: "0 */10 * * * *", from: at(0), now: at(35), limit: 2, wantDue: []time.Time{at(20), at(30)}, wantSkipped: 1},
+		{name: "catch up all", spec: "0 */10 * * * *", from: at(0), now: at(35), limit: 10, wantDue: []time.Time{at(10), at(20), at(30)}},
+		{name: "zero limit keeps latest", spec: "@every 1m", from: at(0), now: at(3), limit: 0, wantDue: []time.Time{at(3)}, wantSkipped: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := watcher.Parser.Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			due, skipped := dueTimes(spec, tt.from, tt.now, tt.limit)
+			if len(due) != len(tt.wantDue) || skipped != tt.wantSkipped {
+				t.Fatalf("dueTimes() = %v, %d, want %v, %d", due, skipped, tt.wantDue, tt.wantSkipped)
+			}
+			for i := range due {
+				if !due[i].Equal(tt.wantDue[i]) {
+					t.Fatalf("dueTimes() = %v, want %v", due, tt.wantDue)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleReplace(t *testing.T) {
+	tests := []struct {
+		name string
+		// changed 为 true 时定时任务在调度期间被并发修改
+		changed        bool
+		wantPrevious   model.TaskStatus
+		wantTaskCount  int64
+		wantLastUpdate bool
+	}{
+		{name: "replace", wantPrevious: model.TaskStatusCancelling, wantTaskCount: 2, wantLastUpdate: true},
+		// 放弃本次调度时上一次的任务也不能被取消
+		{name: "schedule changed", changed: true, wantPrevious: model.TaskStatusRunning, wantTaskCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemoryStore()
+			now := time.Now()
+			schedule := &model.TaskSchedule{
+				Name:              "backup",
+				Namespace:         "demo",
+				Spec:              "@every 1m",
+				ConcurrencyPolicy: model.ConcurrencyPolicyReplace,
+				CreatedAt:         now.Add(-90 * time.Second),
+			}
+			if err := s.Schedules().Create(ctx, schedule); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			previous := schedule.NewTask(now.Add(-time.Hour))
+			previous.Status = model.TaskStatusRunning
+			if err := s.Tasks().Create(ctx, previous); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			if tt.changed {
+				stale := *schedule
+				stale.Spec = "@every 2m"
+				if err := s.Schedules().Update(ctx, &stale); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+			w := &scheduleWatcher{store: s}
+			w.schedule(ctx, schedule, now)
+
+			got, err := s.Tasks().Get(ctx, strconv.FormatInt(previous.ID, 10))
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status != tt.wantPrevious {
+				t.Errorf("status of previous task = %s, want %s", got.Status, tt.wantPrevious)
+			}
+			count, _, err := s.Tasks().List(ctx, meta.WithFilter(map[string]any{"schedule_id": schedule.ID}))
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if count != tt.wantTaskCount {
+				t.Errorf("task count = %d, want %d", count, tt.wantTaskCount)
+			}
+			current, err := s.Schedules().Get(ctx, strconv.FormatInt(schedule.ID, 10))
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if (current.LastScheduleAt != nil) != tt.wantLastUpdate {
+				t.Errorf("last schedule at = %v, want updated %v", current.LastScheduleAt, tt.wantLastUpdate)
+			}
+		})
+	}
+}
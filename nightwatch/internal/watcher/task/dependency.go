@@ -0,0 +1,90 @@
+package task
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 上游任务处于这些状态时，下游任务无法再启动
+var upstreamFailedStatuses = []model.TaskStatus{
+	model.TaskStatusFailed,
+	model.TaskStatusCancelled,
+	model.TaskStatusUpstreamFailed,
+}
+
+// upstreamsSucceeded 判断任务依赖的上游任务是否都已执行成功
+// 存在无法执行成功的上游任务时，将任务及其下游任务标记为 UpstreamFailed
+func (w *taskWatcher) upstreamsSucceeded(ctx context.Context, task *model.Task) bool {
+	deps, err := w.store.Tasks().ListDependencies(ctx, task.ID)
+	if err != nil {
+		slog.Error("Failed to list task dependencies", "err", err, "taskID", task.ID)
+		return false
+	}
+	if len(deps) == 0 {
+		return true
+	}
+
+	ids := make([]int64, 0, len(deps))
+	for _, dep := range deps {
+		ids = append(ids, dep.DependsOnID)
+	}
+	_, upstreams, err := w.store.Tasks().List(ctx, meta.WithFilter(map[string]any{"id": ids}), meta.WithLimit(int64(len(ids))))
+	if err != nil {
+		slog.Error("Failed to list upstream tasks", "err", err, "taskID", task.ID)
+		return false
+	}
+
+	// 上游任务被删除也视为无法执行成功
+	failed := len(upstreams) < len(ids)
+	succeeded := 0
+	for _, upstream := range upstreams {
+		if upstream.Status == model.TaskStatusSucceeded {
+			succeeded++
+		} else if slices.Contains(upstreamFailedStatuses, upstream.Status) {
+			failed = true
+		}
+	}
+	if failed {
+		w.markUpstreamFailed(ctx, task)
+		return false
+	}
+	return succeeded == len(ids)
+}
+
+// failDownstreams 任务最终执行失败后，将还未启动的下游任务标记为 UpstreamFailed
+func (w *taskWatcher) failDownstreams(ctx context.Context, task *model.Task) {
+	downstreams, err := w.store.Tasks().ListDownstreams(ctx, task.ID)
+	if err != nil {
+		slog.Error("Failed to list downstream tasks", "err", err, "taskID", task.ID)
+		return
+	}
+	for _, downstream := range downstreams {
+		w.markUpstreamFailed(ctx, downstream)
+	}
+}
+
+// markUpstreamFailed 将还未启动的任务标记为 UpstreamFailed，并继续传播给下游任务
+// 创建任务时已经拒绝了依赖环，递归一定会结束
+func (w *taskWatcher) markUpstreamFailed(ctx context.Context, task *model.Task) {
+	if task.Status != model.TaskStatusNormal {
+		return
+	}
+
+	task.Status = model.TaskStatusUpstreamFailed
+	event := model.NewTaskEvent(task, model.TaskStatusNormal, model.TaskEventReasonUpstreamFailed, "")
+	ok, err := w.updateWithEvent(ctx, task, map[string]any{"status": model.TaskStatusNormal}, event)
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	slog.Info("Upstream task failed, skip task", "taskID", task.ID)
+
+	w.failDownstreams(ctx, task)
+}
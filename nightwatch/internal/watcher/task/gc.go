@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/executor"
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// executionLostGracePeriod 任务提交执行后超过此时长仍然找不到执行实例时，认为执行实例已经消失
+// 任务在提交前已经变为 Pending，需要为提交执行以及 informer 缓存同步留出时间
+const executionLostGracePeriod = 5 * time.Minute
+
+var (
+	_ watcher.Watcher = (*gcWatcher)(nil)
+	_ watcher.ISpec   = (*gcWatcher)(nil)
+)
+
+// gcWatcher 回收所属任务已经删除的执行实例，并将执行实例已经消失的任务标记为失败或等待重试
+// 与 taskWatcher 共用执行器，所有 Watcher 都初始化完成后才会执行
+type gcWatcher struct {
+	tasks *taskWatcher
+}
+
+func (w *gcWatcher) Init(ctx context.Context, config *watcher.Config) error {
+	return nil
+}
+
+func (w *gcWatcher) Spec() string {
+	return "@every 5m"
+}
+
+// Run 运行 gc watcher 任务
+func (w *gcWatcher) Run(ctx context.Context) {
+	for _, exec := range w.tasks.executors {
+		if collector, ok := exec.(executor.Collector); ok {
+			w.collectOrphans(ctx, collector)
+		}
+	}
+	w.repairLostTasks(ctx)
+}
+
+// collectOrphans 删除所属任务已经不存在的执行实例
+func (w *gcWatcher) collectOrphans(ctx context.Context, collector executor.Collector) {
+	executions, err := collector.ListExecutions(ctx)
+	if err != nil {
+		slog.Error("Failed to list executions", "err", err)
+		return
+	}
+	if len(executions) == 0 {
+		return
+	}
+
+	ids := make(map[int64]bool)
+	for _, e := range executions {
+		ids[e.TaskID] = false
+	}
+	taskIDs := make([]int64, 0, len(ids))
+	for id := range ids {
+		taskIDs = append(taskIDs, id)
+	}
+	_, tasks, err := w.tasks.store.Tasks().List(ctx,
+		meta.WithFilter(map[string]any{"id": taskIDs}),
+		meta.WithLimit(int64(len(taskIDs))),
+	)
+	if err != nil {
+		slog.Error("Failed to list tasks of executions", "err", err)
+		return
+	}
+	for _, task := range tasks {
+		ids[task.ID] = true
+	}
+
+	for _, e := range executions {
+		if ids[e.TaskID] {
+			continue
+		}
+		if err := collector.DeleteExecution(ctx, e); err != nil {
+			slog.Error("Failed to delete orphan execution", "err", err, "namespace", e.Namespace, "name", e.Name, "taskID", e.TaskID)
+			continue
+		}
+		slog.Info("Deleted orphan execution", "namespace", e.Namespace, "name", e.Name, "taskID", e.TaskID, "attempt", e.Attempt)
+	}
+}
+
+// repairLostTasks 将已经提交执行但执行实例消失的任务按执行失败处理，还可以重试时进入 Retrying 状态
+// 例如 Job 在 nightwatch 停止期间执行结束并被 TTL 清理，或者被手动删除
+func (w *gcWatcher) repairLostTasks(ctx context.Context) {
+	_, tasks, err := w.tasks.store.Tasks().List(ctx, meta.WithFilter(map[string]any{
+		"status": []model.TaskStatus{model.TaskStatusPending, model.TaskStatusRunning, model.TaskStatusUnknown},
+	}))
+	if err != nil {
+		slog.Error("Failed to list in-flight tasks", "err", err)
+		return
+	}
+
+	deadline := time.Now().Add(-executionLostGracePeriod)
+	for _, task := range tasks {
+		if task.UpdatedAt.After(deadline) {
+			continue
+		}
+		exec, err := w.tasks.executor(task)
+		if err != nil {
+			continue
+		}
+		_, err = exec.Status(ctx, task)
+		if !errors.Is(err, executor.ErrNotFound) {
+			continue
+		}
+
+		slog.Warn("Task execution is lost", "taskID", task.ID, "attempt", task.Attempt, "status", task.Status)
+		state := &executor.State{
+			Status:  model.TaskStatusFailed,
+			Message: fmt.Sprintf("execution of attempt %d not found", task.Attempt),
+		}
+		w.tasks.updateStatus(ctx, task, state, model.TaskEventReasonExecutionLost)
+	}
+}
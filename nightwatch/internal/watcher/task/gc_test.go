@@ -0,0 +1,56 @@
+package task
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/executor"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+func TestRepairLostLocalTask(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	// 进程由重启前的 nightwatch 启动，当前执行器中没有进程信息
+	updated := time.Now().Add(-2 * executionLostGracePeriod)
+	lost := &model.Task{Name: "lost", Namespace: "demo", Executor: model.ExecutorLocal, Status: model.TaskStatusRunning, Attempt: 1, UpdatedAt: updated}
+	retry := &model.Task{Name: "retry", Namespace: "demo", Executor: model.ExecutorLocal, Status: model.TaskStatusRunning, Attempt: 1, MaxAttempts: 2, UpdatedAt: updated}
+	// 刚提交执行的任务还在宽限期内
+	recent := &model.Task{Name: "recent", Namespace: "demo", Executor: model.ExecutorLocal, Status: model.TaskStatusPending, Attempt: 1}
+	for _, task := range []*model.Task{lost, retry, recent} {
+		if err := s.Tasks().Create(ctx, task); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	w := &gcWatcher{tasks: &taskWatcher{
+		store:     s,
+		executors: map[model.ExecutorType]executor.Executor{model.ExecutorLocal: executor.NewLocal()},
+	}}
+	w.repairLostTasks(ctx)
+
+	for task, want := range map[*model.Task]model.TaskStatus{
+		lost:   model.TaskStatusFailed,
+		retry:  model.TaskStatusRetrying,
+		recent: model.TaskStatusPending,
+	} {
+		got, err := s.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10))
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Status != want {
+			t.Errorf("status of task %s = %s, want %s", task.Name, got.Status, want)
+		}
+	}
+
+	events, err := s.TaskEvents().List(ctx, lost.ID)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Reason != model.TaskEventReasonExecutionLost {
+		t.Fatalf("events of lost task = %+v, want one %s event", events, model.TaskEventReasonExecutionLost)
+	}
+}
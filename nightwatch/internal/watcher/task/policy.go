@@ -0,0 +1,159 @@
+package task
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 调度策略名称
+const (
+	policyFIFO      = "fifo"       // 按创建顺序启动
+	policyPriority  = "priority"   // 按优先级启动，优先级相同时按创建顺序
+	policyFairShare = "fair-share" // 按优先级启动，优先级相同时按权重在用户之间公平分配，同一用户按创建顺序
+)
+
+// defaultPolicy 默认的调度策略
+const defaultPolicy = policyFairShare
+
+// policy 调度策略，决定等待启动的任务的启动顺序
+// 任务按返回的顺序依次检查并发配额，排在前面的任务先占用配额
+type policy interface {
+	// Order 返回排序后的任务，usages 为各用户已经提交执行还未结束的任务数量
+	Order(tasks []*model.Task, usages []*model.TaskUsage) []*model.Task
+	// ListOrder 分页读取等待启动的任务时使用的排序，与 Order 中任务的先后顺序一致
+	ListOrder() string
+	// Tied 判断按 ListOrder 相邻的两个任务是否需要在同一批次中排序，分页读取时不能在两者之间截断
+	Tied(a, b *model.Task) bool
+}
+
+// newPolicy 根据名称创建调度策略，weights 为公平分配时各用户的权重，未指定的用户权重为 1
+func newPolicy(name string, weights map[int64]float64) (policy, error) {
+	switch name {
+	case policyFIFO:
+		return fifoPolicy{}, nil
+	case policyPriority:
+		return priorityPolicy{}, nil
+	case policyFairShare:
+		return &fairSharePolicy{weights: weights}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheduling policy: %s", name)
+	}
+}
+
+// compareAge 按创建时间排序，创建时间相同时按 ID 排序
+func compareAge(a, b *model.Task) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// comparePriority 按优先级从高到低排序，优先级相同时按创建顺序
+func comparePriority(a, b *model.Task) int {
+	if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+		return c
+	}
+	return compareAge(a, b)
+}
+
+// 与 compareAge、comparePriority 对应的排序
+const (
+	ageOrder      = "created_at, id"
+	priorityOrder = "priority desc, created_at, id"
+)
+
+type fifoPolicy struct{}
+
+func (fifoPolicy) ListOrder() string { return ageOrder }
+
+func (fifoPolicy) Tied(_, _ *model.Task) bool { return false }
+
+func (fifoPolicy) Order(tasks []*model.Task, _ []*model.TaskUsage) []*model.Task {
+	ret := slices.Clone(tasks)
+	slices.SortStableFunc(ret, compareAge)
+	return ret
+}
+
+type priorityPolicy struct{}
+
+func (priorityPolicy) ListOrder() string { return priorityOrder }
+
+func (priorityPolicy) Tied(_, _ *model.Task) bool { return false }
+
+func (priorityPolicy) Order(tasks []*model.Task, _ []*model.TaskUsage) []*model.Task {
+	ret := slices.Clone(tasks)
+	slices.SortStableFunc(ret, comparePriority)
+	return ret
+}
+
+// fairSharePolicy 同一优先级的任务每次选择占用份额（运行中的任务数量 / 权重）最少的用户，避免单个用户的大量任务阻塞其他用户
+type fairSharePolicy struct {
+	weights map[int64]float64
+}
+
+func (p *fairSharePolicy) weight(userID int64) float64 {
+	if w, ok := p.weights[userID]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+func (p *fairSharePolicy) ListOrder() string { return priorityOrder }
+
+// Tied 同一优先级的任务需要一起在用户之间分配
+func (p *fairSharePolicy) Tied(a, b *model.Task) bool { return a.Priority == b.Priority }
+
+func (p *fairSharePolicy) Order(tasks []*model.Task, usages []*model.TaskUsage) []*model.Task {
+	running := make(map[int64]float64)
+	for _, u := range usages {
+		running[u.UserID] += float64(u.Count)
+	}
+
+	sorted := slices.Clone(tasks)
+	slices.SortStableFunc(sorted, comparePriority)
+
+	ret := make([]*model.Task, 0, len(sorted))
+	for len(sorted) > 0 {
+		// 取出同一优先级的任务，按用户分组，组内保持创建顺序
+		n := 1
+		for n < len(sorted) && sorted[n].Priority == sorted[0].Priority {
+			n++
+		}
+		queues := make(map[int64][]*model.Task)
+		var users []int64
+		for _, task := range sorted[:n] {
+			if _, ok := queues[task.UserID]; !ok {
+				users = append(users, task.UserID)
+			}
+			queues[task.UserID] = append(queues[task.UserID], task)
+		}
+		sorted = sorted[n:]
+
+		for len(users) > 0 {
+			i := p.next(users, queues, running)
+			user := users[i]
+			ret = append(ret, queues[user][0])
+			running[user]++
+			if queues[user] = queues[user][1:]; len(queues[user]) == 0 {
+				users = slices.Delete(users, i, i+1)
+			}
+		}
+	}
+	return ret
+}
+
+// next 返回占用份额最少的用户下标，份额相同时选择队首任务创建最早的用户
+func (p *fairSharePolicy) next(users []int64, queues map[int64][]*model.Task, running map[int64]float64) int {
+	best := 0
+	for i := 1; i < len(users); i++ {
+		a, b := users[i], users[best]
+		shareA, shareB := running[a]/p.weight(a), running[b]/p.weight(b)
+		if shareA < shareB || (shareA == shareB && compareAge(queues[a][0], queues[b][0]) < 0) {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func TestPolicyOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// ID 与创建顺序一致
+	task := func(id, userID int64, priority int) *model.Task {
+		return &model.Task{ID: id, UserID: userID, Priority: priority, CreatedAt: base.Add(time.Duration(id) * time.Second)}
+	}
+	tasks := []*model.Task{
+		task(6, 2, 0),
+		task(1, 1, 0),
+		task(2, 1, 0),
+		task(3, 1, 0),
+		task(4, 2, 0),
+		task(5, 3, 5),
+	}
+	usages := []*model.TaskUsage{{UserID: 2, Namespace: "demo", Count: 1}}
+
+	tests := []struct {
+		policy  string
+		weights map[int64]float64
+		want    []int64
+	}{
+		{policy: policyFIFO, want: []int64{1, 2, 3, 4, 5, 6}},
+		{policy: policyPriority, want: []int64{5, 1, 2, 3, 4, 6}},
+		// 用户 2 已经有一个运行中的任务，用户 1 先启动一个任务后两者份额相同，再按队首任务的创建顺序交替
+		{policy: policyFairShare, want: []int64{5, 1, 2, 4, 3, 6}},
+		// 用户 2 的权重为 2，运行中的任务占用的份额减半
+		{policy: policyFairShare, weights: map[int64]float64{2: 2}, want: []int64{5, 1, 4, 2, 6, 3}},
+	}
+	for _, tt := range tests {
+		p, err := newPolicy(tt.policy, tt.weights)
+		if err != nil {
+			t.Fatalf("newPolicy(%s) error = %v", tt.policy, err)
+		}
+		var got []int64
+		for _, task := range p.Order(tasks, usages) {
+			got = append(got, task.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("%s policy with weights %v order = %v, want %v", tt.policy, tt.weights, got, tt.want)
+		}
+	}
+
+	if _, err := newPolicy("lottery", nil); err == nil {
+		t.Fatal("newPolicy() with unsupported policy should fail")
+	}
+}
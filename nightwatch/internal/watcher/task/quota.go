@@ -0,0 +1,53 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// 占用并发配额的任务状态，即已经提交执行还未结束的任务
+var quotaStatuses = []model.TaskStatus{
+	model.TaskStatusPending,
+	model.TaskStatusRunning,
+	model.TaskStatusUnknown,
+	model.TaskStatusCancelling,
+}
+
+// quotaTracker 记录本次调度中各个维度已经占用的配额
+type quotaTracker struct {
+	quota      model.TaskQuota
+	total      int64
+	users      map[int64]int64
+	namespaces map[string]int64
+}
+
+func newQuotaTracker(quota model.TaskQuota, usages []*model.TaskUsage) *quotaTracker {
+	q := &quotaTracker{quota: quota, users: make(map[int64]int64), namespaces: make(map[string]int64)}
+	for _, u := range usages {
+		q.total += u.Count
+		q.users[u.UserID] += u.Count
+		q.namespaces[u.Namespace] += u.Count
+	}
+	return q
+}
+
+// admit 判断任务是否在配额内，在配额内时占用配额，否则返回超出配额的原因
+func (q *quotaTracker) admit(task *model.Task) (string, bool) {
+	switch {
+	case exceeded(q.quota.MaxRunning, q.total):
+		return fmt.Sprintf("running tasks reached the limit of %d", q.quota.MaxRunning), false
+	case exceeded(q.quota.MaxRunningPerUser, q.users[task.UserID]):
+		return fmt.Sprintf("running tasks of user %d reached the limit of %d", task.UserID, q.quota.MaxRunningPerUser), false
+	case exceeded(q.quota.MaxRunningPerNamespace, q.namespaces[task.Namespace]):
+		return fmt.Sprintf("running tasks in namespace %s reached the limit of %d", task.Namespace, q.quota.MaxRunningPerNamespace), false
+	}
+	q.total++
+	q.users[task.UserID]++
+	q.namespaces[task.Namespace]++
+	return "", true
+}
+
+func exceeded(limit, used int64) bool {
+	return limit > 0 && used >= limit
+}
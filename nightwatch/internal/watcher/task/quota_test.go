@@ -0,0 +1,58 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+func TestQuotaTrackerAdmit(t *testing.T) {
+	task := func(userID int64, namespace string) *model.Task {
+		return &model.Task{UserID: userID, Namespace: namespace}
+	}
+	usages := []*model.TaskUsage{
+		{UserID: 1, Namespace: "demo", Count: 1},
+		{UserID: 2, Namespace: "prod", Count: 1},
+	}
+
+	tests := []struct {
+		name  string
+		quota model.TaskQuota
+		tasks []*model.Task
+		want  []bool
+	}{
+		{
+			name:  "unlimited",
+			tasks: []*model.Task{task(1, "demo"), task(1, "demo"), task(2, "prod")},
+			want:  []bool{true, true, true},
+		},
+		{
+			name:  "global",
+			quota: model.TaskQuota{MaxRunning: 3},
+			tasks: []*model.Task{task(1, "demo"), task(2, "prod")},
+			want:  []bool{true, false},
+		},
+		{
+			name:  "per user",
+			quota: model.TaskQuota{MaxRunningPerUser: 2},
+			tasks: []*model.Task{task(1, "demo"), task(1, "prod"), task(3, "demo")},
+			want:  []bool{true, false, true},
+		},
+		{
+			name:  "per namespace",
+			quota: model.TaskQuota{MaxRunningPerNamespace: 1},
+			tasks: []*model.Task{task(3, "demo"), task(3, "test"), task(4, "test")},
+			want:  []bool{false, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuotaTracker(tt.quota, usages)
+			for i, task := range tt.tasks {
+				if reason, ok := q.admit(task); ok != tt.want[i] {
+					t.Fatalf("admit(task %d) = %v (%s), want %v", i, ok, reason, tt.want[i])
+				}
+			}
+		})
+	}
+}
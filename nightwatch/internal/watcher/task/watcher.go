@@ -2,89 +2,122 @@ package task
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"gorm.io/gorm"
+	"k8s.io/client-go/util/retry"
 
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/executor"
 	"github.com/jianghushinian/blog-go-example/nightwatch/internal/watcher"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
 	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
 )
 
-var _ watcher.Watcher = (*taskWatcher)(nil)
+const (
+	// 分页读取等待启动的任务时每页的数量，每个周期读取到这么多可以启动的任务后停止
+	admitBatchSize = 500
+	// 获取任务执行结果的超时时间，日志较多时避免长时间阻塞状态同步
+	resultTimeout = 10 * time.Second
+	// 等待执行实例删除完成的超时时间，超时后在下个周期继续等待
+	cancelTimeout = 20 * time.Second
+)
+
+var (
+	_ watcher.Watcher  = (*taskWatcher)(nil)
+	_ watcher.IStarter = (*taskWatcher)(nil)
+	_ watcher.IStopper = (*taskWatcher)(nil)
+)
 
 type taskWatcher struct {
 	store     store.IStore
-	clientset kubernetes.Interface
+	executors map[model.ExecutorType]executor.Executor
+	quota     model.TaskQuota
+	policy    policy
 
 	wg sync.WaitGroup
 }
 
 func (w *taskWatcher) Init(ctx context.Context, config *watcher.Config) error {
 	w.store = config.Store
-	w.clientset = config.Clientset
+	w.quota = config.TaskQuota
+	name := config.SchedulingPolicy
+	if name == "" {
+		name = defaultPolicy
+	}
+	policy, err := newPolicy(name, config.UserWeights)
+	if err != nil {
+		return err
+	}
+	w.policy = policy
+	w.executors = make(map[model.ExecutorType]executor.Executor)
+	if config.Clientset != nil {
+		w.executors[model.ExecutorKubernetes] = executor.NewKubernetes(config.Clientset)
+	}
+	if config.EnableLocalExecutor {
+		w.executors[model.ExecutorLocal] = executor.NewLocal()
+	}
 	return nil
 }
 
+// Start 启动能够主动推送任务状态变化的执行器，ctx 取消时退出
+func (w *taskWatcher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, exec := range w.executors {
+		if source, ok := exec.(executor.EventSource); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				source.Watch(ctx, w.onEvent)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// Stop 终止在 nightwatch 进程内执行的任务，避免释放锁后与新的 leader 重复执行
+func (w *taskWatcher) Stop() {
+	for _, exec := range w.executors {
+		if t, ok := exec.(executor.Terminator); ok {
+			t.TerminateAll()
+		}
+	}
+}
+
 func (w *taskWatcher) Spec() string {
 	return "@every 30s"
 }
 
 // Run 运行 task watcher 任务
-func (w *taskWatcher) Run() {
+func (w *taskWatcher) Run(ctx context.Context) {
 	w.wg.Add(2)
 
 	slog.Debug("Sync period is start")
 
-	// NOTE: 将 Normal 状态任务在 K8s 中启动
+	// NOTE: 按调度策略的顺序将上游任务都已执行成功的 Normal 状态、到达重试时间的 Retrying 状态以及排队中的任务交给执行器启动
+	// 超出并发配额的任务进入 Queued 状态，等待配额空闲后按同样的顺序启动
 	go func() {
 		defer w.wg.Done()
-		ctx := context.Background()
-
-		_, tasks, err := w.store.Tasks().List(ctx, meta.WithFilter(map[string]any{
-			"status": model.TaskStatusNormal,
-		}))
-		if err != nil {
-			slog.Error(err.Error(), "Failed to list tasks")
-			return
-		}
-
-		var wg sync.WaitGroup
-		wg.Add(len(tasks))
-		for _, task := range tasks {
-			go func(task *model.Task) {
-				defer wg.Done()
-				job, err := w.clientset.BatchV1().Jobs(task.Namespace).Create(ctx, toJob(task), metav1.CreateOptions{})
-				if err != nil {
-					slog.Error(err.Error(), "Failed to create job")
-					return
-				}
-
-				task.Status = model.TaskStatusPending
-				if err := w.store.Tasks().Update(ctx, task); err != nil {
-					slog.Error(err.Error(), "Failed to update task status")
-					return
-				}
-				slog.Info("Successfully created job", "namespace", job.Namespace, "name", job.Name)
-			}(task)
-		}
-		wg.Wait()
+		w.admit(ctx)
 	}()
 
-	// NOTE: 同步中间状态的任务在 K8s 中的状态到表中
+	// NOTE: 同步中间状态的任务在执行器中的状态到表中
+	// 执行器支持推送时状态变化主要实时同步，这里只作为定期兜底
 	go func() {
 		defer w.wg.Done()
-		ctx := context.Background()
 
 		_, tasks, err := w.store.Tasks().List(ctx, meta.WithFilterNot(map[string]any{
 			// 排除这几个状态
-			"status": []model.TaskStatus{model.TaskStatusNormal, model.TaskStatusSucceeded, model.TaskStatusFailed},
+			"status": notInFlightStatuses,
 		}))
 		if err != nil {
-			slog.Error(err.Error(), "Failed to list tasks")
+			slog.Error("Failed to list tasks", "err", err)
 			return
 		}
 
@@ -93,18 +126,24 @@ func (w *taskWatcher) Run() {
 		for _, task := range tasks {
 			go func(task *model.Task) {
 				defer wg.Done()
-				job, err := w.clientset.BatchV1().Jobs(task.Namespace).Get(ctx, task.Name, metav1.GetOptions{})
+				exec, err := w.executor(task)
 				if err != nil {
-					slog.Error(err.Error(), "Failed to get task")
+					slog.Error("Failed to get executor", "err", err, "taskID", task.ID)
 					return
 				}
 
-				task.Status = toTaskStatus(job)
-				if err := w.store.Tasks().Update(ctx, task); err != nil {
-					slog.Error(err.Error(), "Failed to update task status")
+				if task.Status == model.TaskStatusCancelling {
+					w.finishCancel(ctx, exec, task)
+					return
+				}
+
+				state, err := exec.Status(ctx, task)
+				if err != nil {
+					slog.Error("Failed to get task status", "err", err, "taskID", task.ID)
 					return
 				}
-				slog.Info("Successfully sync job status to task", "namespace", job.Namespace, "name", job.Name, "status", task.Status)
+
+				w.syncTaskStatus(ctx, task, state)
 			}(task)
 		}
 		wg.Wait()
@@ -114,6 +153,420 @@ func (w *taskWatcher) Run() {
 	slog.Debug("Sync period is complete")
 }
 
+// admit 按调度策略的顺序检查等待启动的任务的并发配额，在配额内的任务交给执行器启动，超出配额的任务进入 Queued 状态
+func (w *taskWatcher) admit(ctx context.Context) {
+	// 到达重试时间的条件在表中过滤，未到时间的任务不会占用批次
+	waiting, err := w.waiting(ctx, meta.WithFilter(map[string]any{
+		"status": []model.TaskStatus{model.TaskStatusNormal, model.TaskStatusQueued},
+	}))
+	if err != nil {
+		slog.Error("Failed to list tasks", "err", err)
+		return
+	}
+	retrying, err := w.waiting(ctx,
+		meta.WithFilter(map[string]any{"status": model.TaskStatusRetrying}),
+		meta.WithCondition("retry_at", meta.OpLte, time.Now()),
+	)
+	if err != nil {
+		slog.Error("Failed to list tasks", "err", err)
+		return
+	}
+	// 两类任务中排在前面的任务都已读取，合并后由调度策略统一排序
+	candidates := append(waiting, retrying...)
+
+	usages, err := w.store.Tasks().CountByOwner(ctx, quotaStatuses)
+	if err != nil {
+		slog.Error("Failed to count running tasks", "err", err)
+		return
+	}
+	quota := newQuotaTracker(w.quota, usages)
+
+	var wg sync.WaitGroup
+	for _, task := range w.policy.Order(candidates, usages) {
+		if reason, ok := quota.admit(task); !ok {
+			if task.Status != model.TaskStatusQueued {
+				w.queueTask(ctx, task, reason)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.launch(ctx, task)
+		}()
+	}
+	wg.Wait()
+}
+
+// waiting 按调度策略的排序分页读取满足 opts 的任务，跳过上游任务还未全部成功的任务
+// 得到 admitBatchSize 个可以启动的任务后停止读取，调度策略需要一起排序的任务会继续读完
+func (w *taskWatcher) waiting(ctx context.Context, opts ...meta.ListOption) ([]*model.Task, error) {
+	order := w.policy.ListOrder()
+	var (
+		ret    []*model.Task
+		cursor string
+	)
+	for {
+		_, tasks, err := w.store.Tasks().List(ctx, slices.Concat(opts, []meta.ListOption{
+			meta.WithOrder(order),
+			meta.WithLimit(admitBatchSize),
+			meta.WithCursor(cursor),
+		})...)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range w.ready(ctx, tasks) {
+			if len(ret) >= admitBatchSize && !w.policy.Tied(ret[len(ret)-1], task) {
+				return ret, nil
+			}
+			ret = append(ret, task)
+		}
+		if len(tasks) < admitBatchSize {
+			return ret, nil
+		}
+		if cursor, err = store.TaskCursor(tasks[len(tasks)-1], order); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ready 并发检查上游任务，返回可以启动的任务，只有 Normal 状态的任务需要检查
+func (w *taskWatcher) ready(ctx context.Context, tasks []*model.Task) []*model.Task {
+	ok := make([]bool, len(tasks))
+	var wg sync.WaitGroup
+	wg.Add(len(tasks))
+	for i, task := range tasks {
+		go func() {
+			defer wg.Done()
+			ok[i] = task.Status != model.TaskStatusNormal || w.upstreamsSucceeded(ctx, task)
+		}()
+	}
+	wg.Wait()
+
+	ret := make([]*model.Task, 0, len(tasks))
+	for i, task := range tasks {
+		if ok[i] {
+			ret = append(ret, task)
+		}
+	}
+	return ret
+}
+
+// queueTask 将超出并发配额的任务标记为 Queued
+func (w *taskWatcher) queueTask(ctx context.Context, task *model.Task, reason string) {
+	from := task.Status
+	task.Status = model.TaskStatusQueued
+	event := model.NewTaskEvent(task, from, model.TaskEventReasonQuotaExceeded, reason)
+	ok, err := w.updateWithEvent(ctx, task, map[string]any{"status": from}, event)
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err)
+		return
+	}
+	if ok {
+		slog.Info("Task exceeds quota, queued", "taskID", task.ID, "reason", reason)
+	}
+}
+
+// launch 将任务交给执行器启动
+func (w *taskWatcher) launch(ctx context.Context, task *model.Task) {
+	exec, err := w.executor(task)
+	if err != nil {
+		slog.Error("Failed to get executor", "err", err, "taskID", task.ID)
+		return
+	}
+
+	// 提交前先将任务条件更新为 Pending，任务已被取消等并发修改时放弃提交
+	status, attempt, retryAt := task.Status, task.Attempt, task.RetryAt
+	task.Status = model.TaskStatusPending
+	task.Attempt++
+	task.RetryAt = nil
+	event := model.NewTaskEvent(task, status, model.TaskEventReasonSubmitted, "")
+	ok, err := w.updateWithEvent(ctx, task, map[string]any{"status": status, "attempt": attempt}, event)
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err)
+		return
+	}
+	if !ok {
+		slog.Info("Task has been changed concurrently, skip submitting", "taskID", task.ID)
+		return
+	}
+
+	if err := exec.Submit(ctx, task); err != nil {
+		if errors.Is(err, executor.ErrInvalidTask) || errors.Is(err, executor.ErrConflict) {
+			// 任务信息不合法，或者同名的执行实例属于其他任务、不是 nightwatch 创建的，重试也无法成功，直接标记为失败
+			slog.Error("Failed to submit task and cannot retry", "err", err, "taskID", task.ID)
+			w.failTask(ctx, task, err.Error())
+			return
+		}
+		slog.Error("Failed to submit task", "err", err, "taskID", task.ID)
+
+		// 恢复任务状态，等待下个周期重新提交
+		task.Status, task.Attempt, task.RetryAt = status, attempt, retryAt
+		event := model.NewTaskEvent(task, model.TaskStatusPending, model.TaskEventReasonSubmitFailed, err.Error())
+		if _, err := w.updateWithEvent(ctx, task, map[string]any{
+			"status":  model.TaskStatusPending,
+			"attempt": attempt + 1,
+		}, event); err != nil {
+			slog.Error("Failed to update task status", "err", err)
+		}
+		return
+	}
+	slog.Info("Successfully submitted task", "taskID", task.ID, "executor", task.ExecutorType(), "attempt", task.Attempt)
+
+	// 提交期间任务被取消时，取消可能在执行实例创建之前就已经完成，需要再次删除
+	w.cancelIfCancelled(ctx, exec, task)
+}
+
+// executor 返回任务对应的执行器
+func (w *taskWatcher) executor(task *model.Task) (executor.Executor, error) {
+	exec, ok := w.executors[task.ExecutorType()]
+	if !ok {
+		return nil, fmt.Errorf("executor %s is not available", task.ExecutorType())
+	}
+	return exec, nil
+}
+
+// onEvent 处理执行器推送的任务状态变化
+func (w *taskWatcher) onEvent(ctx context.Context, event *executor.Event) {
+	task, err := w.store.Tasks().Get(ctx, strconv.FormatInt(event.TaskID, 10))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("Failed to get task", "err", err, "taskID", event.TaskID)
+		}
+		return
+	}
+
+	// 忽略之前执行次数遗留的事件
+	if !isInFlight(task) || event.Attempt != task.Attempt {
+		return
+	}
+	w.syncTaskStatus(ctx, task, &event.State)
+}
+
+// failTask 在提交执行前将处于 Pending 状态的任务标记为失败
+func (w *taskWatcher) failTask(ctx context.Context, task *model.Task, message string) {
+	task.Status = model.TaskStatusFailed
+	task.RetryAt = nil
+	task.Attempts = append(task.Attempts, model.TaskAttempt{
+		Attempt:    task.Attempt,
+		Status:     model.TaskStatusFailed,
+		Message:    message,
+		FinishedAt: time.Now(),
+	})
+	event := model.NewTaskEvent(task, model.TaskStatusPending, model.TaskEventReasonInvalidTask, message)
+	ok, err := w.updateWithEvent(ctx, task, map[string]any{
+		"status":  model.TaskStatusPending,
+		"attempt": task.Attempt,
+	}, event)
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err)
+		return
+	}
+	if ok {
+		w.failDownstreams(ctx, task)
+	}
+}
+
+// syncTaskStatus 将执行状态同步到任务，状态未变化时不更新
+// 执行失败且任务还可以重试时，任务进入 Retrying 状态，等待退避时间后重新提交执行
+func (w *taskWatcher) syncTaskStatus(ctx context.Context, task *model.Task, state *executor.State) {
+	w.updateStatus(ctx, task, state, model.TaskEventReasonStatusSynced)
+}
+
+// updateStatus 与 syncTaskStatus 相同，reason 为记录到状态变化中的原因
+func (w *taskWatcher) updateStatus(ctx context.Context, task *model.Task, state *executor.State, reason string) {
+	attempt := task.Attempt
+	ok, err := w.updateTask(ctx, task, func(task *model.Task) *model.TaskEvent {
+		// 任务已经结束或者开始了新一次执行，执行状态已经过期
+		if !isInFlight(task) || task.Attempt != attempt || task.Status == state.Status {
+			return nil
+		}
+		// 正在取消的任务由 finishCancel 结束，忽略删除执行实例期间的状态变化
+		if task.Status == model.TaskStatusCancelling {
+			return nil
+		}
+
+		from, status := task.Status, state.Status
+		if status == model.TaskStatusSucceeded || status == model.TaskStatusFailed {
+			now := time.Now()
+			task.Attempts = append(task.Attempts, model.TaskAttempt{
+				Attempt:    task.Attempt,
+				JobName:    state.Name,
+				Status:     status,
+				Message:    state.Message,
+				FinishedAt: now,
+			})
+
+			if status == model.TaskStatusFailed && task.CanRetry() {
+				status = model.TaskStatusRetrying
+				retryAt := now.Add(task.RetryBackoff())
+				task.RetryAt = &retryAt
+			}
+		}
+		task.Status = status
+
+		event := model.NewTaskEvent(task, from, reason, state.Message)
+		event.Conditions = state.Conditions
+		return event
+	})
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err, "taskID", task.ID)
+		return
+	}
+	if !ok {
+		return
+	}
+	slog.Info("Successfully sync execution status to task", "taskID", task.ID, "name", state.Name, "status", task.Status)
+
+	if state.Status == model.TaskStatusSucceeded || state.Status == model.TaskStatusFailed {
+		w.saveResult(ctx, task)
+	}
+
+	if task.Status == model.TaskStatusFailed {
+		w.failDownstreams(ctx, task)
+	}
+}
+
+// finishCancel 删除正在取消的任务的执行实例，删除完成后将任务标记为 Cancelled
+func (w *taskWatcher) finishCancel(ctx context.Context, exec executor.Executor, task *model.Task) {
+	cancelCtx, cancel := context.WithTimeout(ctx, cancelTimeout)
+	err := exec.Cancel(cancelCtx, task)
+	cancel()
+	if err != nil {
+		slog.Warn("Task execution is still being deleted", "err", err, "taskID", task.ID, "attempt", task.Attempt)
+		return
+	}
+
+	attempt := task.Attempt
+	ok, err := w.updateTask(ctx, task, func(task *model.Task) *model.TaskEvent {
+		if task.Status != model.TaskStatusCancelling || task.Attempt != attempt {
+			return nil
+		}
+		task.Status = model.TaskStatusCancelled
+		task.Attempts = append(task.Attempts, model.TaskAttempt{
+			Attempt:    task.Attempt,
+			Status:     model.TaskStatusCancelled,
+			FinishedAt: time.Now(),
+		})
+		return model.NewTaskEvent(task, model.TaskStatusCancelling, model.TaskEventReasonCancelFinished, "")
+	})
+	if err != nil {
+		slog.Error("Failed to update task status", "err", err, "taskID", task.ID)
+		return
+	}
+	if ok {
+		slog.Info("Successfully cancelled task", "taskID", task.ID, "attempt", attempt)
+	}
+}
+
+// cancelIfCancelled 提交执行后重新读取任务，任务已经被取消时删除刚提交的执行实例
+func (w *taskWatcher) cancelIfCancelled(ctx context.Context, exec executor.Executor, task *model.Task) {
+	latest, err := w.store.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10))
+	if err != nil {
+		slog.Error("Failed to get task", "err", err, "taskID", task.ID)
+		return
+	}
+	if latest.Attempt != task.Attempt || (latest.Status != model.TaskStatusCancelling && latest.Status != model.TaskStatusCancelled) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cancelTimeout)
+	defer cancel()
+	if err := exec.Cancel(ctx, latest); err != nil {
+		slog.Warn("Failed to delete execution of cancelled task", "err", err, "taskID", task.ID)
+	}
+}
+
+// saveResult 保存任务本次执行的退出原因和最后的日志，失败时只记录日志，不影响任务状态
+func (w *taskWatcher) saveResult(ctx context.Context, task *model.Task) {
+	exec, err := w.executor(task)
+	if err != nil {
+		slog.Error("Failed to get executor", "err", err, "taskID", task.ID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resultTimeout)
+	defer cancel()
+	result, err := exec.Result(ctx, task)
+	if err != nil {
+		slog.Warn("Failed to get task result", "err", err, "taskID", task.ID, "attempt", task.Attempt)
+		return
+	}
+	if err := w.store.Tasks().CreateResult(ctx, result); err != nil {
+		slog.Error("Failed to save task result", "err", err, "taskID", task.ID, "attempt", task.Attempt)
+	}
+}
+
+// updateTask 使用 mutate 修改任务，并在同一事务中以乐观锁写入任务和 mutate 返回的状态变化记录，返回是否更新
+// informer 事件和定期同步会并发处理同一个任务，发生冲突时重新读取任务再次修改，mutate 返回 nil 表示不需要更新
+func (w *taskWatcher) updateTask(ctx context.Context, task *model.Task, mutate func(task *model.Task) *model.TaskEvent) (bool, error) {
+	updated := false
+	err := retry.OnError(retry.DefaultRetry, store.IsConflict, func() error {
+		event := mutate(task)
+		if event == nil {
+			return nil
+		}
+
+		err := w.store.TX(ctx, func(ctx context.Context) error {
+			if err := w.store.Tasks().Update(ctx, task); err != nil {
+				return err
+			}
+			if err := w.store.TaskEvents().Create(ctx, event); err != nil {
+				return err
+			}
+			return w.store.Notifications().Create(ctx, model.NewTaskNotifications(task, event)...)
+		})
+		if err == nil {
+			updated = true
+			return nil
+		}
+		if store.IsConflict(err) {
+			latest, getErr := w.store.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10))
+			if getErr != nil {
+				return getErr
+			}
+			*task = *latest
+		}
+		return err
+	})
+	return updated, err
+}
+
+// updateWithEvent 在同一事务中条件更新任务并记录状态变化，conds 与 UpdateWhere 相同
+func (w *taskWatcher) updateWithEvent(ctx context.Context, task *model.Task, conds map[string]any, event *model.TaskEvent) (bool, error) {
+	var ok bool
+	err := w.store.TX(ctx, func(ctx context.Context) error {
+		var err error
+		if ok, err = w.store.Tasks().UpdateWhere(ctx, task, conds); err != nil || !ok {
+			return err
+		}
+		if err := w.store.TaskEvents().Create(ctx, event); err != nil {
+			return err
+		}
+		return w.store.Notifications().Create(ctx, model.NewTaskNotifications(task, event)...)
+	})
+	return ok && err == nil, err
+}
+
+// 不需要同步执行状态的任务状态
+var notInFlightStatuses = []model.TaskStatus{
+	model.TaskStatusNormal,
+	model.TaskStatusSucceeded,
+	model.TaskStatusFailed,
+	model.TaskStatusCancelled,
+	model.TaskStatusRetrying,
+	model.TaskStatusQueued,
+	model.TaskStatusUpstreamFailed,
+}
+
+// isInFlight 判断任务是否已经提交执行且还未结束
+func isInFlight(task *model.Task) bool {
+	return !slices.Contains(notInFlightStatuses, task.Status)
+}
+
 func init() {
-	watcher.Register(&taskWatcher{})
+	w := &taskWatcher{}
+	watcher.Register(w)
+	watcher.Register(&gcWatcher{tasks: w})
 }
@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/internal/executor"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/store"
+)
+
+// fakeExecutor 记录提交的任务，不实际执行
+type fakeExecutor struct {
+	mu        sync.Mutex
+	submitted []int64
+	submitErr error // 提交返回的错误
+}
+
+func (e *fakeExecutor) Submit(ctx context.Context, task *model.Task) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.submitted = append(e.submitted, task.ID)
+	return e.submitErr
+}
+
+func (e *fakeExecutor) Status(ctx context.Context, task *model.Task) (*executor.State, error) {
+	return &executor.State{Status: task.Status}, nil
+}
+
+func (e *fakeExecutor) Cancel(ctx context.Context, task *model.Task) error {
+	return nil
+}
+
+func (e *fakeExecutor) Result(ctx context.Context, task *model.Task) (*model.TaskResult, error) {
+	return nil, executor.ErrNotFound
+}
+
+func TestAdmitBeyondBatch(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Minute)
+
+	// newStore 创建超过 admitBatchSize 个还不能启动的高优先级任务，以及排在它们之后的可以启动的任务
+	// 返回可以启动的任务 ID：用户 1 的低优先级任务、用户 2 到达重试时间的任务、用户 3 的中优先级任务
+	newStore := func(t *testing.T) (store.IStore, []int64, int64, int64) {
+		ctx := context.Background()
+		s := store.NewMemoryStore()
+		n := 0
+		create := func(userID int64, status model.TaskStatus, priority int, retryAt *time.Time) *model.Task {
+			n++
+			task := &model.Task{
+				Name:      fmt.Sprintf("task-%d", n),
+				Namespace: "demo",
+				UserID:    userID,
+				Status:    status,
+				Priority:  priority,
+				RetryAt:   retryAt,
+				CreatedAt: base.Add(time.Duration(n) * time.Second),
+			}
+			if err := s.Tasks().Create(ctx, task); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			return task
+		}
+
+		// 运行中的上游任务，占用一个并发配额
+		upstream := create(9, model.TaskStatusRunning, 0, nil)
+		for range admitBatchSize + 100 {
+			create(1, model.TaskStatusRetrying, 10, &later)
+		}
+		for range admitBatchSize + 100 {
+			task := create(1, model.TaskStatusNormal, 10, nil)
+			if err := s.Tasks().AddDependencies(ctx, task.ID, []int64{upstream.ID}); err != nil {
+				t.Fatalf("AddDependencies() error = %v", err)
+			}
+		}
+		var low []int64
+		for range admitBatchSize + 100 {
+			low = append(low, create(1, model.TaskStatusNormal, 0, nil).ID)
+		}
+		retrying := create(2, model.TaskStatusRetrying, 0, &earlier)
+		medium := create(3, model.TaskStatusNormal, 5, nil)
+		return s, low, retrying.ID, medium.ID
+	}
+
+	tests := []struct {
+		policy string
+		// want 根据可以启动的任务返回期望提交的任务
+		want func(low []int64, retrying, medium int64) []int64
+	}{
+		{
+			policy: policyFIFO,
+			want:   func(low []int64, _, _ int64) []int64 { return low[:3] },
+		},
+		{
+			policy: policyPriority,
+			want:   func(low []int64, _, medium int64) []int64 { return []int64{medium, low[0], low[1]} },
+		},
+		{
+			// 同一优先级内用户 1 和用户 2 交替启动
+			policy: policyFairShare,
+			want:   func(low []int64, retrying, medium int64) []int64 { return []int64{medium, low[0], retrying} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s, low, retrying, medium := newStore(t)
+			p, err := newPolicy(tt.policy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exec := &fakeExecutor{}
+			w := &taskWatcher{
+				store:     s,
+				executors: map[model.ExecutorType]executor.Executor{model.ExecutorKubernetes: exec},
+				quota:     model.TaskQuota{MaxRunning: 4},
+				policy:    p,
+			}
+
+			w.admit(context.Background())
+
+			want := tt.want(low, retrying, medium)
+			slices.Sort(want)
+			slices.Sort(exec.submitted)
+			if !slices.Equal(exec.submitted, want) {
+				t.Errorf("submitted = %v, want %v", exec.submitted, want)
+			}
+		})
+	}
+}
+
+func TestLaunchSubmitError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want model.TaskStatus
+	}{
+		{name: "invalid task", err: executor.ErrInvalidTask, want: model.TaskStatusFailed},
+		// 同名 Job 属于其他任务，重新提交也会冲突
+		{name: "conflict", err: fmt.Errorf("%w: job demo/task belongs to task 1 attempt 1", executor.ErrConflict), want: model.TaskStatusFailed},
+		// 其他错误在下个周期重新提交
+		{name: "transient", err: errors.New("connection refused"), want: model.TaskStatusNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemoryStore()
+			task := &model.Task{Name: "task", Namespace: "demo", Status: model.TaskStatusNormal}
+			if err := s.Tasks().Create(ctx, task); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			w := &taskWatcher{
+				store:     s,
+				executors: map[model.ExecutorType]executor.Executor{model.ExecutorKubernetes: &fakeExecutor{submitErr: tt.err}},
+			}
+
+			w.launch(ctx, task)
+
+			got, err := s.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10))
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("status = %s, want %s", got.Status, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package watcher
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 
@@ -13,9 +14,13 @@ const (
 	Every3Seconds = "@every 3s"
 )
 
+// Parser 解析支持秒级的 cron 表达式，Watcher 定时周期和定时任务共用
+var Parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 type Watcher interface {
 	Init(ctx context.Context, config *Config) error
-	cron.Job
+	// Run 执行一次 Watcher，ctx 携带当前 leader 任期的 fencing token，失去锁后被取消
+	Run(ctx context.Context)
 }
 
 type ISpec interface {
@@ -25,6 +30,35 @@ type ISpec interface {
 	Spec() string
 }
 
+// DefaultSpec 返回 Watcher 默认的定时周期，没有实现 ISpec 时每 3 秒执行一次
+func DefaultSpec(w Watcher) string {
+	if obj, ok := w.(ISpec); ok {
+		return obj.Spec()
+	}
+	return Every3Seconds
+}
+
+// Status Watcher 当前的调度状态
+type Status struct {
+	Name    string    `json:"name"`
+	Enabled bool      `json:"enabled"`
+	Spec    string    `json:"spec"`
+	Next    time.Time `json:"next"` // 下次执行时间，没有获取到锁或未启用时为零值
+	Prev    time.Time `json:"prev"` // 上次执行时间
+}
+
+// IStarter 由需要在后台常驻运行的 Watcher 实现，如 informer
+// nightWatch 获取锁后调用 Start，ctx 取消时需要退出
+type IStarter interface {
+	Start(ctx context.Context)
+}
+
+// IStopper 由持有需要在 nightWatch 停止时释放的资源的 Watcher 实现
+// nightWatch 在定时任务停止后、释放锁之前调用 Stop
+type IStopper interface {
+	Stop()
+}
+
 var (
 	registryLock = new(sync.Mutex)
 	registry     = make(map[string]Watcher)
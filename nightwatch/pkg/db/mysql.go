@@ -1,9 +1,11 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/spf13/pflag"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -21,6 +23,45 @@ type MySQLOptions struct {
 	Logger logger.Interface
 }
 
+// NewMySQLOptions 返回本地开发环境的默认 MySQL 配置
+func NewMySQLOptions() *MySQLOptions {
+	return &MySQLOptions{
+		Host:                  "127.0.0.1:33306",
+		Username:              "root",
+		Password:              "nightwatch",
+		Database:              "nightwatch",
+		MaxIdleConnections:    100,
+		MaxOpenConnections:    100,
+		MaxConnectionLifeTime: 10 * time.Second,
+	}
+}
+
+// AddFlags 将 MySQL 配置注册为 mysql. 开头的命令行参数
+func (o *MySQLOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Host, "mysql.host", o.Host, "MySQL service host address, host:port")
+	fs.StringVar(&o.Username, "mysql.username", o.Username, "Username for access to MySQL service")
+	fs.StringVar(&o.Password, "mysql.password", o.Password, "Password for access to MySQL service")
+	fs.StringVar(&o.Database, "mysql.database", o.Database, "Database name for the server to use")
+	fs.IntVar(&o.MaxIdleConnections, "mysql.max-idle-connections", o.MaxIdleConnections, "Maximum idle connections allowed to connect to MySQL")
+	fs.IntVar(&o.MaxOpenConnections, "mysql.max-open-connections", o.MaxOpenConnections, "Maximum open connections allowed to connect to MySQL")
+	fs.DurationVar(&o.MaxConnectionLifeTime, "mysql.max-connection-life-time", o.MaxConnectionLifeTime, "Maximum connection life time allowed to connect to MySQL")
+}
+
+// Validate 校验 MySQL 配置
+func (o *MySQLOptions) Validate() error {
+	var errs []error
+	if o.Host == "" {
+		errs = append(errs, errors.New("mysql.host is required"))
+	}
+	if o.Database == "" {
+		errs = append(errs, errors.New("mysql.database is required"))
+	}
+	if o.MaxIdleConnections < 0 || o.MaxOpenConnections < 0 || o.MaxConnectionLifeTime < 0 {
+		errs = append(errs, errors.New("mysql connection limits must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
 func (o *MySQLOptions) DSN() string {
 	return fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8&parseTime=%t&loc=%s`,
 		o.Username,
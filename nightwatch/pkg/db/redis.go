@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
 )
 
 type RedisOptions struct {
@@ -21,6 +23,49 @@ type RedisOptions struct {
 	PoolSize     int
 }
 
+// NewRedisOptions 返回本地开发环境的默认 Redis 配置
+func NewRedisOptions() *RedisOptions {
+	return &RedisOptions{
+		Addr:         "127.0.0.1:36379",
+		Password:     "nightwatch",
+		MaxRetries:   3,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  3 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		PoolSize:     10,
+	}
+}
+
+// AddFlags 将 Redis 配置注册为 redis. 开头的命令行参数
+func (o *RedisOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Addr, "redis.addr", o.Addr, "Redis service address, host:port")
+	fs.StringVar(&o.Username, "redis.username", o.Username, "Username for access to Redis service")
+	fs.StringVar(&o.Password, "redis.password", o.Password, "Password for access to Redis service")
+	fs.IntVar(&o.Database, "redis.database", o.Database, "Redis database to be selected after connecting to the server")
+	fs.IntVar(&o.MaxRetries, "redis.max-retries", o.MaxRetries, "Maximum number of retries before giving up")
+	fs.IntVar(&o.MinIdleConns, "redis.min-idle-conns", o.MinIdleConns, "Minimum number of idle connections")
+	fs.DurationVar(&o.DialTimeout, "redis.dial-timeout", o.DialTimeout, "Dial timeout for establishing new connections")
+	fs.DurationVar(&o.ReadTimeout, "redis.read-timeout", o.ReadTimeout, "Timeout for socket reads")
+	fs.DurationVar(&o.WriteTimeout, "redis.write-timeout", o.WriteTimeout, "Timeout for socket writes")
+	fs.DurationVar(&o.PoolTimeout, "redis.pool-timeout", o.PoolTimeout, "Time to wait for a connection if all connections are busy, 0 means ReadTimeout + 1s")
+	fs.IntVar(&o.PoolSize, "redis.pool-size", o.PoolSize, "Maximum number of socket connections")
+}
+
+// Validate 校验 Redis 配置
+func (o *RedisOptions) Validate() error {
+	var errs []error
+	if o.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if o.Database < 0 {
+		errs = append(errs, errors.New("redis.database must not be negative"))
+	}
+	if o.PoolSize < 0 || o.MinIdleConns < 0 || o.MaxRetries < -1 {
+		errs = append(errs, errors.New("redis connection limits must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
 func NewRedis(opts *RedisOptions) (*redis.Client, error) {
 	options := &redis.Options{
 		Addr:         opts.Addr,
@@ -0,0 +1,306 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/spf13/pflag"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/assets"
+)
+
+// sqliteDriverName 以 MySQL datetime 格式保存时间的 SQLite 驱动
+const sqliteDriverName = "nightwatch-sqlite3"
+
+// sqliteTimeLayout SQLite 中保存时间的格式，与 MySQL datetime 字面量相同，不带时区
+const sqliteTimeLayout = "2006-01-02 15:04:05.999999999"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{})
+}
+
+type SQLiteOptions struct {
+	// 数据库文件路径，为 :memory: 时数据只保存在内存中
+	Path string
+	// +optional
+	Logger logger.Interface
+}
+
+// NewSQLiteOptions 返回本地运行的默认 SQLite 配置
+func NewSQLiteOptions() *SQLiteOptions {
+	return &SQLiteOptions{Path: "nightwatch.db"}
+}
+
+// AddFlags 将 SQLite 配置注册为 sqlite. 开头的命令行参数
+func (o *SQLiteOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Path, "sqlite.path", o.Path, "Path of the SQLite database file, :memory: keeps data in memory only")
+}
+
+// Validate 校验 SQLite 配置
+func (o *SQLiteOptions) Validate() error {
+	if o.Path == "" {
+		return errors.New("sqlite.path is required")
+	}
+	return nil
+}
+
+func (o *SQLiteOptions) DSN() string {
+	return fmt.Sprintf("file:%s?_busy_timeout=5000", o.Path)
+}
+
+// NewSQLite 打开 SQLite 数据库，并按照 assets/schema.sql 创建缺少的表、列和索引
+func NewSQLite(opts *SQLiteOptions) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: sqliteDriverName, DSN: opts.DSN()}), &gorm.Config{
+		TranslateError: true,
+		Logger:         opts.Logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写事务，只使用一个连接使读写串行执行，:memory: 数据库也不会因为连接关闭而丢失
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db, assets.Schema); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to migrate SQLite schema: %w", err)
+	}
+	return db, nil
+}
+
+// sqliteDriver 与 MySQL 一样以本地时区的 datetime 文本保存时间
+// mattn/go-sqlite3 默认保存带时区偏移的文本，与游标、范围查询中的 datetime 字面量按文本比较时结果与 MySQL 不一致
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// CheckNamedValue 将时间参数转换为本地时区的 datetime 文本，其他参数按默认规则转换
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(time.Time); ok {
+		v = t.In(time.Local).Format(sqliteTimeLayout)
+	}
+	nv.Value = v
+	return nil
+}
+
+// QueryContext 驱动按 UTC 解析不带时区的时间，返回前改为本地时区，文本改为 []byte
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteRows{rows}, nil
+}
+
+type sqliteRows struct {
+	driver.Rows
+}
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		switch v := v.(type) {
+		case time.Time:
+			dest[i] = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.Local)
+		case string:
+			// 与 MySQL 驱动一致，文本以 []byte 返回，实现 sql.Scanner 的 JSON 字段依赖这一点
+			dest[i] = []byte(v)
+		}
+	}
+	return nil
+}
+
+// sqliteTable 由 MySQL 建表语句转换得到的 SQLite 表结构
+type sqliteTable struct {
+	name        string
+	columns     []sqliteColumn
+	constraints []string // 表级约束，如非自增主键
+	indexes     []string // 建索引语句
+}
+
+type sqliteColumn struct {
+	name       string
+	definition string // 包含列名的完整定义
+}
+
+// MySQL 列类型对应的 SQLite 类型，时间列声明为 DATETIME，驱动读取时才会解析为 time.Time
+var sqliteTypes = map[string]string{
+	"tinyint":    "INTEGER",
+	"int":        "INTEGER",
+	"bigint":     "INTEGER",
+	"varchar":    "TEXT",
+	"text":       "TEXT",
+	"mediumtext": "TEXT",
+	"datetime":   "DATETIME",
+}
+
+var (
+	createTableRe   = regexp.MustCompile("(?is)^CREATE TABLE (?:IF NOT EXISTS )?`(\\w+)`\\s*\\((.*)\\)[^)]*$")
+	columnRe        = regexp.MustCompile("^`(\\w+)`\\s+(\\w+)(?:\\(\\d+\\))?(.*)$")
+	keyRe           = regexp.MustCompile("(?i)^(PRIMARY |UNIQUE )?KEY (?:`(\\w+)` )?\\((.+)\\)$")
+	commentRe       = regexp.MustCompile(`(?i)\s+COMMENT\s+'(?:[^'\\]|\\.|'')*'`)
+	autoIncrementRe = regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`)
+	onUpdateRe      = regexp.MustCompile(`(?i)\s+ON UPDATE CURRENT_TIMESTAMP(?:\(\d*\))?`)
+	// SQLite 的 CURRENT_TIMESTAMP 为 UTC 时间，改为本地时间与其他时间列保持一致
+	currentTimeRe = regexp.MustCompile(`(?i)CURRENT_TIMESTAMP(?:\(\d*\))?`)
+)
+
+// parseSchema 将 MySQL 建表语句转换为 SQLite 表结构，忽略建库语句和测试数据
+// 只支持 schema.sql 中使用的列类型和语法，语句之间以分号分隔
+func parseSchema(schema string) ([]*sqliteTable, error) {
+	var lines []string
+	for _, line := range strings.Split(schema, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var tables []*sqliteTable
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		stmt = strings.TrimSpace(stmt)
+		if !strings.HasPrefix(strings.ToUpper(stmt), "CREATE TABLE") {
+			continue
+		}
+		m := createTableRe.FindStringSubmatch(stmt)
+		if m == nil {
+			return nil, fmt.Errorf("invalid create table statement: %.40q", stmt)
+		}
+		table, err := parseTable(m[1], m[2])
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", m[1], err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// parseTable 转换建表语句括号中的列定义和索引，每行一项
+func parseTable(name, body string) (*sqliteTable, error) {
+	table := &sqliteTable{name: name}
+	autoIncrement := false
+	var primaryKey string
+	for _, item := range strings.Split(body, "\n") {
+		item = strings.TrimSuffix(strings.TrimSpace(item), ",")
+		if item == "" {
+			continue
+		}
+
+		if m := keyRe.FindStringSubmatch(item); m != nil {
+			switch strings.ToUpper(strings.TrimSpace(m[1])) {
+			case "PRIMARY":
+				primaryKey = m[3]
+			case "UNIQUE":
+				table.indexes = append(table.indexes, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS `%s_%s` ON `%s` (%s)", name, m[2], name, m[3]))
+			default:
+				table.indexes = append(table.indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s_%s` ON `%s` (%s)", name, m[2], name, m[3]))
+			}
+			continue
+		}
+
+		m := columnRe.FindStringSubmatch(item)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported definition: %q", item)
+		}
+		typ, ok := sqliteTypes[strings.ToLower(m[2])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported column type %q", m[2])
+		}
+		rest := commentRe.ReplaceAllString(m[3], "")
+		rest = onUpdateRe.ReplaceAllString(rest, "")
+		rest = currentTimeRe.ReplaceAllString(rest, "(datetime('now', 'localtime'))")
+		// SQLite 只有 INTEGER PRIMARY KEY 列可以自增
+		if autoIncrementRe.MatchString(rest) {
+			autoIncrement = true
+			rest = autoIncrementRe.ReplaceAllString(rest, "PRIMARY KEY AUTOINCREMENT")
+		}
+		table.columns = append(table.columns, sqliteColumn{name: m[1], definition: fmt.Sprintf("`%s` %s%s", m[1], typ, rest)})
+	}
+
+	if primaryKey != "" && !autoIncrement {
+		table.constraints = append(table.constraints, fmt.Sprintf("PRIMARY KEY (%s)", primaryKey))
+	}
+	return table, nil
+}
+
+// createStatement 返回建表语句
+func (t *sqliteTable) createStatement() string {
+	var defs []string
+	for _, c := range t.columns {
+		defs = append(defs, c.definition)
+	}
+	defs = append(defs, t.constraints...)
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n  %s\n)", t.name, strings.Join(defs, ",\n  "))
+}
+
+// migrate 表不存在时建表，已经存在时添加缺少的列，然后创建缺少的索引
+func (t *sqliteTable) migrate(tx *gorm.DB) error {
+	var existing []string
+	if err := tx.Raw("SELECT name FROM pragma_table_info(?)", t.name).Scan(&existing).Error; err != nil {
+		return err
+	}
+
+	stmts := []string{t.createStatement()}
+	if len(existing) > 0 {
+		stmts = nil
+		for _, c := range t.columns {
+			if !slices.ContainsFunc(existing, func(name string) bool { return strings.EqualFold(name, c.name) }) {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", t.name, c.definition))
+			}
+		}
+	}
+	for _, stmt := range append(stmts, t.indexes...) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// migrateSQLite 在一个事务中按照 MySQL 建表语句 schema 创建或补全 SQLite 中的表
+func migrateSQLite(db *gorm.DB, schema string) error {
+	tables, err := parseSchema(schema)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			if err := table.migrate(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/assets"
+)
+
+func TestParseSchema(t *testing.T) {
+	tables, err := parseSchema(assets.Schema)
+	if err != nil {
+		t.Fatalf("parseSchema() error = %v", err)
+	}
+	byName := make(map[string]*sqliteTable)
+	for _, table := range tables {
+		byName[table.name] = table
+	}
+	if len(byName) != 8 {
+		t.Fatalf("parsed %d tables, want 8", len(byName))
+	}
+
+	task := byName["task"].createStatement()
+	for _, want := range []string{"`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT", "`retry_at` DATETIME DEFAULT NULL", "`updated_at` DATETIME NOT NULL DEFAULT (datetime('now', 'localtime'))"} {
+		if !strings.Contains(task, want) {
+			t.Errorf("task table %q does not contain %q", task, want)
+		}
+	}
+	for _, unwanted := range []string{"COMMENT", "ENGINE", "ON UPDATE", "KEY `"} {
+		if strings.Contains(task, unwanted) {
+			t.Errorf("task table %q contains %q", task, unwanted)
+		}
+	}
+	if got := strings.Join(byName["task"].indexes, "; "); got != "CREATE UNIQUE INDEX IF NOT EXISTS `task_uk_name_namespace` ON `task` (`name`, `namespace`); CREATE INDEX IF NOT EXISTS `task_idx_schedule_id` ON `task` (`schedule_id`)" {
+		t.Errorf("task indexes = %s", got)
+	}
+	if got := byName["fencing_token"].constraints; len(got) != 1 || got[0] != "PRIMARY KEY (`name`)" {
+		t.Errorf("fencing_token constraints = %v", got)
+	}
+
+	if _, err := parseSchema("CREATE TABLE `t` (\n  `id` json NOT NULL\n);"); err == nil {
+		t.Error("parseSchema() with unsupported column type should fail")
+	}
+}
+
+func TestNewSQLiteMigrate(t *testing.T) {
+	opts := &SQLiteOptions{Path: filepath.Join(t.TempDir(), "nightwatch.db"), Logger: logger.Discard}
+	db, err := NewSQLite(opts)
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	// 模拟旧版本创建的表，缺少后来新增的字段
+	if err := db.Exec("DROP TABLE `task`").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE TABLE `task` (`id` INTEGER PRIMARY KEY AUTOINCREMENT, `name` TEXT NOT NULL DEFAULT '')").Error; err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	_ = sqlDB.Close()
+
+	// 重新打开时补齐缺少的字段，已有的表和索引不受影响
+	db, err = NewSQLite(opts)
+	if err != nil {
+		t.Fatalf("NewSQLite() reopen error = %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		_ = sqlDB.Close()
+	}()
+	var columns []string
+	if err := db.Raw("SELECT name FROM pragma_table_info('task')").Scan(&columns).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 19 || columns[0] != "id" || columns[len(columns)-1] != "updated_at" {
+		t.Errorf("task columns = %v", columns)
+	}
+}
@@ -0,0 +1,150 @@
+package meta
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidOption 查询参数不合法，如不允许排序的字段、与排序方式不匹配的游标
+var ErrInvalidOption = errors.New("invalid list option")
+
+// Operator 查询条件的比较运算符
+type Operator string
+
+const (
+	OpEq    Operator = "="
+	OpNe    Operator = "<>"
+	OpGt    Operator = ">"
+	OpGte   Operator = ">="
+	OpLt    Operator = "<"
+	OpLte   Operator = "<="
+	OpIn    Operator = "IN"     // 值为切片
+	OpNotIn Operator = "NOT IN" // 值为切片
+	OpLike  Operator = "LIKE"   // 值为包含通配符的字符串，以 \ 转义
+)
+
+// Condition 字段与值的比较条件，多个条件之间为 AND 关系
+type Condition struct {
+	Field string
+	Op    Operator
+	Value any
+}
+
+// WithCondition 追加一个比较条件
+func WithCondition(field string, op Operator, value any) ListOption {
+	return func(o *ListOptions) {
+		o.Conditions = append(o.Conditions, Condition{Field: field, Op: op, Value: value})
+	}
+}
+
+// WithRange 追加 [from, to) 范围条件，from 或 to 为 nil 时不限制对应的边界
+func WithRange(field string, from, to any) ListOption {
+	return func(o *ListOptions) {
+		if from != nil {
+			o.Conditions = append(o.Conditions, Condition{Field: field, Op: OpGte, Value: from})
+		}
+		if to != nil {
+			o.Conditions = append(o.Conditions, Condition{Field: field, Op: OpLt, Value: to})
+		}
+	}
+}
+
+// WithContains 追加字段包含 s 的 LIKE 条件，s 中的通配符会被转义
+func WithContains(field, s string) ListOption {
+	return WithCondition(field, OpLike, "%"+EscapeLike(s)+"%")
+}
+
+// EscapeLike 转义 LIKE 中的通配符
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// 排序字段名称只能由小写字母、数字和下划线组成
+var orderFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// OrderBy 排序字段及方向
+type OrderBy struct {
+	Field string
+	Desc  bool
+}
+
+func (o OrderBy) String() string {
+	if o.Desc {
+		return o.Field + " desc"
+	}
+	return o.Field + " asc"
+}
+
+// ParseOrder 解析 "field [asc|desc], ..." 格式的排序参数，只校验格式，允许排序的字段由调用方校验
+func ParseOrder(order string) ([]OrderBy, error) {
+	var ret []OrderBy
+	for _, item := range strings.Split(order, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("%w: order %q", ErrInvalidOption, order)
+		}
+		if !orderFieldPattern.MatchString(parts[0]) {
+			return nil, fmt.Errorf("%w: order field %q", ErrInvalidOption, parts[0])
+		}
+		o := OrderBy{Field: parts[0]}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				o.Desc = true
+			default:
+				return nil, fmt.Errorf("%w: order direction %q", ErrInvalidOption, parts[1])
+			}
+		}
+		ret = append(ret, o)
+	}
+	return ret, nil
+}
+
+// FormatOrder 将排序字段格式化为 ParseOrder 能够解析的字符串
+func FormatOrder(orders []OrderBy) string {
+	items := make([]string, 0, len(orders))
+	for _, o := range orders {
+		items = append(items, o.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+// Cursor 游标分页的位置，记录排序方式以及上一页最后一条记录的排序字段值
+type Cursor struct {
+	Order  string `json:"order"`
+	Values []any  `json:"values"`
+}
+
+// Encode 将游标编码为可以在 URL 中传递的字符串
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor 解码 Cursor.Encode 返回的字符串
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor: %w", ErrInvalidOption, err)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	// 保留数字的原始格式，避免较大的 ID 损失精度
+	dec.UseNumber()
+	var c Cursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: cursor: %w", ErrInvalidOption, err)
+	}
+	return &c, nil
+}
+
+// WithCursor 从游标的位置继续查询，设置游标时忽略 Offset
+func WithCursor(cursor string) ListOption {
+	return func(o *ListOptions) {
+		o.Cursor = cursor
+	}
+}
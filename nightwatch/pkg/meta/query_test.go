@@ -0,0 +1,52 @@
+package meta
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		order   string
+		want    string
+		wantErr bool
+	}{
+		{order: "id", want: "id asc"},
+		{order: "created_at DESC, id", want: "created_at desc, id asc"},
+		{order: "", wantErr: true},
+		{order: "id,", wantErr: true},
+		{order: "`id`", wantErr: true},
+		{order: "id; drop table task", wantErr: true},
+		{order: "(select 1)", wantErr: true},
+	}
+	for _, tt := range tests {
+		orders, err := ParseOrder(tt.order)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseOrder(%q) error = %v, wantErr %v", tt.order, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("ParseOrder(%q) error = %v, want %v", tt.order, err, ErrInvalidOption)
+		}
+		if got := FormatOrder(orders); err == nil && got != tt.want {
+			t.Fatalf("ParseOrder(%q) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := &Cursor{Order: "created_at desc, id desc", Values: []any{"2024-01-01 00:00:00", int64(9007199254740993)}}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if got.Order != c.Order || got.Values[0] != "2024-01-01 00:00:00" || got.Values[1] != json.Number("9007199254740993") {
+		t.Fatalf("DecodeCursor() = %+v, want %+v", got, c)
+	}
+
+	for _, s := range []string{"%%%", "bm90IGpzb24"} {
+		if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("DecodeCursor(%q) error = %v, want %v", s, err, ErrInvalidOption)
+		}
+	}
+}
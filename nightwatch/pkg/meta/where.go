@@ -9,11 +9,13 @@ const (
 type ListOption func(*ListOptions)
 
 type ListOptions struct {
-	Filters map[string]any
-	Not     map[string]any
-	Offset  int
-	Limit   int
-	Order   string
+	Filters    map[string]any
+	Not        map[string]any
+	Conditions []Condition
+	Offset     int
+	Limit      int
+	Order      string
+	Cursor     string // Cursor.Encode 返回的游标，为空时使用 Offset 分页
 }
 
 func NewListOptions(opts ...ListOption) ListOptions {
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+const TableNameFencingToken = "fencing_token"
+
+// FencingToken 记录每把分布式锁最新一任 leader 的 fencing token，每次获取锁后递增
+type FencingToken struct {
+	Name      string    `gorm:"column:name;primaryKey" json:"name"`  // 锁名称
+	Token     int64     `gorm:"column:token" json:"token"`           // 最新的 fencing token
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"` // 修改时间
+}
+
+func (*FencingToken) TableName() string {
+	return TableNameFencingToken
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BackoffStrategy 任务重试退避策略
+type BackoffStrategy string
+
+const (
+	BackoffStrategyFixed       BackoffStrategy = "Fixed"       // 每次重试间隔固定
+	BackoffStrategyExponential BackoffStrategy = "Exponential" // 重试间隔按 2 的幂增长
+)
+
+// 重试间隔上限
+const maxBackoff = time.Hour
+
+// TaskAttempt 记录任务单次执行的结果
+type TaskAttempt struct {
+	Attempt    int        `json:"attempt"`
+	JobName    string     `json:"job_name"`
+	Status     TaskStatus `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
+
+type TaskAttempts []TaskAttempt
+
+// Scan implements the [Scanner] interface.
+func (ta *TaskAttempts) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), ta)
+}
+
+// Value implements the [driver.Valuer] interface.
+func (ta TaskAttempts) Value() (driver.Value, error) {
+	if ta == nil {
+		ta = TaskAttempts{}
+	}
+	bytes, err := json.Marshal(ta)
+	return string(bytes), err
+}
+
+// CanRetry 判断任务失败后是否还可以重试
+func (t *Task) CanRetry() bool {
+	maxAttempts := t.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	return t.Attempt < maxAttempts
+}
+
+// RetryBackoff 计算当前执行失败后，距离下次重试的等待时长
+func (t *Task) RetryBackoff() time.Duration {
+	backoff := time.Duration(t.BackoffSeconds) * time.Second
+	if t.BackoffStrategy == BackoffStrategyExponential {
+		for i := 1; i < t.Attempt && backoff < maxBackoff; i++ {
+			backoff *= 2
+		}
+	}
+	return min(backoff, maxBackoff)
+}
+
+// ValidateRetryPolicy 校验任务重试策略
+func (t *Task) ValidateRetryPolicy(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if t.MaxAttempts < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("max_attempts"), t.MaxAttempts, "must be greater than or equal to 0"))
+	}
+	if t.BackoffSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("backoff_seconds"), t.BackoffSeconds, "must be greater than or equal to 0"))
+	}
+	switch t.BackoffStrategy {
+	case "", BackoffStrategyFixed, BackoffStrategyExponential:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("backoff_strategy"), t.BackoffStrategy,
+			[]BackoffStrategy{BackoffStrategyFixed, BackoffStrategyExponential}))
+	}
+	return allErrs
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskCanRetry(t *testing.T) {
+	tests := []struct {
+		maxAttempts int
+		attempt     int
+		want        bool
+	}{
+		{maxAttempts: 0, attempt: 0, want: true},
+		{maxAttempts: 0, attempt: 1, want: false},
+		{maxAttempts: 1, attempt: 1, want: false},
+		{maxAttempts: 3, attempt: 1, want: true},
+		{maxAttempts: 3, attempt: 2, want: true},
+		{maxAttempts: 3, attempt: 3, want: false},
+		{maxAttempts: 3, attempt: 4, want: false},
+	}
+	for _, tt := range tests {
+		task := &Task{MaxAttempts: tt.maxAttempts, Attempt: tt.attempt}
+		if got := task.CanRetry(); got != tt.want {
+			t.Fatalf("CanRetry() with max_attempts %d and attempt %d = %v, want %v", tt.maxAttempts, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestTaskRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy BackoffStrategy
+		seconds  int
+		attempt  int
+		want     time.Duration
+	}{
+		{name: "fixed", strategy: BackoffStrategyFixed, seconds: 10, attempt: 5, want: 10 * time.Second},
+		{name: "default strategy is fixed", seconds: 10, attempt: 5, want: 10 * time.Second},
+		{name: "no backoff", strategy: BackoffStrategyExponential, seconds: 0, attempt: 5, want: 0},
+		{name: "exponential first attempt", strategy: BackoffStrategyExponential, seconds: 10, attempt: 1, want: 10 * time.Second},
+		{name: "exponential third attempt", strategy: BackoffStrategyExponential, seconds: 10, attempt: 3, want: 40 * time.Second},
+		{name: "exponential capped", strategy: BackoffStrategyExponential, seconds: 10, attempt: 20, want: maxBackoff},
+		{name: "exponential no overflow", strategy: BackoffStrategyExponential, seconds: 10, attempt: 1000, want: maxBackoff},
+		{name: "fixed capped", strategy: BackoffStrategyFixed, seconds: 7200, attempt: 1, want: maxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{BackoffStrategy: tt.strategy, BackoffSeconds: tt.seconds, Attempt: tt.attempt}
+			if got := task.RetryBackoff(); got != tt.want {
+				t.Fatalf("RetryBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskValidateRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr bool
+	}{
+		{name: "default", task: Task{}},
+		{name: "exponential", task: Task{MaxAttempts: 3, BackoffStrategy: BackoffStrategyExponential, BackoffSeconds: 10}},
+		{name: "negative max attempts", task: Task{MaxAttempts: -1}, wantErr: true},
+		{name: "negative backoff seconds", task: Task{BackoffSeconds: -1}, wantErr: true},
+		{name: "unsupported strategy", task: Task{BackoffStrategy: "Linear"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.task.ValidateRetryPolicy(nil); (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("ValidateRetryPolicy() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
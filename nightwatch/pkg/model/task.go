@@ -17,27 +17,89 @@ const (
 	TaskStatusSucceeded TaskStatus = "Succeeded"
 	TaskStatusFailed    TaskStatus = "Failed"
 	TaskStatusUnknown   TaskStatus = "Unknown"
+	TaskStatusCancelled TaskStatus = "Cancelled"
+	TaskStatusRetrying  TaskStatus = "Retrying"
+	// 已经请求取消，等待执行实例删除完成后变为 Cancelled
+	TaskStatusCancelling TaskStatus = "Cancelling"
+	// 超出并发配额，等待其他任务结束后再启动
+	TaskStatusQueued TaskStatus = "Queued"
+	// 上游依赖任务执行失败，任务不会再启动
+	TaskStatusUpstreamFailed TaskStatus = "UpstreamFailed"
+)
+
+// ExecutorType 任务执行器类型
+type ExecutorType string
+
+const (
+	ExecutorKubernetes ExecutorType = "kubernetes" // 以 K8s Job 的形式执行
+	ExecutorLocal      ExecutorType = "local"      // 以 nightwatch 本地进程的形式执行
 )
 
 type Task struct {
-	ID        int64      `gorm:"column:id" json:"id"`                 // 任务 ID
-	Name      string     `gorm:"column:name" json:"name"`             // 任务名称
-	Namespace string     `gorm:"column:namespace" json:"namespace"`   // 任务 k8s namespace 名称
-	Info      TaskInfo   `gorm:"column:info" json:"info"`             // 任务 k8s 相关信息
-	Status    TaskStatus `gorm:"column:status" json:"status"`         // 任务状态
-	UserID    int64      `gorm:"column:user_id" json:"user_id"`       // 用户 ID
-	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"` // 创建时间
-	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"` // 修改时间
+	ID              int64               `gorm:"column:id" json:"id"`                                 // 任务 ID
+	Name            string              `gorm:"column:name" json:"name"`                             // 任务名称
+	Namespace       string              `gorm:"column:namespace" json:"namespace"`                   // 任务 k8s namespace 名称
+	Info            TaskInfo            `gorm:"column:info" json:"info"`                             // 任务 k8s 相关信息
+	Status          TaskStatus          `gorm:"column:status" json:"status"`                         // 任务状态
+	UserID          int64               `gorm:"column:user_id" json:"user_id"`                       // 用户 ID
+	Priority        int                 `gorm:"column:priority" json:"priority"`                     // 优先级，值越大越先启动
+	Executor        ExecutorType        `gorm:"column:executor" json:"executor"`                     // 任务执行器
+	MaxAttempts     int                 `gorm:"column:max_attempts" json:"max_attempts"`             // 最大执行次数，包含首次执行
+	BackoffStrategy BackoffStrategy     `gorm:"column:backoff_strategy" json:"backoff_strategy"`     // 重试退避策略
+	BackoffSeconds  int                 `gorm:"column:backoff_seconds" json:"backoff_seconds"`       // 重试退避基础时长
+	Attempt         int                 `gorm:"column:attempt" json:"attempt"`                       // 当前执行次数
+	RetryAt         *time.Time          `gorm:"column:retry_at" json:"retry_at"`                     // 下次重试时间
+	Attempts        TaskAttempts        `gorm:"column:attempts" json:"attempts"`                     // 每次执行的结果
+	ScheduleID      *int64              `gorm:"column:schedule_id" json:"schedule_id,omitempty"`     // 创建任务的定时任务 ID
+	Notifications   NotificationTargets `gorm:"column:notifications" json:"notifications,omitempty"` // 任务结束时的通知目标
+	ResourceVersion int64               `gorm:"column:resource_version" json:"resource_version"`     // 乐观锁版本号，每次更新递增
+	CreatedAt       time.Time           `gorm:"column:created_at" json:"created_at"`                 // 创建时间
+	UpdatedAt       time.Time           `gorm:"column:updated_at" json:"updated_at"`                 // 修改时间
 }
 
 func (*Task) TableName() string {
 	return TableNameTask
 }
 
+// Rerun 返回以相同配置重新执行任务的名称为 name 的新任务，不包含执行记录和依赖关系
+func (t *Task) Rerun(name string) *Task {
+	return &Task{
+		Name:            name,
+		Namespace:       t.Namespace,
+		Info:            t.Info,
+		Status:          TaskStatusNormal,
+		UserID:          t.UserID,
+		Priority:        t.Priority,
+		Executor:        t.Executor,
+		MaxAttempts:     t.MaxAttempts,
+		BackoffStrategy: t.BackoffStrategy,
+		BackoffSeconds:  t.BackoffSeconds,
+		Notifications:   t.Notifications,
+	}
+}
+
+// ExecutorType 返回任务的执行器类型，未指定时默认使用 K8s
+func (t *Task) ExecutorType() ExecutorType {
+	if t.Executor == "" {
+		return ExecutorKubernetes
+	}
+	return t.Executor
+}
+
 type TaskInfo struct {
-	Image   string   `json:"image"`
-	Command []string `json:"command"`
-	Args    []string `json:"args"`
+	Image                   string            `json:"image"`
+	Command                 []string          `json:"command"`
+	Args                    []string          `json:"args"`
+	Env                     []EnvVar          `json:"env,omitempty"`                        // 环境变量
+	ConfigMapRefs           []string          `json:"config_map_refs,omitempty"`            // 以环境变量注入的 ConfigMap 名称
+	SecretRefs              []string          `json:"secret_refs,omitempty"`                // 以环境变量注入的 Secret 名称
+	Volumes                 []Volume          `json:"volumes,omitempty"`                    // 挂载的卷
+	Resources               Resources         `json:"resources,omitempty"`                  // CPU、内存资源
+	NodeSelector            map[string]string `json:"node_selector,omitempty"`              // 节点选择器
+	Tolerations             []Toleration      `json:"tolerations,omitempty"`                // 污点容忍
+	ServiceAccount          string            `json:"service_account,omitempty"`            // Pod 使用的 ServiceAccount
+	ActiveDeadlineSeconds   *int64            `json:"active_deadline_seconds,omitempty"`    // 单次执行的最长时间
+	TTLSecondsAfterFinished *int32            `json:"ttl_seconds_after_finished,omitempty"` // Job 结束后自动清理的时间
 }
 
 // Scan implements the [Scanner] interface.
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+const TableNameTaskDependency = "task_dependency"
+
+// TaskDependency 任务依赖关系，TaskID 对应的任务需要等 DependsOnID 对应的任务执行成功后才能启动
+type TaskDependency struct {
+	ID          int64     `gorm:"column:id" json:"id"`                       // 主键 ID
+	TaskID      int64     `gorm:"column:task_id" json:"task_id"`             // 下游任务 ID
+	DependsOnID int64     `gorm:"column:depends_on_id" json:"depends_on_id"` // 上游任务 ID
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`       // 创建时间
+}
+
+func (*TaskDependency) TableName() string {
+	return TableNameTaskDependency
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+const TableNameTaskEvent = "task_event"
+
+// 任务状态变化的原因
+const (
+	TaskEventReasonSubmitted      = "Submitted"      // 提交执行
+	TaskEventReasonSubmitFailed   = "SubmitFailed"   // 提交失败，等待重新提交
+	TaskEventReasonQuotaExceeded  = "QuotaExceeded"  // 超出并发配额，等待其他任务结束
+	TaskEventReasonInvalidTask    = "InvalidTask"    // 任务信息不合法
+	TaskEventReasonStatusSynced   = "StatusSynced"   // 同步执行器中的执行状态
+	TaskEventReasonUpstreamFailed = "UpstreamFailed" // 上游任务无法执行成功
+	TaskEventReasonCancelled      = "Cancelled"      // 用户取消
+	TaskEventReasonReplaced       = "Replaced"       // 被定时任务新创建的任务替换
+	TaskEventReasonCancelFinished = "CancelFinished" // 执行实例已删除，取消完成
+	TaskEventReasonExecutionLost  = "ExecutionLost"  // 执行实例已经消失，无法获取执行结果
+)
+
+// TaskCondition 执行器中执行实例的状况，如 Job 的 Conditions
+type TaskCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"last_transition_time"`
+}
+
+type TaskConditions []TaskCondition
+
+// Scan implements the [Scanner] interface.
+func (tc *TaskConditions) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), tc)
+}
+
+// Value implements the [driver.Valuer] interface.
+func (tc TaskConditions) Value() (driver.Value, error) {
+	if tc == nil {
+		tc = TaskConditions{}
+	}
+	bytes, err := json.Marshal(tc)
+	return string(bytes), err
+}
+
+// TaskEvent 任务状态变化记录，按时间顺序组成任务的时间线
+type TaskEvent struct {
+	ID         int64          `gorm:"column:id" json:"id"`                           // 主键 ID
+	TaskID     int64          `gorm:"column:task_id" json:"task_id"`                 // 任务 ID
+	Attempt    int            `gorm:"column:attempt" json:"attempt"`                 // 状态变化时的执行次数
+	FromStatus TaskStatus     `gorm:"column:from_status" json:"from_status"`         // 变化前的状态
+	ToStatus   TaskStatus     `gorm:"column:to_status" json:"to_status"`             // 变化后的状态
+	Reason     string         `gorm:"column:reason" json:"reason"`                   // 变化原因
+	Message    string         `gorm:"column:message" json:"message,omitempty"`       // 详细信息
+	Conditions TaskConditions `gorm:"column:conditions" json:"conditions,omitempty"` // 执行实例的状况
+	CreatedAt  time.Time      `gorm:"column:created_at" json:"created_at"`           // 创建时间
+}
+
+func (*TaskEvent) TableName() string {
+	return TableNameTaskEvent
+}
+
+// NewTaskEvent 创建任务从 from 变化为当前状态的记录
+func NewTaskEvent(task *Task, from TaskStatus, reason, message string) *TaskEvent {
+	return &TaskEvent{
+		TaskID:     task.ID,
+		Attempt:    task.Attempt,
+		FromStatus: from,
+		ToStatus:   task.Status,
+		Reason:     reason,
+		Message:    message,
+	}
+}
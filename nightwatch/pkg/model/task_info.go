@@ -0,0 +1,230 @@
+package model
+
+import (
+	"path"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// EnvVar 容器环境变量
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Volume 挂载到容器中的卷，ConfigMap、Secret、PersistentVolumeClaim、EmptyDir 必须且只能指定一个
+type Volume struct {
+	Name                  string `json:"name"`
+	MountPath             string `json:"mount_path"`
+	ReadOnly              bool   `json:"read_only,omitempty"`
+	ConfigMap             string `json:"config_map,omitempty"`
+	Secret                string `json:"secret,omitempty"`
+	PersistentVolumeClaim string `json:"persistent_volume_claim,omitempty"`
+	EmptyDir              bool   `json:"empty_dir,omitempty"`
+}
+
+// ResourceList CPU、内存资源量，格式同 K8s，如 500m、1Gi
+type ResourceList struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// Resources 容器资源请求和限制
+type Resources struct {
+	Requests ResourceList `json:"requests,omitempty"`
+	Limits   ResourceList `json:"limits,omitempty"`
+}
+
+// Toleration Pod 污点容忍
+type Toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"` // Exists 或 Equal，默认 Equal
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"` // NoSchedule、PreferNoSchedule 或 NoExecute，为空时匹配所有
+	TolerationSeconds *int64 `json:"toleration_seconds,omitempty"`
+}
+
+var (
+	supportedTolerationOperators = sets.New("", "Exists", "Equal")
+	supportedTolerationEffects   = sets.New("", "NoSchedule", "PreferNoSchedule", "NoExecute")
+)
+
+// Validate 校验任务 k8s 相关信息，K8s 执行器要求指定镜像，本地执行器要求指定命令
+func (ti *TaskInfo) Validate(fldPath *field.Path, executor ExecutorType) field.ErrorList {
+	var allErrs field.ErrorList
+	switch executor {
+	case ExecutorKubernetes:
+		if ti.Image == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("image"), ""))
+		}
+	case ExecutorLocal:
+		if len(ti.Command) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("command"), ""))
+		}
+	}
+	for i, c := range ti.Command {
+		if c == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("command").Index(i), c, "must not be empty"))
+		}
+	}
+
+	envNames := sets.New[string]()
+	for i, env := range ti.Env {
+		idxPath := fldPath.Child("env").Index(i).Child("name")
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath, env.Name, msg))
+		}
+		if envNames.Has(env.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, env.Name))
+		}
+		envNames.Insert(env.Name)
+	}
+	allErrs = append(allErrs, validateNames(ti.ConfigMapRefs, fldPath.Child("config_map_refs"))...)
+	allErrs = append(allErrs, validateNames(ti.SecretRefs, fldPath.Child("secret_refs"))...)
+
+	volumeNames := sets.New[string]()
+	for i, v := range ti.Volumes {
+		idxPath := fldPath.Child("volumes").Index(i)
+		allErrs = append(allErrs, v.validate(idxPath)...)
+		if volumeNames.Has(v.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), v.Name))
+		}
+		volumeNames.Insert(v.Name)
+	}
+
+	allErrs = append(allErrs, ti.Resources.validate(fldPath.Child("resources"))...)
+
+	for k, v := range ti.NodeSelector {
+		keyPath := fldPath.Child("node_selector").Key(k)
+		for _, msg := range validation.IsQualifiedName(k) {
+			allErrs = append(allErrs, field.Invalid(keyPath, k, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(v) {
+			allErrs = append(allErrs, field.Invalid(keyPath, v, msg))
+		}
+	}
+
+	for i, t := range ti.Tolerations {
+		allErrs = append(allErrs, t.validate(fldPath.Child("tolerations").Index(i))...)
+	}
+
+	if ti.ServiceAccount != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(ti.ServiceAccount) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("service_account"), ti.ServiceAccount, msg))
+		}
+	}
+	if ti.ActiveDeadlineSeconds != nil && *ti.ActiveDeadlineSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("active_deadline_seconds"), *ti.ActiveDeadlineSeconds, "must be greater than 0"))
+	}
+	if ti.TTLSecondsAfterFinished != nil && *ti.TTLSecondsAfterFinished < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("ttl_seconds_after_finished"), *ti.TTLSecondsAfterFinished, "must be greater than or equal to 0"))
+	}
+
+	return allErrs
+}
+
+func (v *Volume) validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(v.Name) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), v.Name, msg))
+	}
+	if !path.IsAbs(v.MountPath) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mount_path"), v.MountPath, "must be an absolute path"))
+	}
+
+	sources := 0
+	for _, src := range []struct{ field, name string }{
+		{"config_map", v.ConfigMap},
+		{"secret", v.Secret},
+		{"persistent_volume_claim", v.PersistentVolumeClaim},
+	} {
+		if src.name != "" {
+			sources++
+			for _, msg := range validation.IsDNS1123Subdomain(src.name) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(src.field), src.name, msg))
+			}
+		}
+	}
+	if v.EmptyDir {
+		sources++
+	}
+	if sources != 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath, v.Name, "must specify exactly one of config_map, secret, persistent_volume_claim or empty_dir"))
+	}
+	return allErrs
+}
+
+func (r *Resources) validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	requests, errs := r.Requests.Parse(fldPath.Child("requests"))
+	allErrs = append(allErrs, errs...)
+	limits, errs := r.Limits.Parse(fldPath.Child("limits"))
+	allErrs = append(allErrs, errs...)
+
+	for name, request := range requests {
+		if limit, ok := limits[name]; ok && request.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests", name), request.String(), "must be less than or equal to "+name+" limit"))
+		}
+	}
+	return allErrs
+}
+
+// Parse 解析资源量，返回以 cpu、memory 为 key 的资源列表，key 与 K8s 资源名称一致
+func (rl ResourceList) Parse(fldPath *field.Path) (map[string]resource.Quantity, field.ErrorList) {
+	var allErrs field.ErrorList
+	ret := make(map[string]resource.Quantity)
+	for name, value := range map[string]string{"cpu": rl.CPU, "memory": rl.Memory} {
+		if value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(name), value, err.Error()))
+			continue
+		}
+		if q.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(name), value, "must be greater than or equal to 0"))
+			continue
+		}
+		ret[name] = q
+	}
+	return ret, allErrs
+}
+
+func (t *Toleration) validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if t.Key != "" {
+		for _, msg := range validation.IsQualifiedName(t.Key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("key"), t.Key, msg))
+		}
+	}
+	if !supportedTolerationOperators.Has(t.Operator) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("operator"), t.Operator, sets.List(supportedTolerationOperators)))
+	}
+	if t.Operator == "Exists" && t.Value != "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("value"), t.Value, "must be empty when operator is Exists"))
+	}
+	if t.Key == "" && t.Operator != "Exists" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("operator"), t.Operator, "must be Exists when key is empty"))
+	}
+	if !supportedTolerationEffects.Has(t.Effect) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("effect"), t.Effect, sets.List(supportedTolerationEffects)))
+	}
+	if t.TolerationSeconds != nil && t.Effect != "NoExecute" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("toleration_seconds"), *t.TolerationSeconds, "may only be set when effect is NoExecute"))
+	}
+	return allErrs
+}
+
+func validateNames(names []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, name := range names {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), name, msg))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestTaskInfoValidate(t *testing.T) {
+	int64Ptr := func(i int64) *int64 { return &i }
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	tests := []struct {
+		name     string
+		info     TaskInfo
+		executor ExecutorType
+		wantErrs []string // 期望出错的字段
+	}{
+		{
+			name:     "kubernetes",
+			info:     TaskInfo{Image: "busybox", Command: []string{"sleep"}, Args: []string{"60"}},
+			executor: ExecutorKubernetes,
+		},
+		{
+			name:     "kubernetes requires image",
+			info:     TaskInfo{Command: []string{"sleep"}},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{"info.image"},
+		},
+		{
+			name:     "local requires command",
+			info:     TaskInfo{Image: "busybox"},
+			executor: ExecutorLocal,
+			wantErrs: []string{"info.command"},
+		},
+		{
+			name:     "empty command",
+			info:     TaskInfo{Command: []string{"sh", ""}},
+			executor: ExecutorLocal,
+			wantErrs: []string{"info.command[1]"},
+		},
+		{
+			name: "env",
+			info: TaskInfo{
+				Image: "busybox",
+				Env:   []EnvVar{{Name: "GREETING", Value: "hello"}, {Name: "1INVALID"}, {Name: "GREETING"}},
+			},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{"info.env[1].name", "info.env[2].name"},
+		},
+		{
+			name:     "env from refs",
+			info:     TaskInfo{Image: "busybox", ConfigMapRefs: []string{"config"}, SecretRefs: []string{"Secret_Name"}},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{"info.secret_refs[0]"},
+		},
+		{
+			name: "volumes",
+			info: TaskInfo{
+				Image: "busybox",
+				Volumes: []Volume{
+					{Name: "config", MountPath: "/etc/config", ConfigMap: "config"},
+					{Name: "data", MountPath: "data", EmptyDir: true},
+					{Name: "secret", MountPath: "/etc/secret", Secret: "Secret_Name"},
+					{Name: "none", MountPath: "/none"},
+					{Name: "both", MountPath: "/both", ConfigMap: "config", EmptyDir: true},
+					{Name: "config", MountPath: "/dup", EmptyDir: true},
+				},
+			},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{
+				"info.volumes[1].mount_path",
+				"info.volumes[2].secret",
+				"info.volumes[3]",
+				"info.volumes[4]",
+				"info.volumes[5].name",
+			},
+		},
+		{
+			name: "resources",
+			info: TaskInfo{
+				Image: "busybox",
+				Resources: Resources{
+					Requests: ResourceList{CPU: "1", Memory: "abc"},
+					Limits:   ResourceList{CPU: "500m", Memory: "-1Gi"},
+				},
+			},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{
+				"info.resources.requests.memory",
+				"info.resources.limits.memory",
+				"info.resources.requests.cpu",
+			},
+		},
+		{
+			name: "scheduling",
+			info: TaskInfo{
+				Image:        "busybox",
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux", "bad key!": "v"},
+				Tolerations: []Toleration{
+					{Operator: "Exists"},
+					{Key: "dedicated", Operator: "Exists", Value: "gpu"},
+					{Key: "dedicated", Operator: "In"},
+					{Key: "dedicated", Value: "gpu", TolerationSeconds: int64Ptr(60)},
+				},
+				ServiceAccount: "Default_SA",
+			},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{
+				"info.node_selector[bad key!]",
+				"info.tolerations[1].value",
+				"info.tolerations[2].operator",
+				"info.tolerations[3].toleration_seconds",
+				"info.service_account",
+			},
+		},
+		{
+			name: "timeouts",
+			info: TaskInfo{
+				Image:                   "busybox",
+				ActiveDeadlineSeconds:   int64Ptr(0),
+				TTLSecondsAfterFinished: int32Ptr(-1),
+			},
+			executor: ExecutorKubernetes,
+			wantErrs: []string{"info.active_deadline_seconds", "info.ttl_seconds_after_finished"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.info.Validate(field.NewPath("info"), tt.executor)
+			got := make(map[string]bool, len(errs))
+			for _, err := range errs {
+				got[err.Field] = true
+			}
+			for _, f := range tt.wantErrs {
+				if !got[f] {
+					t.Errorf("expected error on field %s, got %v", f, errs)
+				}
+			}
+			if len(got) != len(tt.wantErrs) {
+				t.Errorf("expected errors on fields %v, got %v", tt.wantErrs, errs)
+			}
+		})
+	}
+}
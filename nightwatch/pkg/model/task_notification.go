@@ -0,0 +1,237 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const TableNameTaskNotification = "task_notification"
+
+// NotifierType 通知目标类型
+type NotifierType string
+
+const (
+	NotifierWebhook NotifierType = "webhook" // 以 JSON 推送任务信息的 HTTP 回调，配置密钥时使用 HMAC-SHA256 签名
+	NotifierFeishu  NotifierType = "feishu"  // 飞书群机器人，配置密钥时按飞书的签名校验方式签名
+)
+
+// NotificationStatus 通知的发送状态
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "Pending" // 等待发送或等待重试
+	NotificationStatusSent    NotificationStatus = "Sent"    // 发送成功
+	NotificationStatusFailed  NotificationStatus = "Failed"  // 超过最大发送次数，不再重试
+)
+
+const (
+	// MaxNotificationAttempts 每条通知最多发送的次数
+	MaxNotificationAttempts = 8
+	// MaxNotificationErrorBytes 发送失败的错误信息最多保留的字节数
+	MaxNotificationErrorBytes = 1 << 10
+	// notificationBackoff 第一次发送失败后的重试间隔，之后按 2 的幂增长
+	notificationBackoff = 30 * time.Second
+)
+
+// redactedSecret 响应中代替通知目标密钥返回的内容
+const redactedSecret = "******"
+
+// NotificationTarget 任务结束时的通知目标
+type NotificationTarget struct {
+	Type   NotifierType `json:"type"`
+	URL    string       `json:"url"`
+	Secret string       `json:"secret,omitempty"` // 签名密钥，响应中不返回原文
+}
+
+// storedNotificationTarget 与 NotificationTarget 字段相同，没有脱敏的 MarshalJSON，用于保存包含密钥的完整内容
+type storedNotificationTarget NotificationTarget
+
+// MarshalJSON 返回密钥脱敏后的 JSON，避免查询任务时泄露签名密钥
+func (nt NotificationTarget) MarshalJSON() ([]byte, error) {
+	if nt.Secret != "" {
+		nt.Secret = redactedSecret
+	}
+	return json.Marshal(storedNotificationTarget(nt))
+}
+
+// Validate 校验通知目标，URL 需要为 http 或 https 地址
+func (nt *NotificationTarget) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch nt.Type {
+	case NotifierWebhook, NotifierFeishu:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), nt.Type, []NotifierType{NotifierWebhook, NotifierFeishu}))
+	}
+	u, err := url.Parse(nt.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("url"), nt.URL, "must be an absolute http or https URL"))
+	}
+	return allErrs
+}
+
+// Scan implements the [Scanner] interface.
+func (nt *NotificationTarget) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), nt)
+}
+
+// Value implements the [driver.Valuer] interface.
+func (nt NotificationTarget) Value() (driver.Value, error) {
+	bytes, err := json.Marshal(storedNotificationTarget(nt))
+	return string(bytes), err
+}
+
+type NotificationTargets []NotificationTarget
+
+// stored 返回用于保存的通知目标，保留密钥原文
+func (nts NotificationTargets) stored() []storedNotificationTarget {
+	if nts == nil {
+		return nil
+	}
+	ret := make([]storedNotificationTarget, 0, len(nts))
+	for _, nt := range nts {
+		ret = append(ret, storedNotificationTarget(nt))
+	}
+	return ret
+}
+
+// Validate 校验所有通知目标
+func (nts NotificationTargets) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i := range nts {
+		allErrs = append(allErrs, nts[i].Validate(fldPath.Index(i))...)
+	}
+	return allErrs
+}
+
+// Scan implements the [Scanner] interface.
+func (nts *NotificationTargets) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), nts)
+}
+
+// Value implements the [driver.Valuer] interface.
+func (nts NotificationTargets) Value() (driver.Value, error) {
+	stored := nts.stored()
+	if stored == nil {
+		stored = []storedNotificationTarget{}
+	}
+	bytes, err := json.Marshal(stored)
+	return string(bytes), err
+}
+
+// NotificationPayload 通知的内容，任务进入终态时的快照
+type NotificationPayload struct {
+	TaskID     int64      `json:"task_id"`
+	Name       string     `json:"name"`
+	Namespace  string     `json:"namespace"`
+	UserID     int64      `json:"user_id"`
+	ScheduleID *int64     `json:"schedule_id,omitempty"`
+	Attempt    int        `json:"attempt"`
+	FromStatus TaskStatus `json:"from_status"`
+	Status     TaskStatus `json:"status"`
+	Reason     string     `json:"reason"`
+	Message    string     `json:"message,omitempty"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
+
+// Scan implements the [Scanner] interface.
+func (np *NotificationPayload) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), np)
+}
+
+// Value implements the [driver.Valuer] interface.
+func (np NotificationPayload) Value() (driver.Value, error) {
+	bytes, err := json.Marshal(np)
+	return string(bytes), err
+}
+
+// TaskNotification 等待发送或已经发送的任务通知，与任务状态变化在同一事务中写入，nightwatch 重启后继续发送
+type TaskNotification struct {
+	ID            int64               `gorm:"column:id" json:"id"`                           // 主键 ID
+	TaskID        int64               `gorm:"column:task_id" json:"task_id"`                 // 任务 ID
+	EventID       int64               `gorm:"column:event_id" json:"event_id"`               // 触发通知的状态变化记录 ID
+	Target        NotificationTarget  `gorm:"column:target" json:"target"`                   // 通知目标
+	Payload       NotificationPayload `gorm:"column:payload" json:"payload"`                 // 通知内容
+	Status        NotificationStatus  `gorm:"column:status" json:"status"`                   // 发送状态
+	Attempts      int                 `gorm:"column:attempts" json:"attempts"`               // 已经发送的次数
+	NextAttemptAt time.Time           `gorm:"column:next_attempt_at" json:"next_attempt_at"` // 下次发送时间
+	LastError     string              `gorm:"column:last_error" json:"last_error,omitempty"` // 最近一次发送失败的原因
+	SentAt        *time.Time          `gorm:"column:sent_at" json:"sent_at"`                 // 发送成功的时间
+	CreatedAt     time.Time           `gorm:"column:created_at" json:"created_at"`           // 创建时间
+	UpdatedAt     time.Time           `gorm:"column:updated_at" json:"updated_at"`           // 修改时间
+}
+
+func (*TaskNotification) TableName() string {
+	return TableNameTaskNotification
+}
+
+// NewTaskNotifications 根据任务的状态变化记录为每个通知目标创建通知，只有变为终态时才需要通知
+func NewTaskNotifications(task *Task, event *TaskEvent) []*TaskNotification {
+	if !event.ToStatus.IsFinished() || event.FromStatus == event.ToStatus || len(task.Notifications) == 0 {
+		return nil
+	}
+	finishedAt := event.CreatedAt
+	if finishedAt.IsZero() {
+		finishedAt = time.Now()
+	}
+	payload := NotificationPayload{
+		TaskID:     task.ID,
+		Name:       task.Name,
+		Namespace:  task.Namespace,
+		UserID:     task.UserID,
+		ScheduleID: task.ScheduleID,
+		Attempt:    event.Attempt,
+		FromStatus: event.FromStatus,
+		Status:     event.ToStatus,
+		Reason:     event.Reason,
+		Message:    event.Message,
+		FinishedAt: finishedAt,
+	}
+	var ret []*TaskNotification
+	for _, target := range task.Notifications {
+		ret = append(ret, &TaskNotification{
+			TaskID:        task.ID,
+			EventID:       event.ID,
+			Target:        target,
+			Payload:       payload,
+			Status:        NotificationStatusPending,
+			NextAttemptAt: finishedAt,
+		})
+	}
+	return ret
+}
+
+// MarkSent 记录发送成功
+func (n *TaskNotification) MarkSent(now time.Time) {
+	n.Attempts++
+	n.Status = NotificationStatusSent
+	n.LastError = ""
+	n.SentAt = &now
+}
+
+// MarkFailed 记录发送失败，未超过最大发送次数时按指数退避等待重试，否则不再发送
+func (n *TaskNotification) MarkFailed(err error, now time.Time) {
+	n.Attempts++
+	n.LastError, _ = TruncateHead(err.Error(), MaxNotificationErrorBytes)
+	if n.Attempts >= MaxNotificationAttempts {
+		n.Status = NotificationStatusFailed
+		return
+	}
+	backoff := notificationBackoff
+	for i := 1; i < n.Attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	n.NextAttemptAt = now.Add(min(backoff, maxBackoff))
+}
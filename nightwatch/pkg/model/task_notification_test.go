@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestNewTaskNotifications(t *testing.T) {
+	targets := NotificationTargets{
+		{Type: NotifierWebhook, URL: "http://example.com/hook", Secret: "s3cr3t"},
+		{Type: NotifierFeishu, URL: "https://open.feishu.cn/open-apis/bot/v2/hook/xxx"},
+	}
+	tests := []struct {
+		name     string
+		targets  NotificationTargets
+		from, to TaskStatus
+		want     int
+	}{
+		{name: "succeeded", targets: targets, from: TaskStatusRunning, to: TaskStatusSucceeded, want: 2},
+		{name: "upstream failed", targets: targets, from: TaskStatusNormal, to: TaskStatusUpstreamFailed, want: 2},
+		{name: "not finished", targets: targets, from: TaskStatusPending, to: TaskStatusRunning},
+		{name: "cancelling", targets: targets, from: TaskStatusRunning, to: TaskStatusCancelling},
+		{name: "unchanged", targets: targets, from: TaskStatusFailed, to: TaskStatusFailed},
+		{name: "no targets", from: TaskStatusRunning, to: TaskStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{ID: 1, Name: "demo", Namespace: "default", Status: tt.to, Attempt: 2, Notifications: tt.targets}
+			event := NewTaskEvent(task, tt.from, TaskEventReasonStatusSynced, "done")
+			event.ID = 10
+			got := NewTaskNotifications(task, event)
+			if len(got) != tt.want {
+				t.Fatalf("NewTaskNotifications() = %d notifications, want %d", len(got), tt.want)
+			}
+			for i, n := range got {
+				if n.Target != tt.targets[i] || n.EventID != 10 || n.Status != NotificationStatusPending {
+					t.Errorf("notification %d = %+v", i, n)
+				}
+				if n.Payload.Status != tt.to || n.Payload.FromStatus != tt.from || n.Payload.Attempt != 2 || n.Payload.Message != "done" {
+					t.Errorf("payload %d = %+v", i, n.Payload)
+				}
+			}
+		})
+	}
+}
+
+func TestTaskNotificationMarkFailed(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	n := &TaskNotification{Status: NotificationStatusPending}
+	wantBackoff := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, want := range wantBackoff {
+		n.MarkFailed(errors.New("connection refused"), now)
+		if n.Attempts != i+1 || n.Status != NotificationStatusPending || !n.NextAttemptAt.Equal(now.Add(want)) {
+			t.Fatalf("after %d failures = %+v, want next attempt after %s", i+1, n, want)
+		}
+	}
+	for n.Status == NotificationStatusPending {
+		n.MarkFailed(errors.New(strings.Repeat("x", 2*MaxNotificationErrorBytes)), now)
+	}
+	if n.Attempts != MaxNotificationAttempts || n.Status != NotificationStatusFailed {
+		t.Errorf("final = %d attempts in %s, want %d in Failed", n.Attempts, n.Status, MaxNotificationAttempts)
+	}
+	if len(n.LastError) > MaxNotificationErrorBytes+64 {
+		t.Errorf("LastError is not truncated: %d bytes", len(n.LastError))
+	}
+
+	n.MarkSent(now)
+	if n.Status != NotificationStatusSent || n.LastError != "" || n.SentAt == nil {
+		t.Errorf("after MarkSent = %+v", n)
+	}
+}
+
+func TestNotificationTargetSecret(t *testing.T) {
+	target := NotificationTarget{Type: NotifierWebhook, URL: "http://example.com/hook", Secret: "s3cr3t"}
+
+	// 响应中不返回密钥原文
+	data, err := json.Marshal(&Task{Notifications: NotificationTargets{target}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") || !strings.Contains(string(data), redactedSecret) {
+		t.Errorf("Marshal() = %s, want redacted secret", data)
+	}
+
+	// 保存到数据库时保留密钥原文
+	value, err := NotificationTargets{target}.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	var targets NotificationTargets
+	if err := targets.Scan([]byte(value.(string))); err != nil || len(targets) != 1 || targets[0] != target {
+		t.Errorf("Scan(Value()) = %v, %v, want %v", targets, err, target)
+	}
+
+	value, err = TaskTemplate{Notifications: NotificationTargets{target}}.Value()
+	if err != nil {
+		t.Fatalf("TaskTemplate.Value() error = %v", err)
+	}
+	var tt TaskTemplate
+	if err := tt.Scan([]byte(value.(string))); err != nil || len(tt.Notifications) != 1 || tt.Notifications[0] != target {
+		t.Errorf("TaskTemplate Scan(Value()) = %v, %v, want %v", tt.Notifications, err, target)
+	}
+}
+
+func TestNotificationTargetValidate(t *testing.T) {
+	tests := []struct {
+		target  NotificationTarget
+		wantErr bool
+	}{
+		{target: NotificationTarget{Type: NotifierWebhook, URL: "https://example.com/hook"}},
+		{target: NotificationTarget{Type: NotifierFeishu, URL: "http://127.0.0.1:8080/bot"}},
+		{target: NotificationTarget{Type: "email", URL: "https://example.com"}, wantErr: true},
+		{target: NotificationTarget{Type: NotifierWebhook, URL: "ftp://example.com"}, wantErr: true},
+		{target: NotificationTarget{Type: NotifierWebhook, URL: "/hook"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		errs := tt.target.Validate(field.NewPath("notifications"))
+		if (len(errs) > 0) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, want error %v", tt.target, errs, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+// TaskQuota 任务并发配额，限制同时提交执行的任务数量，0 表示不限制
+type TaskQuota struct {
+	MaxRunning             int64 // 所有任务
+	MaxRunningPerUser      int64 // 每个用户的任务
+	MaxRunningPerNamespace int64 // 每个 namespace 的任务
+}
+
+// TaskUsage 某个用户在某个 namespace 中已经提交执行还未结束的任务数量
+type TaskUsage struct {
+	UserID    int64  `gorm:"column:user_id"`
+	Namespace string `gorm:"column:namespace"`
+	Count     int64  `gorm:"column:count"`
+}
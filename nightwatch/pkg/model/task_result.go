@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const TableNameTaskResult = "task_result"
+
+const (
+	// MaxTaskResultLogLines 每次执行最多保留的日志行数
+	MaxTaskResultLogLines = 200
+	// MaxTaskResultLogBytes 每次执行最多保留的日志字节数
+	MaxTaskResultLogBytes = 64 << 10
+	// MaxTaskResultMessageBytes 退出信息最多保留的字节数
+	MaxTaskResultMessageBytes = 4 << 10
+)
+
+// TruncatedLogsMarker 读取日志时已经丢弃了部分日志、无法得知丢弃的字节数时加上的截断标记
+const TruncatedLogsMarker = "... [truncated] ...\n"
+
+// TaskResult 任务单次执行结束后的结果，包括退出原因、退出码和最后的日志
+type TaskResult struct {
+	ID            int64     `gorm:"column:id" json:"id"`                         // 主键 ID
+	TaskID        int64     `gorm:"column:task_id" json:"task_id"`               // 任务 ID
+	Attempt       int       `gorm:"column:attempt" json:"attempt"`               // 执行次数
+	Name          string    `gorm:"column:name" json:"name"`                     // 执行实例名称，如 Pod 名称
+	Reason        string    `gorm:"column:reason" json:"reason"`                 // 退出原因，如 Completed、Error、OOMKilled
+	ExitCode      *int32    `gorm:"column:exit_code" json:"exit_code"`           // 退出码，没有退出时为空
+	Message       string    `gorm:"column:message" json:"message,omitempty"`     // 退出信息
+	Logs          string    `gorm:"column:logs" json:"logs"`                     // 最后的日志
+	LogsTruncated bool      `gorm:"column:logs_truncated" json:"logs_truncated"` // 日志是否被截断
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`         // 创建时间
+}
+
+func (*TaskResult) TableName() string {
+	return TableNameTaskResult
+}
+
+// Truncate 按大小上限截断退出信息和日志，并加上截断标记
+func (r *TaskResult) Truncate() {
+	r.Message, _ = TruncateHead(r.Message, MaxTaskResultMessageBytes)
+	var truncated bool
+	r.Logs, truncated = TruncateTail(r.Logs, MaxTaskResultLogBytes)
+	r.LogsTruncated = r.LogsTruncated || truncated
+}
+
+// TruncateTail 保留 s 最后不超过 limit 字节的内容，截断时在开头加上截断标记，返回是否截断
+func TruncateTail(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	i := len(s) - limit
+	// 从完整的行开始，避免截断后的第一行只有一半
+	if s[i-1] != '\n' {
+		if j := strings.IndexByte(s[i:], '\n'); j >= 0 && i+j < len(s)-1 {
+			i += j + 1
+		}
+	}
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return fmt.Sprintf("... [truncated %d bytes] ...\n", i) + s[i:], true
+}
+
+// TruncateHead 保留 s 开头不超过 limit 字节的内容，截断时在结尾加上截断标记，返回是否截断
+func TruncateHead(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	n := limit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + fmt.Sprintf("... [truncated %d bytes]", len(s)-n), true
+}
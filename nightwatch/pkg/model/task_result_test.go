@@ -0,0 +1,69 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateTail(t *testing.T) {
+	tests := []struct {
+		name          string
+		s             string
+		limit         int
+		want          string
+		wantTruncated bool
+	}{
+		{name: "within limit", s: "a\nb\n", limit: 4, want: "a\nb\n"},
+		{name: "cut at line start", s: "aaa\nbbb\nccc\n", limit: 8, want: "... [truncated 4 bytes] ...\nbbb\nccc\n", wantTruncated: true},
+		{name: "skip partial line", s: "aaa\nbbb\nccc\n", limit: 6, want: "... [truncated 8 bytes] ...\nccc\n", wantTruncated: true},
+		{name: "single long line", s: "abcdefgh", limit: 3, want: "... [truncated 5 bytes] ...\nfgh", wantTruncated: true},
+		{name: "rune boundary", s: "你好世界", limit: 4, want: "... [truncated 9 bytes] ...\n界", wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := TruncateTail(tt.s, tt.limit)
+			if got != tt.want || truncated != tt.wantTruncated {
+				t.Fatalf("TruncateTail(%q, %d) = %q, %v, want %q, %v", tt.s, tt.limit, got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestTruncateHead(t *testing.T) {
+	tests := []struct {
+		name          string
+		s             string
+		limit         int
+		want          string
+		wantTruncated bool
+	}{
+		{name: "within limit", s: "abc", limit: 3, want: "abc"},
+		{name: "truncated", s: "abcdef", limit: 2, want: "ab... [truncated 4 bytes]", wantTruncated: true},
+		{name: "rune boundary", s: "你好", limit: 4, want: "你... [truncated 3 bytes]", wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := TruncateHead(tt.s, tt.limit)
+			if got != tt.want || truncated != tt.wantTruncated {
+				t.Fatalf("TruncateHead(%q, %d) = %q, %v, want %q, %v", tt.s, tt.limit, got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestTaskResultTruncate(t *testing.T) {
+	r := &TaskResult{
+		Message: strings.Repeat("m", MaxTaskResultMessageBytes+1),
+		Logs:    strings.Repeat("line\n", MaxTaskResultLogBytes),
+	}
+	r.Truncate()
+	if !r.LogsTruncated || !strings.HasPrefix(r.Logs, "... [truncated") {
+		t.Fatalf("logs were not truncated: truncated = %v, prefix = %q", r.LogsTruncated, r.Logs[:32])
+	}
+	if !strings.HasSuffix(r.Logs, "line\n") {
+		t.Fatalf("logs tail was not kept: %q", r.Logs[len(r.Logs)-16:])
+	}
+	if !strings.HasSuffix(r.Message, "... [truncated 1 bytes]") {
+		t.Fatalf("message was not truncated: %q", r.Message[len(r.Message)-32:])
+	}
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const TableNameTaskSchedule = "task_schedule"
+
+// ConcurrencyPolicy 定时任务到达执行时间时，上一次创建的任务还未结束的处理策略
+type ConcurrencyPolicy string
+
+const (
+	ConcurrencyPolicyAllow   ConcurrencyPolicy = "Allow"   // 允许同时运行
+	ConcurrencyPolicyForbid  ConcurrencyPolicy = "Forbid"  // 跳过本次执行
+	ConcurrencyPolicyReplace ConcurrencyPolicy = "Replace" // 取消还未结束的任务后创建新任务
+)
+
+// MaxScheduleNameLength 定时任务名称的最大长度，需要为创建的任务名称预留时间戳后缀
+const MaxScheduleNameLength = 52
+
+// TaskSchedule 定时任务，按照 cron 表达式周期性地创建 Normal 状态的任务
+type TaskSchedule struct {
+	ID                int64             `gorm:"column:id" json:"id"`                                 // 定时任务 ID
+	Name              string            `gorm:"column:name" json:"name"`                             // 定时任务名称
+	Namespace         string            `gorm:"column:namespace" json:"namespace"`                   // 创建的任务所在的 k8s namespace
+	UserID            int64             `gorm:"column:user_id" json:"user_id"`                       // 用户 ID
+	Spec              string            `gorm:"column:spec" json:"spec"`                             // cron 表达式，支持秒级
+	Template          TaskTemplate      `gorm:"column:template" json:"template"`                     // 创建任务使用的模板
+	ConcurrencyPolicy ConcurrencyPolicy `gorm:"column:concurrency_policy" json:"concurrency_policy"` // 并发策略
+	CatchUpLimit      int               `gorm:"column:catch_up_limit" json:"catch_up_limit"`         // 错过执行时间后最多补偿执行的次数
+	Suspend           bool              `gorm:"column:suspend" json:"suspend"`                       // 是否暂停
+	LastScheduleAt    *time.Time        `gorm:"column:last_schedule_at" json:"last_schedule_at"`     // 最近一次执行时间
+	CreatedAt         time.Time         `gorm:"column:created_at" json:"created_at"`                 // 创建时间
+	UpdatedAt         time.Time         `gorm:"column:updated_at" json:"updated_at"`                 // 修改时间
+}
+
+func (*TaskSchedule) TableName() string {
+	return TableNameTaskSchedule
+}
+
+// NewTask 根据模板创建 scheduledAt 这一次执行对应的任务，任务名称以执行时间的时间戳为后缀
+func (s *TaskSchedule) NewTask(scheduledAt time.Time) *Task {
+	scheduleID := s.ID
+	return &Task{
+		Name:            fmt.Sprintf("%s-%d", s.Name, scheduledAt.Unix()),
+		Namespace:       s.Namespace,
+		Info:            s.Template.Info,
+		Status:          TaskStatusNormal,
+		UserID:          s.UserID,
+		Priority:        s.Template.Priority,
+		Executor:        s.Template.Executor,
+		MaxAttempts:     max(s.Template.MaxAttempts, 1),
+		BackoffStrategy: s.Template.BackoffStrategy,
+		BackoffSeconds:  s.Template.BackoffSeconds,
+		ScheduleID:      &scheduleID,
+		Notifications:   s.Template.Notifications,
+	}
+}
+
+// TaskTemplate 定时任务创建任务时使用的模板
+type TaskTemplate struct {
+	Info            TaskInfo            `json:"info"`
+	Priority        int                 `json:"priority,omitempty"`
+	Executor        ExecutorType        `json:"executor,omitempty"`
+	MaxAttempts     int                 `json:"max_attempts,omitempty"`
+	BackoffStrategy BackoffStrategy     `json:"backoff_strategy,omitempty"`
+	BackoffSeconds  int                 `json:"backoff_seconds,omitempty"`
+	Notifications   NotificationTargets `json:"notifications,omitempty"`
+}
+
+// Validate 校验任务模板，executors 为允许使用的执行器
+func (tt *TaskTemplate) Validate(fldPath *field.Path, executors []ExecutorType) field.ErrorList {
+	var allErrs field.ErrorList
+	task := &Task{
+		Executor:        tt.Executor,
+		MaxAttempts:     tt.MaxAttempts,
+		BackoffStrategy: tt.BackoffStrategy,
+		BackoffSeconds:  tt.BackoffSeconds,
+	}
+	executor := task.ExecutorType()
+	if !slices.Contains(executors, executor) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("executor"), tt.Executor, executors))
+	}
+	allErrs = append(allErrs, tt.Info.Validate(fldPath.Child("info"), executor)...)
+	allErrs = append(allErrs, task.ValidateRetryPolicy(fldPath)...)
+	allErrs = append(allErrs, tt.Notifications.Validate(fldPath.Child("notifications"))...)
+	return allErrs
+}
+
+// Scan implements the [Scanner] interface.
+func (tt *TaskTemplate) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), tt)
+}
+
+// Value implements the [driver.Valuer] interface.
+// 通知目标的密钥需要原样保存，不能使用脱敏后的 JSON
+func (tt TaskTemplate) Value() (driver.Value, error) {
+	type template TaskTemplate
+	bytes, err := json.Marshal(struct {
+		template
+		Notifications []storedNotificationTarget `json:"notifications,omitempty"`
+	}{template(tt), tt.Notifications.stored()})
+	return string(bytes), err
+}
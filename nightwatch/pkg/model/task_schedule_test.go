@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestTaskScheduleNewTask(t *testing.T) {
+	s := &TaskSchedule{
+		ID:        3,
+		Name:      "nightly-report",
+		Namespace: "demo",
+		UserID:    1,
+		Template: TaskTemplate{
+			Info:            TaskInfo{Image: "busybox"},
+			BackoffStrategy: BackoffStrategyExponential,
+			BackoffSeconds:  10,
+		},
+	}
+	task := s.NewTask(time.Unix(1767232800, 0))
+
+	if task.Name != "nightly-report-1767232800" {
+		t.Fatalf("task name = %q", task.Name)
+	}
+	if task.Status != TaskStatusNormal || task.MaxAttempts != 1 || task.ScheduleID == nil || *task.ScheduleID != s.ID {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if task.Namespace != s.Namespace || task.UserID != s.UserID || task.Info.Image != "busybox" || task.BackoffStrategy != BackoffStrategyExponential {
+		t.Fatalf("task does not match template: %+v", task)
+	}
+
+	// 最长的定时任务名称加上时间戳后缀仍然是合法的 Job 名称
+	long := &TaskSchedule{Name: "a2345678901234567890123456789012345678901234567890ab"}
+	if len(long.Name) != MaxScheduleNameLength {
+		t.Fatalf("schedule name length = %d, want %d", len(long.Name), MaxScheduleNameLength)
+	}
+	if errs := validation.IsDNS1123Label(long.NewTask(time.Now()).Name); len(errs) > 0 {
+		t.Fatalf("task name is not a valid DNS-1123 label: %v", errs)
+	}
+}
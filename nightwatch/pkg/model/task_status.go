@@ -0,0 +1,59 @@
+package model
+
+import "slices"
+
+// taskStatusTransitions 任务状态允许变化到的状态，终态（Succeeded、Failed、Cancelled、UpstreamFailed）不能再变化
+var taskStatusTransitions = map[TaskStatus][]TaskStatus{
+	// 启动、超出配额排队、取消或上游任务失败
+	TaskStatusNormal: {TaskStatusPending, TaskStatusQueued, TaskStatusCancelled, TaskStatusUpstreamFailed},
+	// 到达重试时间后重新启动、超出配额排队或取消
+	TaskStatusRetrying: {TaskStatusPending, TaskStatusQueued, TaskStatusCancelled},
+	// 配额空闲后启动或取消
+	TaskStatusQueued: {TaskStatusPending, TaskStatusCancelled},
+	// 提交失败时恢复为 Normal、Retrying 或 Queued 等待重新提交，其余为执行器同步的状态或请求取消
+	TaskStatusPending: {TaskStatusNormal, TaskStatusRetrying, TaskStatusQueued, TaskStatusRunning, TaskStatusUnknown, TaskStatusSucceeded, TaskStatusFailed, TaskStatusCancelling},
+	TaskStatusRunning: {TaskStatusPending, TaskStatusUnknown, TaskStatusSucceeded, TaskStatusFailed, TaskStatusRetrying, TaskStatusCancelling},
+	TaskStatusUnknown: {TaskStatusPending, TaskStatusRunning, TaskStatusSucceeded, TaskStatusFailed, TaskStatusRetrying, TaskStatusCancelling},
+	// 执行实例删除完成
+	TaskStatusCancelling: {TaskStatusCancelled},
+}
+
+// CanTransitionTo 判断任务状态是否可以变化为 to，状态不变时总是允许
+func (s TaskStatus) CanTransitionTo(to TaskStatus) bool {
+	return s == to || slices.Contains(taskStatusTransitions[s], to)
+}
+
+// CancelStatus 返回取消处于 s 状态的任务时应变化到的状态，无法取消时返回 false
+// 还未提交执行的任务直接变为 Cancelled，已经提交执行的任务先变为 Cancelling，等待执行实例删除后再变为 Cancelled
+func (s TaskStatus) CancelStatus() (TaskStatus, bool) {
+	switch s {
+	case TaskStatusNormal, TaskStatusRetrying, TaskStatusQueued:
+		return TaskStatusCancelled, true
+	case TaskStatusPending, TaskStatusRunning, TaskStatusUnknown:
+		return TaskStatusCancelling, true
+	default:
+		return "", false
+	}
+}
+
+// PreviousStatuses 返回可以变化为 to 的所有状态，包含 to 本身
+func PreviousStatuses(to TaskStatus) []TaskStatus {
+	ret := []TaskStatus{to}
+	for from, targets := range taskStatusTransitions {
+		if from != to && slices.Contains(targets, to) {
+			ret = append(ret, from)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// IsFinished 判断 s 是否为终态，终态的任务不会再变化
+func (s TaskStatus) IsFinished() bool {
+	switch s {
+	case TaskStatusSucceeded, TaskStatusFailed, TaskStatusCancelled, TaskStatusUpstreamFailed:
+		return true
+	default:
+		return false
+	}
+}
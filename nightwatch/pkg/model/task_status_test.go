@@ -0,0 +1,101 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTaskStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to TaskStatus
+		want     bool
+	}{
+		{from: TaskStatusNormal, to: TaskStatusPending, want: true},
+		{from: TaskStatusNormal, to: TaskStatusCancelled, want: true},
+		{from: TaskStatusNormal, to: TaskStatusUpstreamFailed, want: true},
+		{from: TaskStatusNormal, to: TaskStatusRunning},
+		{from: TaskStatusPending, to: TaskStatusNormal, want: true},
+		{from: TaskStatusPending, to: TaskStatusRunning, want: true},
+		{from: TaskStatusPending, to: TaskStatusFailed, want: true},
+		{from: TaskStatusPending, to: TaskStatusCancelled},
+		{from: TaskStatusPending, to: TaskStatusCancelling, want: true},
+		{from: TaskStatusRunning, to: TaskStatusCancelling, want: true},
+		{from: TaskStatusCancelling, to: TaskStatusCancelled, want: true},
+		{from: TaskStatusCancelling, to: TaskStatusFailed},
+		{from: TaskStatusRetrying, to: TaskStatusCancelling},
+		{from: TaskStatusNormal, to: TaskStatusQueued, want: true},
+		{from: TaskStatusRetrying, to: TaskStatusQueued, want: true},
+		{from: TaskStatusQueued, to: TaskStatusPending, want: true},
+		{from: TaskStatusQueued, to: TaskStatusRunning},
+		{from: TaskStatusPending, to: TaskStatusQueued, want: true},
+		{from: TaskStatusRunning, to: TaskStatusSucceeded, want: true},
+		{from: TaskStatusRunning, to: TaskStatusRetrying, want: true},
+		{from: TaskStatusRunning, to: TaskStatusNormal},
+		{from: TaskStatusUnknown, to: TaskStatusRunning, want: true},
+		{from: TaskStatusRetrying, to: TaskStatusPending, want: true},
+		{from: TaskStatusRetrying, to: TaskStatusRunning},
+		{from: TaskStatusSucceeded, to: TaskStatusRunning},
+		{from: TaskStatusFailed, to: TaskStatusRetrying},
+		{from: TaskStatusCancelled, to: TaskStatusPending},
+		{from: TaskStatusUpstreamFailed, to: TaskStatusNormal},
+		{from: TaskStatusSucceeded, to: TaskStatusSucceeded, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Fatalf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPreviousStatuses(t *testing.T) {
+	tests := []struct {
+		to   TaskStatus
+		want []TaskStatus
+	}{
+		{to: TaskStatusNormal, want: []TaskStatus{TaskStatusNormal, TaskStatusPending}},
+		{to: TaskStatusCancelled, want: []TaskStatus{TaskStatusCancelled, TaskStatusCancelling, TaskStatusNormal, TaskStatusQueued, TaskStatusRetrying}},
+		{to: TaskStatusCancelling, want: []TaskStatus{TaskStatusCancelling, TaskStatusPending, TaskStatusRunning, TaskStatusUnknown}},
+		{to: TaskStatusSucceeded, want: []TaskStatus{TaskStatusPending, TaskStatusRunning, TaskStatusSucceeded, TaskStatusUnknown}},
+		{to: TaskStatusRetrying, want: []TaskStatus{TaskStatusPending, TaskStatusRetrying, TaskStatusRunning, TaskStatusUnknown}},
+	}
+	for _, tt := range tests {
+		got := PreviousStatuses(tt.to)
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("PreviousStatuses(%s) = %v, want %v", tt.to, got, tt.want)
+		}
+		// 与 CanTransitionTo 保持一致
+		for _, from := range got {
+			if !from.CanTransitionTo(tt.to) {
+				t.Fatalf("%s.CanTransitionTo(%s) = false", from, tt.to)
+			}
+		}
+	}
+}
+
+func TestTaskStatusCancelStatus(t *testing.T) {
+	tests := []struct {
+		from   TaskStatus
+		want   TaskStatus
+		wantOK bool
+	}{
+		{from: TaskStatusNormal, want: TaskStatusCancelled, wantOK: true},
+		{from: TaskStatusRetrying, want: TaskStatusCancelled, wantOK: true},
+		{from: TaskStatusQueued, want: TaskStatusCancelled, wantOK: true},
+		{from: TaskStatusPending, want: TaskStatusCancelling, wantOK: true},
+		{from: TaskStatusRunning, want: TaskStatusCancelling, wantOK: true},
+		{from: TaskStatusUnknown, want: TaskStatusCancelling, wantOK: true},
+		{from: TaskStatusCancelling},
+		{from: TaskStatusCancelled},
+		{from: TaskStatusSucceeded},
+		{from: TaskStatusUpstreamFailed},
+	}
+	for _, tt := range tests {
+		got, ok := tt.from.CancelStatus()
+		if got != tt.want || ok != tt.wantOK {
+			t.Fatalf("%s.CancelStatus() = %s, %v, want %s, %v", tt.from, got, ok, tt.want, tt.wantOK)
+		}
+		if ok && !tt.from.CanTransitionTo(got) {
+			t.Fatalf("%s.CanTransitionTo(%s) = false", tt.from, got)
+		}
+	}
+}
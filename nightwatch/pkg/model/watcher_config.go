@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const TableNameWatcherConfig = "watcher_config"
+
+// WatcherConfig Watcher 运行配置，没有配置的 Watcher 默认启用并使用自身的定时周期
+type WatcherConfig struct {
+	ID        int64     `gorm:"column:id" json:"id"`                 // 主键 ID
+	Name      string    `gorm:"column:name" json:"name"`             // Watcher 名称，即注册的结构体名称
+	Enabled   bool      `gorm:"column:enabled" json:"enabled"`       // 是否启用
+	Spec      string    `gorm:"column:spec" json:"spec"`             // 覆盖默认定时周期的 cron 表达式，为空时使用默认值
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"` // 创建时间
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"` // 修改时间
+}
+
+func (*WatcherConfig) TableName() string {
+	return TableNameWatcherConfig
+}
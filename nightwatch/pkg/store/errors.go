@@ -0,0 +1,25 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition 任务状态变化不合法，重试也无法成功
+var ErrInvalidTransition = errors.New("invalid task status transition")
+
+// ConflictError 任务在读取之后已经被并发修改，调用方可以重新读取任务后重试
+type ConflictError struct {
+	TaskID          int64
+	ResourceVersion int64 // 更新时期望的版本号
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("task %d has been modified concurrently, expected resource version %d", e.TaskID, e.ResourceVersion)
+}
+
+// IsConflict 判断 err 是否为 *ConflictError
+func IsConflict(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
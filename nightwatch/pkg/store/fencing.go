@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// ErrStaleFencingToken 写入携带的 fencing token 已过期，说明锁已经被新的 leader 获取
+var ErrStaleFencingToken = errors.New("fencing token is stale, leadership has been lost")
+
+type fencingKey struct{}
+
+type fencing struct {
+	name  string
+	token int64
+}
+
+// WithFencingToken 返回携带 fencing token 的 ctx，使用此 ctx 的写入只有在 token 仍是最新时才会执行
+func WithFencingToken(ctx context.Context, name string, token int64) context.Context {
+	return context.WithValue(ctx, fencingKey{}, fencing{name: name, token: token})
+}
+
+// FencingTokenFrom 返回 ctx 携带的 fencing token
+func FencingTokenFrom(ctx context.Context) (int64, bool) {
+	f, ok := ctx.Value(fencingKey{}).(fencing)
+	return f.token, ok
+}
+
+type FencingStore interface {
+	// Next 递增并返回 name 对应的 fencing token，获取锁后调用，之后旧 token 的写入都会被拒绝
+	Next(ctx context.Context, name string) (int64, error)
+}
+
+type fencingStore struct {
+	ds *datastore
+}
+
+func newFencingStore(ds *datastore) *fencingStore {
+	return &fencingStore{ds}
+}
+
+func (d *fencingStore) Next(ctx context.Context, name string) (token int64, err error) {
+	// 不能使用携带旧 token 的 ctx，否则递增本身也会被拒绝
+	ctx = context.WithValue(ctx, fencingKey{}, nil)
+	err = d.ds.core.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ft := &model.FencingToken{Name: name, Token: 1}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]any{"token": gorm.Expr("token + 1")}),
+		}).Create(ft).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("name = ?", name).Take(ft).Error; err != nil {
+			return err
+		}
+		token = ft.Token
+		return nil
+	})
+	return token, err
+}
+
+// registerFencingCallbacks 在写入所在的事务中以共享锁读取最新的 fencing token 进行校验
+// Next 递增 token 时需要等待持有共享锁的旧写入提交，递增后旧 token 的写入都会失败
+func registerFencingCallbacks(db *gorm.DB) error {
+	const name = "nightwatch:fencing"
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:begin_transaction").Register(name, checkFencingToken); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:begin_transaction").Register(name, checkFencingToken); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:begin_transaction").Register(name, checkFencingToken)
+}
+
+func checkFencingToken(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Context == nil {
+		return
+	}
+	f, ok := db.Statement.Context.Value(fencingKey{}).(fencing)
+	if !ok {
+		return
+	}
+
+	query := "SELECT token FROM " + model.TableNameFencingToken + " WHERE name = ?"
+	// SQLite 同一时间只有一个写事务，不需要也不支持加共享锁
+	if db.Dialector.Name() != "sqlite" {
+		query += " LOCK IN SHARE MODE"
+	}
+	var token int64
+	row := db.Statement.ConnPool.QueryRowContext(db.Statement.Context, query, f.name)
+	if err := row.Scan(&token); err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if token != f.token {
+		_ = db.AddError(ErrStaleFencingToken)
+	}
+}
@@ -1,5 +1,17 @@
 package store
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+)
+
 const (
 	defaultLimitValue = 10
 	defaultOrderValue = "id desc"
@@ -18,3 +30,112 @@ func defaultOrder(order string) string {
 	}
 	return order
 }
+
+// 允许在查询条件中使用的运算符
+var operators = []meta.Operator{
+	meta.OpEq, meta.OpNe, meta.OpGt, meta.OpGte, meta.OpLt, meta.OpLte, meta.OpIn, meta.OpNotIn, meta.OpLike,
+}
+
+// orderFields 允许排序的字段，值为从记录中取出该字段的值，用于生成游标
+// 必须包含 id，作为排序值相同时的决胜字段，保证游标分页不重复也不遗漏
+type orderFields[T any] map[string]func(T) any
+
+// resolveOrder 解析并校验排序参数，排序字段不包含 id 时追加 id 使排序唯一
+func (fields orderFields[T]) resolveOrder(order string) ([]meta.OrderBy, error) {
+	orders, err := meta.ParseOrder(defaultOrder(order))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		if _, ok := fields[o.Field]; !ok {
+			return nil, fmt.Errorf("%w: unsupported order field %q", meta.ErrInvalidOption, o.Field)
+		}
+	}
+	if !slices.ContainsFunc(orders, func(o meta.OrderBy) bool { return o.Field == "id" }) {
+		orders = append(orders, meta.OrderBy{Field: "id", Desc: orders[len(orders)-1].Desc})
+	}
+	return orders, nil
+}
+
+// cursor 返回 row 之后一页的游标
+func (fields orderFields[T]) cursor(row T, order string) (string, error) {
+	orders, err := fields.resolveOrder(order)
+	if err != nil {
+		return "", err
+	}
+	c := &meta.Cursor{Order: meta.FormatOrder(orders)}
+	for _, o := range orders {
+		c.Values = append(c.Values, fields[o.Field](row))
+	}
+	return c.Encode(), nil
+}
+
+// list 将 o 转换为 gorm 查询，count 用于统计满足条件的总数，find 额外包含分页和排序
+func (fields orderFields[T]) list(db *gorm.DB, o meta.ListOptions) (count, find *gorm.DB, err error) {
+	orders, err := fields.resolveOrder(o.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := db.Where(o.Filters).Not(o.Not)
+	for _, c := range o.Conditions {
+		if !slices.Contains(operators, c.Op) {
+			return nil, nil, fmt.Errorf("%w: unsupported operator %q", meta.ErrInvalidOption, c.Op)
+		}
+		// 字段名称作为列名引用，不会被解释为 SQL
+		query = query.Where(fmt.Sprintf("? %s ?", c.Op), clause.Column{Name: c.Field}, c.Value)
+	}
+	count = query.Session(&gorm.Session{})
+
+	find = query.Session(&gorm.Session{}).Limit(defaultLimit(o.Limit))
+	if o.Cursor != "" {
+		expr, err := keyset(o.Cursor, orders)
+		if err != nil {
+			return nil, nil, err
+		}
+		find = find.Where(expr)
+	} else {
+		find = find.Offset(o.Offset)
+	}
+	for _, order := range orders {
+		find = find.Order(clause.OrderByColumn{Column: clause.Column{Name: order.Field}, Desc: order.Desc})
+	}
+	return count, find, nil
+}
+
+// keyset 将游标转换为查询条件，按排序字段的字典序取游标位置之后的记录
+// 例如 created_at desc, id desc 转换为 created_at < ? OR (created_at = ? AND id < ?)
+func keyset(cursor string, orders []meta.OrderBy) (clause.Expr, error) {
+	c, err := meta.DecodeCursor(cursor)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	if c.Order != meta.FormatOrder(orders) || len(c.Values) != len(orders) {
+		return clause.Expr{}, fmt.Errorf("%w: cursor does not match order %q", meta.ErrInvalidOption, meta.FormatOrder(orders))
+	}
+
+	var (
+		ors  []string
+		vars []any
+	)
+	for i, o := range orders {
+		var ands []string
+		for j := range i {
+			ands = append(ands, "? = ?")
+			vars = append(vars, clause.Column{Name: orders[j].Field}, c.Values[j])
+		}
+		op := ">"
+		if o.Desc {
+			op = "<"
+		}
+		ands = append(ands, "? "+op+" ?")
+		vars = append(vars, clause.Column{Name: o.Field}, c.Values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(ors, " OR ") + ")", Vars: vars}, nil
+}
+
+// cursorTime 将时间格式化为 datetime 字面量，保持读取时的时区，避免时区转换导致游标位置偏移
+func cursorTime(t time.Time) any {
+	return t.Format("2006-01-02 15:04:05.999999")
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// dryRunDB 返回只生成 SQL 不连接数据库的 gorm.DB
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/db", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return db
+}
+
+func TestResolveOrder(t *testing.T) {
+	tests := []struct {
+		order   string
+		want    string
+		wantErr bool
+	}{
+		{order: "", want: "id desc"},
+		{order: "id", want: "id asc"},
+		{order: "created_at desc", want: "created_at desc, id desc"},
+		{order: "  updated_at   DESC ", want: "updated_at desc, id desc"},
+		{order: "priority desc, id asc", want: "priority desc, id asc"},
+		{order: "name", wantErr: true},
+		{order: "id; drop table task", wantErr: true},
+		{order: "id up", wantErr: true},
+		{order: "id asc desc", wantErr: true},
+		{order: "id,", wantErr: true},
+	}
+	for _, tt := range tests {
+		orders, err := taskOrderFields.resolveOrder(tt.order)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("resolveOrder(%q) error = %v, wantErr %v", tt.order, err, tt.wantErr)
+		}
+		if err != nil {
+			if !errors.Is(err, meta.ErrInvalidOption) {
+				t.Fatalf("resolveOrder(%q) error = %v, want %v", tt.order, err, meta.ErrInvalidOption)
+			}
+			continue
+		}
+		if got := meta.FormatOrder(orders); got != tt.want {
+			t.Fatalf("resolveOrder(%q) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestListQuery(t *testing.T) {
+	db := dryRunDB(t)
+	o := meta.NewListOptions(
+		meta.WithFilter(map[string]any{"namespace": "demo"}),
+		meta.WithCondition("status", meta.OpIn, []string{"Normal", "Running"}),
+		meta.WithRange("created_at", "2024-01-01 00:00:00", "2024-02-01 00:00:00"),
+		meta.WithContains("name", "50%_off"),
+		meta.WithOffset(20),
+		meta.WithLimit(10),
+		meta.WithOrder("created_at desc"),
+	)
+
+	countQuery, findQuery, err := taskOrderFields.list(db, o)
+	if err != nil {
+		t.Fatalf("list() error = %v", err)
+	}
+	where := "WHERE `namespace` = ? AND `status` IN (?,?) AND `created_at` >= ? AND `created_at` < ? AND `name` LIKE ?"
+	if got, want := countQuery.Model(&model.Task{}).Count(new(int64)).Statement.SQL.String(),
+		"SELECT count(*) FROM `task` "+where; got != want {
+		t.Fatalf("count SQL = %s\nwant %s", got, want)
+	}
+	stmt := findQuery.Find(&[]*model.Task{}).Statement
+	if got, want := stmt.SQL.String(),
+		"SELECT * FROM `task` "+where+" ORDER BY `created_at` DESC,`id` DESC LIMIT ? OFFSET ?"; got != want {
+		t.Fatalf("find SQL = %s\nwant %s", got, want)
+	}
+	if got := stmt.Vars[5]; got != `%50\%\_off%` {
+		t.Fatalf("LIKE pattern = %v", got)
+	}
+
+	// 游标分页忽略 Offset，按排序字段的字典序取游标之后的记录
+	cursor, err := TaskCursor(&model.Task{ID: 7}, "created_at desc")
+	if err != nil {
+		t.Fatalf("TaskCursor() error = %v", err)
+	}
+	o = meta.NewListOptions(meta.WithOrder("created_at desc"), meta.WithOffset(20), meta.WithCursor(cursor))
+	_, findQuery, err = taskOrderFields.list(db, o)
+	if err != nil {
+		t.Fatalf("list() with cursor error = %v", err)
+	}
+	if got, want := findQuery.Find(&[]*model.Task{}).Statement.SQL.String(),
+		"SELECT * FROM `task` WHERE ((`created_at` < ?) OR (`created_at` = ? AND `id` < ?)) ORDER BY `created_at` DESC,`id` DESC LIMIT ?"; got != want {
+		t.Fatalf("find SQL with cursor = %s\nwant %s", got, want)
+	}
+
+	for _, opts := range [][]meta.ListOption{
+		{meta.WithOrder("id asc"), meta.WithCursor(cursor)},
+		{meta.WithCursor("not-a-cursor")},
+		{meta.WithCondition("id", "; drop table task", 1)},
+		{meta.WithOrder("name")},
+	} {
+		if _, _, err := taskOrderFields.list(db, meta.NewListOptions(opts...)); !errors.Is(err, meta.ErrInvalidOption) {
+			t.Fatalf("list() error = %v, want %v", err, meta.ErrInvalidOption)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// memoryStore 数据保存在进程内存中的 IStore 实现，不依赖 MySQL，用于本地运行和单元测试，进程退出后数据丢失
+// 事务之间以及事务与普通读写之间串行执行，事务内的修改在提交前对其他调用不可见，返回错误时全部丢弃
+type memoryStore struct {
+	mu     sync.Mutex
+	tables *memoryTables
+}
+
+var _ IStore = (*memoryStore)(nil)
+
+type memoryTxKey struct{}
+
+// memoryTables 内存中的各个表，保存的记录只会被整体替换，不会原地修改，复制表时只需要复制容器
+type memoryTables struct {
+	seq           map[string]int64 // 各表的自增 ID
+	tasks         map[int64]*model.Task
+	dependencies  []*model.TaskDependency
+	events        []*model.TaskEvent
+	results       []*model.TaskResult
+	notifications map[int64]*model.TaskNotification
+	schedules     map[int64]*model.TaskSchedule
+	watchers      map[string]*model.WatcherConfig
+	fencing       map[string]*model.FencingToken
+}
+
+// NewMemoryStore 创建空的内存存储，每次调用返回独立的实例
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{tables: &memoryTables{
+		seq:           make(map[string]int64),
+		tasks:         make(map[int64]*model.Task),
+		notifications: make(map[int64]*model.TaskNotification),
+		schedules:     make(map[int64]*model.TaskSchedule),
+		watchers:      make(map[string]*model.WatcherConfig),
+		fencing:       make(map[string]*model.FencingToken),
+	}}
+}
+
+func (t *memoryTables) clone() *memoryTables {
+	return &memoryTables{
+		seq:           maps.Clone(t.seq),
+		tasks:         maps.Clone(t.tasks),
+		dependencies:  slices.Clone(t.dependencies),
+		events:        slices.Clone(t.events),
+		results:       slices.Clone(t.results),
+		notifications: maps.Clone(t.notifications),
+		schedules:     maps.Clone(t.schedules),
+		watchers:      maps.Clone(t.watchers),
+		fencing:       maps.Clone(t.fencing),
+	}
+}
+
+// nextID 返回 table 的下一个自增 ID
+func (t *memoryTables) nextID(table string) int64 {
+	t.seq[table]++
+	return t.seq[table]
+}
+
+// view 在 ctx 所在的事务中执行 fn，不在事务中时加锁执行
+func (s *memoryStore) view(ctx context.Context, fn func(t *memoryTables) error) error {
+	if t, ok := ctx.Value(memoryTxKey{}).(*memoryTables); ok {
+		return fn(t)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.tables)
+}
+
+// write 与 view 相同，执行前校验 ctx 携带的 fencing token，fn 需要在修改之前完成所有校验
+func (s *memoryStore) write(ctx context.Context, fn func(t *memoryTables) error) error {
+	return s.view(ctx, func(t *memoryTables) error {
+		if f, ok := ctx.Value(fencingKey{}).(fencing); ok {
+			if ft := t.fencing[f.name]; ft == nil || ft.Token != f.token {
+				return ErrStaleFencingToken
+			}
+		}
+		return fn(t)
+	})
+}
+
+// TX 在表的副本上执行 fn，fn 返回 nil 时提交，嵌套调用时加入外层事务
+func (s *memoryStore) TX(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTables); ok {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.tables.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, tx)); err != nil {
+		return err
+	}
+	s.tables = tx
+	return nil
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *memoryStore) Tasks() TaskStore {
+	return &memoryTaskStore{s}
+}
+
+func (s *memoryStore) TaskEvents() TaskEventStore {
+	return &memoryTaskEventStore{s}
+}
+
+func (s *memoryStore) Notifications() NotificationStore {
+	return &memoryNotificationStore{s}
+}
+
+func (s *memoryStore) Schedules() ScheduleStore {
+	return &memoryScheduleStore{s}
+}
+
+func (s *memoryStore) Watchers() WatcherConfigStore {
+	return &memoryWatcherConfigStore{s}
+}
+
+func (s *memoryStore) Fencing() FencingStore {
+	return &memoryFencingStore{s}
+}
+
+// now 返回写入时间，去掉单调时钟并截断到微秒，与游标中时间的精度一致
+func now() time.Time {
+	return time.Now().Round(0).Truncate(time.Microsecond)
+}
+
+// timestamp 返回 t 截断到微秒后的值，t 为零值时返回当前时间
+func timestamp(t time.Time) time.Time {
+	if t.IsZero() {
+		return now()
+	}
+	return t.Round(0).Truncate(time.Microsecond)
+}
+
+// ptr 返回 v 的副本，避免调用方修改保存的记录
+func ptr[T any](v T) *T {
+	return &v
+}
+
+type memoryTaskEventStore struct {
+	s *memoryStore
+}
+
+func (d *memoryTaskEventStore) Create(ctx context.Context, event *model.TaskEvent) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		event.ID = t.nextID(model.TableNameTaskEvent)
+		event.CreatedAt = timestamp(event.CreatedAt)
+		t.events = append(t.events, ptr(*event))
+		return nil
+	})
+}
+
+func (d *memoryTaskEventStore) List(ctx context.Context, taskID int64) (ret []*model.TaskEvent, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, e := range t.events {
+			if e.TaskID == taskID {
+				ret = append(ret, ptr(*e))
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+type memoryNotificationStore struct {
+	s *memoryStore
+}
+
+func (d *memoryNotificationStore) Create(ctx context.Context, notifications ...*model.TaskNotification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return d.s.write(ctx, func(t *memoryTables) error {
+		for _, n := range notifications {
+			n.ID = t.nextID(model.TableNameTaskNotification)
+			n.CreatedAt, n.UpdatedAt = timestamp(n.CreatedAt), timestamp(n.UpdatedAt)
+			t.notifications[n.ID] = ptr(*n)
+		}
+		return nil
+	})
+}
+
+func (d *memoryNotificationStore) Update(ctx context.Context, notification *model.TaskNotification) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if _, ok := t.notifications[notification.ID]; !ok {
+			return gorm.ErrRecordNotFound
+		}
+		notification.UpdatedAt = now()
+		t.notifications[notification.ID] = ptr(*notification)
+		return nil
+	})
+}
+
+func (d *memoryNotificationStore) ListDue(ctx context.Context, now time.Time, limit int) (ret []*model.TaskNotification, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, n := range t.notifications {
+			if n.Status == model.NotificationStatusPending && !n.NextAttemptAt.After(now) {
+				ret = append(ret, ptr(*n))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(ret, func(a, b *model.TaskNotification) int {
+		if c := a.NextAttemptAt.Compare(b.NextAttemptAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, err
+}
+
+func (d *memoryNotificationStore) List(ctx context.Context, taskID int64) (ret []*model.TaskNotification, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, id := range slices.Sorted(maps.Keys(t.notifications)) {
+			if n := t.notifications[id]; n.TaskID == taskID {
+				ret = append(ret, ptr(*n))
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+type memoryWatcherConfigStore struct {
+	s *memoryStore
+}
+
+func (d *memoryWatcherConfigStore) List(ctx context.Context) (ret []*model.WatcherConfig, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, name := range slices.Sorted(maps.Keys(t.watchers)) {
+			ret = append(ret, ptr(*t.watchers[name]))
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func (d *memoryWatcherConfigStore) Save(ctx context.Context, config *model.WatcherConfig) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if current, ok := t.watchers[config.Name]; ok {
+			updated := ptr(*current)
+			updated.Enabled, updated.Spec, updated.UpdatedAt = config.Enabled, config.Spec, timestamp(config.UpdatedAt)
+			t.watchers[config.Name] = updated
+			return nil
+		}
+		config.ID = t.nextID(model.TableNameWatcherConfig)
+		config.CreatedAt, config.UpdatedAt = timestamp(config.CreatedAt), timestamp(config.UpdatedAt)
+		t.watchers[config.Name] = ptr(*config)
+		return nil
+	})
+}
+
+type memoryFencingStore struct {
+	s *memoryStore
+}
+
+// Next 不在调用方的事务中执行，递增立即生效
+func (d *memoryFencingStore) Next(ctx context.Context, name string) (int64, error) {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	ft := &model.FencingToken{Name: name, Token: 1}
+	if current, ok := d.s.tables.fencing[name]; ok {
+		ft.Token = current.Token + 1
+	}
+	ft.UpdatedAt = now()
+	d.s.tables.fencing[name] = ft
+	return ft.Token, nil
+}
@@ -0,0 +1,405 @@
+package store
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+)
+
+// listRows 在内存中按 o 过滤、排序和分页，语义与 orderFields.list 生成的 SQL 相同
+func listRows[T any](rows []T, fields orderFields[T], o meta.ListOptions) (int64, []T, error) {
+	orders, err := fields.resolveOrder(o.Order)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, c := range o.Conditions {
+		if !slices.Contains(operators, c.Op) {
+			return 0, nil, fmt.Errorf("%w: unsupported operator %q", meta.ErrInvalidOption, c.Op)
+		}
+	}
+
+	var matched []T
+	for _, row := range rows {
+		ok, err := matchRow(row, o)
+		if err != nil {
+			return 0, nil, err
+		}
+		if ok {
+			matched = append(matched, row)
+		}
+	}
+
+	var sortErr error
+	slices.SortFunc(matched, func(a, b T) int {
+		c, err := compareRows(a, b, orders)
+		sortErr = cmp.Or(sortErr, err)
+		return c
+	})
+	if sortErr != nil {
+		return 0, nil, sortErr
+	}
+
+	count := int64(len(matched))
+	page := matched
+	if o.Cursor != "" {
+		c, err := meta.DecodeCursor(o.Cursor)
+		if err != nil {
+			return 0, nil, err
+		}
+		if c.Order != meta.FormatOrder(orders) || len(c.Values) != len(orders) {
+			return 0, nil, fmt.Errorf("%w: cursor does not match order %q", meta.ErrInvalidOption, meta.FormatOrder(orders))
+		}
+		// 跳过不在游标之后的记录
+		i := 0
+		for ; i < len(page); i++ {
+			c, err := compareCursor(page[i], orders, c.Values)
+			if err != nil {
+				return 0, nil, err
+			}
+			if c > 0 {
+				break
+			}
+		}
+		page = page[i:]
+	} else {
+		page = page[min(o.Offset, len(page)):]
+	}
+	page = page[:min(defaultLimit(o.Limit), len(page))]
+
+	ret := make([]T, 0, len(page))
+	for _, row := range page {
+		ret = append(ret, clone(row))
+	}
+	return count, ret, nil
+}
+
+// clone 返回 row 指向的记录的副本
+func clone[T any](row T) T {
+	v := reflect.ValueOf(row)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return row
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(T)
+}
+
+// compareRows 按排序字段比较两条记录
+func compareRows(a, b any, orders []meta.OrderBy) (int, error) {
+	for _, o := range orders {
+		x, err := columnValue(a, o.Field)
+		if err != nil {
+			return 0, err
+		}
+		y, err := columnValue(b, o.Field)
+		if err != nil {
+			return 0, err
+		}
+		c, err := compareNullable(x, y)
+		if err != nil {
+			return 0, err
+		}
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// compareCursor 比较记录与游标的位置，记录排在游标之后时返回正数
+func compareCursor(row any, orders []meta.OrderBy, values []any) (int, error) {
+	for i, o := range orders {
+		x, err := columnValue(row, o.Field)
+		if err != nil {
+			return 0, err
+		}
+		c, err := compareNullable(x, values[i])
+		if err != nil {
+			return 0, err
+		}
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// compareNullable 与 MySQL 排序一致，NULL 小于其他值
+func compareNullable(a, b any) (int, error) {
+	a, b = sqlValue(a), sqlValue(b)
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+	return compareValues(a, b)
+}
+
+// matchRow 判断记录是否满足 o 中的 Filters、Not 和 Conditions
+func matchRow(row any, o meta.ListOptions) (bool, error) {
+	for field, value := range o.Filters {
+		ok, err := matchColumn(row, field, value, false)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for field, value := range o.Not {
+		ok, err := matchColumn(row, field, value, true)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, c := range o.Conditions {
+		v, err := columnValue(row, c.Field)
+		if err != nil {
+			return false, err
+		}
+		ok, err := matchCondition(v, c.Op, c.Value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchColumn 对应 gorm 将 map 转换的条件：值为 nil 时为 IS NULL，值为切片时为 IN，否则为 =，not 为 true 时取反
+func matchColumn(row any, field string, value any, not bool) (bool, error) {
+	v, err := columnValue(row, field)
+	if err != nil {
+		return false, err
+	}
+	if sqlValue(value) == nil {
+		return (sqlValue(v) == nil) != not, nil
+	}
+	op := meta.OpEq
+	if isSlice(value) {
+		op = meta.OpIn
+	}
+	if not {
+		op = map[meta.Operator]meta.Operator{meta.OpEq: meta.OpNe, meta.OpIn: meta.OpNotIn}[op]
+	}
+	return matchCondition(v, op, value)
+}
+
+// matchCondition 计算 v op value，与 SQL 相同，v 为 NULL 时总是不满足
+func matchCondition(v any, op meta.Operator, value any) (bool, error) {
+	v = sqlValue(v)
+	if v == nil {
+		return false, nil
+	}
+
+	switch op {
+	case meta.OpIn, meta.OpNotIn:
+		if !isSlice(value) {
+			return false, fmt.Errorf("%w: value of %s must be a slice", meta.ErrInvalidOption, op)
+		}
+		values := reflect.ValueOf(value)
+		for i := range values.Len() {
+			item := sqlValue(values.Index(i).Interface())
+			if item == nil {
+				continue
+			}
+			c, err := compareValues(v, item)
+			if err != nil {
+				return false, err
+			}
+			if c == 0 {
+				return op == meta.OpIn, nil
+			}
+		}
+		return op == meta.OpNotIn, nil
+	case meta.OpLike:
+		s, ok1 := v.(string)
+		pattern, ok2 := sqlValue(value).(string)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("%w: LIKE requires string operands", meta.ErrInvalidOption)
+		}
+		return likePattern(pattern).MatchString(s), nil
+	}
+
+	value = sqlValue(value)
+	if value == nil {
+		return false, nil
+	}
+	c, err := compareValues(v, value)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case meta.OpEq:
+		return c == 0, nil
+	case meta.OpNe:
+		return c != 0, nil
+	case meta.OpGt:
+		return c > 0, nil
+	case meta.OpGte:
+		return c >= 0, nil
+	case meta.OpLt:
+		return c < 0, nil
+	case meta.OpLte:
+		return c <= 0, nil
+	}
+	return false, fmt.Errorf("%w: unsupported operator %q", meta.ErrInvalidOption, op)
+}
+
+// likePattern 将以 \ 转义的 LIKE 模式转换为正则表达式
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(`.*`)
+		case r == '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+func isSlice(v any) bool {
+	k := reflect.ValueOf(v).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// sqlValue 将字段值或查询参数转换为便于比较的值：整数和布尔值为 int64，浮点数为 float64，字符串类型为 string，空指针为 nil
+func sqlValue(v any) any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return x
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		return sqlValue(rv.Elem().Interface())
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return int64(1)
+		}
+		return int64(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	}
+	return v
+}
+
+// 游标和查询参数中字符串形式的时间格式
+var timeLayouts = []string{"2006-01-02 15:04:05.999999", "2006-01-02", time.RFC3339Nano}
+
+// compareValues 比较两个经过 sqlValue 转换且不为 nil 的值，与 MySQL 相同，字符串与时间、数字比较时先进行转换
+func compareValues(a, b any) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, y), nil
+		case float64:
+			return cmp.Compare(float64(x), y), nil
+		case string:
+			f, err := strconv.ParseFloat(y, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: cannot compare %v with %q", meta.ErrInvalidOption, x, y)
+			}
+			return cmp.Compare(float64(x), f), nil
+		}
+	case float64:
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, float64(y)), nil
+		}
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y), nil
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), nil
+		case int64, float64, time.Time:
+			c, err := compareValues(b, a)
+			return -c, err
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return x.Compare(y), nil
+		case string:
+			for _, layout := range timeLayouts {
+				if t, err := time.ParseInLocation(layout, y, time.Local); err == nil {
+					return x.Compare(t), nil
+				}
+			}
+			return 0, fmt.Errorf("%w: invalid time %q", meta.ErrInvalidOption, y)
+		}
+	}
+	return 0, fmt.Errorf("%w: cannot compare %T with %T", meta.ErrInvalidOption, a, b)
+}
+
+// 各个模型的列名到字段下标的映射
+var columnIndexes sync.Map
+
+// columnValue 返回 row 中 gorm 标签为 column 的字段值
+func columnValue(row any, column string) (any, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	indexes, ok := columnIndexes.Load(v.Type())
+	if !ok {
+		m := make(map[string]int)
+		for i := range v.NumField() {
+			for _, tag := range strings.Split(v.Type().Field(i).Tag.Get("gorm"), ";") {
+				if name, ok := strings.CutPrefix(tag, "column:"); ok {
+					m[name] = i
+				}
+			}
+		}
+		indexes, _ = columnIndexes.LoadOrStore(v.Type(), m)
+	}
+	i, ok := indexes.(map[string]int)[column]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q of %s", column, v.Type().Name())
+	}
+	return v.Field(i).Interface(), nil
+}
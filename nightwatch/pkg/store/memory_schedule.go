@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type memoryScheduleStore struct {
+	s *memoryStore
+}
+
+// duplicatedSchedule 判断是否已经存在同名的定时任务，对应 uk_name_namespace
+func (t *memoryTables) duplicatedSchedule(schedule *model.TaskSchedule) bool {
+	for _, v := range t.schedules {
+		if v.ID != schedule.ID && v.Name == schedule.Name && v.Namespace == schedule.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *memoryScheduleStore) Create(ctx context.Context, schedule *model.TaskSchedule) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if _, ok := t.schedules[schedule.ID]; ok || t.duplicatedSchedule(schedule) {
+			return gorm.ErrDuplicatedKey
+		}
+		if schedule.ID == 0 {
+			schedule.ID = t.nextID(model.TableNameTaskSchedule)
+		}
+		schedule.CreatedAt, schedule.UpdatedAt = timestamp(schedule.CreatedAt), timestamp(schedule.UpdatedAt)
+		t.schedules[schedule.ID] = ptr(*schedule)
+		return nil
+	})
+}
+
+func (d *memoryScheduleStore) Get(ctx context.Context, scheduleID string) (ret *model.TaskSchedule, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		id, _ := strconv.ParseInt(scheduleID, 10, 64)
+		schedule, ok := t.schedules[id]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		ret = ptr(*schedule)
+		return nil
+	})
+	return ret, err
+}
+
+func (d *memoryScheduleStore) List(ctx context.Context, opts ...meta.ListOption) (count int64, ret []*model.TaskSchedule, err error) {
+	o := meta.NewListOptions(opts...)
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		count, ret, err = listRows(slices.Collect(maps.Values(t.schedules)), scheduleOrderFields, o)
+		return err
+	})
+	return count, ret, err
+}
+
+// Update 与 gorm 的 Save 相同，定时任务不存在时创建
+func (d *memoryScheduleStore) Update(ctx context.Context, schedule *model.TaskSchedule) error {
+	if schedule.ID == 0 {
+		return d.Create(ctx, schedule)
+	}
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if t.duplicatedSchedule(schedule) {
+			return gorm.ErrDuplicatedKey
+		}
+		schedule.CreatedAt, schedule.UpdatedAt = timestamp(schedule.CreatedAt), now()
+		t.schedules[schedule.ID] = ptr(*schedule)
+		return nil
+	})
+}
+
+func (d *memoryScheduleStore) UpdateWhere(ctx context.Context, schedule *model.TaskSchedule, conds map[string]any) (updated bool, err error) {
+	err = d.s.write(ctx, func(t *memoryTables) error {
+		current, ok := t.schedules[schedule.ID]
+		if !ok {
+			return nil
+		}
+		if updated, err = matchRow(current, meta.ListOptions{Filters: conds}); err != nil || !updated {
+			return err
+		}
+		if t.duplicatedSchedule(schedule) {
+			updated = false
+			return gorm.ErrDuplicatedKey
+		}
+		schedule.UpdatedAt = now()
+		t.schedules[schedule.ID] = ptr(*schedule)
+		return nil
+	})
+	return updated, err
+}
+
+func (d *memoryScheduleStore) Delete(ctx context.Context, scheduleID string) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		id, _ := strconv.ParseInt(scheduleID, 10, 64)
+		delete(t.schedules, id)
+		return nil
+	})
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type memoryTaskStore struct {
+	s *memoryStore
+}
+
+// duplicatedTask 判断是否已经存在同名的任务，对应 uk_name_namespace
+func (t *memoryTables) duplicatedTask(task *model.Task) bool {
+	for _, v := range t.tasks {
+		if v.ID != task.ID && v.Name == task.Name && v.Namespace == task.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *memoryTaskStore) Create(ctx context.Context, task *model.Task) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if _, ok := t.tasks[task.ID]; ok || t.duplicatedTask(task) {
+			return gorm.ErrDuplicatedKey
+		}
+		if task.ID == 0 {
+			task.ID = t.nextID(model.TableNameTask)
+		}
+		task.CreatedAt, task.UpdatedAt = timestamp(task.CreatedAt), timestamp(task.UpdatedAt)
+		t.tasks[task.ID] = ptr(*task)
+		return nil
+	})
+}
+
+func (d *memoryTaskStore) Get(ctx context.Context, taskID string) (ret *model.Task, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		id, _ := strconv.ParseInt(taskID, 10, 64)
+		task, ok := t.tasks[id]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		ret = ptr(*task)
+		return nil
+	})
+	return ret, err
+}
+
+func (d *memoryTaskStore) List(ctx context.Context, opts ...meta.ListOption) (count int64, ret []*model.Task, err error) {
+	o := meta.NewListOptions(opts...)
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		count, ret, err = listRows(slices.Collect(maps.Values(t.tasks)), taskOrderFields, o)
+		return err
+	})
+	return count, ret, err
+}
+
+func (d *memoryTaskStore) Update(ctx context.Context, task *model.Task) error {
+	return d.update(ctx, task, nil)
+}
+
+func (d *memoryTaskStore) UpdateWhere(ctx context.Context, task *model.Task, conds map[string]any) (bool, error) {
+	err := d.update(ctx, task, conds)
+	if IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// update 与 taskStore.update 的语义相同，以 resource_version 作为乐观锁并校验状态变化是否合法
+func (d *memoryTaskStore) update(ctx context.Context, task *model.Task, conds map[string]any) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		current, ok := t.tasks[task.ID]
+		if !ok {
+			return &ConflictError{TaskID: task.ID, ResourceVersion: task.ResourceVersion}
+		}
+		matched, err := matchRow(current, meta.ListOptions{Filters: conds})
+		if err != nil {
+			return err
+		}
+		if !matched || current.ResourceVersion != task.ResourceVersion ||
+			!slices.Contains(model.PreviousStatuses(task.Status), current.Status) {
+			if current.ResourceVersion == task.ResourceVersion && !current.Status.CanTransitionTo(task.Status) {
+				return fmt.Errorf("%w: task %d from %s to %s", ErrInvalidTransition, task.ID, current.Status, task.Status)
+			}
+			return &ConflictError{TaskID: task.ID, ResourceVersion: task.ResourceVersion}
+		}
+		if t.duplicatedTask(task) {
+			return gorm.ErrDuplicatedKey
+		}
+
+		task.ResourceVersion++
+		task.UpdatedAt = now()
+		t.tasks[task.ID] = ptr(*task)
+		return nil
+	})
+}
+
+func (d *memoryTaskStore) Delete(ctx context.Context, taskID string) error {
+	return d.s.write(ctx, func(t *memoryTables) error {
+		id, _ := strconv.ParseInt(taskID, 10, 64)
+		t.deleteTask(id)
+		return nil
+	})
+}
+
+// DeleteWhere 与 taskStore.DeleteWhere 的语义相同，以 resource_version 作为乐观锁
+func (d *memoryTaskStore) DeleteWhere(ctx context.Context, task *model.Task, conds map[string]any) (deleted bool, err error) {
+	err = d.s.write(ctx, func(t *memoryTables) error {
+		current, ok := t.tasks[task.ID]
+		if !ok || current.ResourceVersion != task.ResourceVersion {
+			return nil
+		}
+		matched, err := matchRow(current, meta.ListOptions{Filters: conds})
+		if err != nil || !matched {
+			return err
+		}
+		t.deleteTask(task.ID)
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// deleteTask 删除任务以及任务的依赖关系、状态变化、执行结果和通知
+func (t *memoryTables) deleteTask(id int64) {
+	delete(t.tasks, id)
+	t.dependencies = slices.DeleteFunc(t.dependencies, func(dep *model.TaskDependency) bool { return dep.TaskID == id })
+	t.events = slices.DeleteFunc(t.events, func(e *model.TaskEvent) bool { return e.TaskID == id })
+	t.results = slices.DeleteFunc(t.results, func(r *model.TaskResult) bool { return r.TaskID == id })
+	maps.DeleteFunc(t.notifications, func(_ int64, n *model.TaskNotification) bool { return n.TaskID == id })
+}
+
+func (d *memoryTaskStore) CountByStatus(ctx context.Context) (ret map[model.TaskStatus]int64, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		ret = make(map[model.TaskStatus]int64)
+		for _, task := range t.tasks {
+			ret[task.Status]++
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// CountByOwner 按用户 ID 和 namespace 排序返回
+func (d *memoryTaskStore) CountByOwner(ctx context.Context, statuses []model.TaskStatus) (ret []*model.TaskUsage, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		usages := make(map[model.TaskUsage]int64)
+		for _, task := range t.tasks {
+			if slices.Contains(statuses, task.Status) {
+				usages[model.TaskUsage{UserID: task.UserID, Namespace: task.Namespace}]++
+			}
+		}
+		for u, count := range usages {
+			u.Count = count
+			ret = append(ret, ptr(u))
+		}
+		slices.SortFunc(ret, func(a, b *model.TaskUsage) int {
+			return cmp.Or(cmp.Compare(a.UserID, b.UserID), cmp.Compare(a.Namespace, b.Namespace))
+		})
+		return nil
+	})
+	return ret, err
+}
+
+// AddDependencies 与 taskStore.AddDependencies 的校验相同
+func (d *memoryTaskStore) AddDependencies(ctx context.Context, taskID int64, dependsOn []int64) error {
+	dependsOn = slices.Compact(slices.Sorted(slices.Values(dependsOn)))
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	if slices.Contains(dependsOn, taskID) {
+		return fmt.Errorf("%w: task %d depends on itself", ErrDependencyCycle, taskID)
+	}
+
+	return d.s.write(ctx, func(t *memoryTables) error {
+		missing := sets.New[int64]()
+		for _, id := range dependsOn {
+			if _, ok := t.tasks[id]; !ok {
+				missing.Insert(id)
+			}
+		}
+		if missing.Len() > 0 {
+			return fmt.Errorf("%w: %v", ErrUpstreamNotFound, sets.List(missing))
+		}
+
+		// 从上游任务开始沿依赖关系向上遍历，能够回到 taskID 说明形成环
+		visited := sets.New(dependsOn...)
+		for frontier := visited.Clone(); frontier.Len() > 0; {
+			next := sets.New[int64]()
+			for _, dep := range t.dependencies {
+				if !frontier.Has(dep.TaskID) {
+					continue
+				}
+				if dep.DependsOnID == taskID {
+					return fmt.Errorf("%w: task %d", ErrDependencyCycle, taskID)
+				}
+				if !visited.Has(dep.DependsOnID) {
+					visited.Insert(dep.DependsOnID)
+					next.Insert(dep.DependsOnID)
+				}
+			}
+			frontier = next
+		}
+
+		for _, id := range dependsOn {
+			if slices.ContainsFunc(t.dependencies, func(dep *model.TaskDependency) bool {
+				return dep.TaskID == taskID && dep.DependsOnID == id
+			}) {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+		for _, id := range dependsOn {
+			t.dependencies = append(t.dependencies, &model.TaskDependency{
+				ID:          t.nextID(model.TableNameTaskDependency),
+				TaskID:      taskID,
+				DependsOnID: id,
+				CreatedAt:   now(),
+			})
+		}
+		return nil
+	})
+}
+
+func (d *memoryTaskStore) ListDependencies(ctx context.Context, taskID int64) (ret []*model.TaskDependency, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, dep := range t.dependencies {
+			if dep.TaskID == taskID {
+				ret = append(ret, ptr(*dep))
+			}
+		}
+		slices.SortFunc(ret, func(a, b *model.TaskDependency) int { return cmp.Compare(a.DependsOnID, b.DependsOnID) })
+		return nil
+	})
+	return ret, err
+}
+
+func (d *memoryTaskStore) ListDownstreams(ctx context.Context, taskID int64) (ret []*model.Task, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, dep := range t.dependencies {
+			if task, ok := t.tasks[dep.TaskID]; ok && dep.DependsOnID == taskID {
+				ret = append(ret, ptr(*task))
+			}
+		}
+		slices.SortFunc(ret, func(a, b *model.Task) int { return cmp.Compare(a.ID, b.ID) })
+		return nil
+	})
+	return ret, err
+}
+
+func (d *memoryTaskStore) CreateResult(ctx context.Context, result *model.TaskResult) error {
+	result.Truncate()
+	return d.s.write(ctx, func(t *memoryTables) error {
+		if slices.ContainsFunc(t.results, func(r *model.TaskResult) bool {
+			return r.TaskID == result.TaskID && r.Attempt == result.Attempt
+		}) {
+			return nil
+		}
+		result.ID = t.nextID(model.TableNameTaskResult)
+		result.CreatedAt = timestamp(result.CreatedAt)
+		t.results = append(t.results, ptr(*result))
+		return nil
+	})
+}
+
+func (d *memoryTaskStore) ListResults(ctx context.Context, taskID int64) (ret []*model.TaskResult, err error) {
+	err = d.s.view(ctx, func(t *memoryTables) error {
+		for _, r := range t.results {
+			if r.TaskID == taskID {
+				ret = append(ret, ptr(*r))
+			}
+		}
+		slices.SortFunc(ret, func(a, b *model.TaskResult) int { return cmp.Compare(a.Attempt, b.Attempt) })
+		return nil
+	})
+	return ret, err
+}
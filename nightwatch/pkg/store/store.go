@@ -16,7 +16,13 @@ type txKey struct{}
 
 type IStore interface {
 	TX(context.Context, func(ctx context.Context) error) error
+	Ping(ctx context.Context) error
 	Tasks() TaskStore
+	TaskEvents() TaskEventStore
+	Notifications() NotificationStore
+	Schedules() ScheduleStore
+	Watchers() WatcherConfigStore
+	Fencing() FencingStore
 }
 
 type datastore struct {
@@ -27,19 +33,31 @@ var _ IStore = (*datastore)(nil)
 
 func NewStore(db *gorm.DB) *datastore {
 	once.Do(func() {
+		if err := registerFencingCallbacks(db); err != nil {
+			panic(err)
+		}
 		Store = &datastore{db}
 	})
 
 	return Store
 }
 
+// NewSQLiteStore 使用 db.NewSQLite 打开的数据库创建存储，每次调用返回独立的实例，用于本地运行和测试
+func NewSQLiteStore(db *gorm.DB) (*datastore, error) {
+	if err := registerFencingCallbacks(db); err != nil {
+		return nil, err
+	}
+	return &datastore{db}, nil
+}
+
 func (ds *datastore) Core(ctx context.Context) *gorm.DB {
 	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
 	if ok {
 		return tx
 	}
 
-	return ds.core
+	// 携带 ctx 才能在写入时校验 fencing token
+	return ds.core.WithContext(ctx)
 }
 
 func (ds *datastore) TX(ctx context.Context, fn func(ctx context.Context) error) error {
@@ -51,6 +69,35 @@ func (ds *datastore) TX(ctx context.Context, fn func(ctx context.Context) error)
 	)
 }
 
+// Ping 检查数据库连接是否可用
+func (ds *datastore) Ping(ctx context.Context) error {
+	db, err := ds.core.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
 func (ds *datastore) Tasks() TaskStore {
 	return newTaskStore(ds)
 }
+
+func (ds *datastore) TaskEvents() TaskEventStore {
+	return newTaskEventStore(ds)
+}
+
+func (ds *datastore) Notifications() NotificationStore {
+	return newNotificationStore(ds)
+}
+
+func (ds *datastore) Schedules() ScheduleStore {
+	return newScheduleStore(ds)
+}
+
+func (ds *datastore) Watchers() WatcherConfigStore {
+	return newWatcherConfigStore(ds)
+}
+
+func (ds *datastore) Fencing() FencingStore {
+	return newFencingStore(ds)
+}
@@ -0,0 +1,341 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/db"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// forEachStore 对每种存储实现分别执行 fn，每次使用空的存储
+func forEachStore(t *testing.T, fn func(t *testing.T, s IStore)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		fn(t, newSQLiteStore(t))
+	})
+}
+
+// newSQLiteStore 创建数据只保存在内存中的 SQLite 存储
+func newSQLiteStore(t *testing.T) IStore {
+	t.Helper()
+	gormDB, err := db.NewSQLite(&db.SQLiteOptions{Path: ":memory:", Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	s, err := NewSQLiteStore(gormDB)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	return s
+}
+
+func createTasks(t *testing.T, s IStore, n int) []*model.Task {
+	t.Helper()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	var tasks []*model.Task
+	for i := range n {
+		task := &model.Task{
+			Name:      fmt.Sprintf("task-%d", i),
+			Namespace: "default",
+			Status:    model.TaskStatusNormal,
+			UserID:    int64(i%2 + 1),
+			Priority:  i % 3,
+			CreatedAt: base.Add(time.Duration(i/2) * time.Hour),
+		}
+		if err := s.Tasks().Create(context.Background(), task); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+func TestStoreList(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		createTasks(t, s, 10)
+		ctx := context.Background()
+
+		tests := []struct {
+			name    string
+			opts    []meta.ListOption
+			wantIDs []int64
+			count   int64
+		}{
+			{"default order", []meta.ListOption{meta.WithLimit(3)}, []int64{10, 9, 8}, 10},
+			{"offset", []meta.ListOption{meta.WithOrder("id"), meta.WithOffset(8)}, []int64{9, 10}, 10},
+			{"filter", []meta.ListOption{meta.WithFilter(map[string]any{"user_id": int64(1), "priority": []int{0, 1}})}, []int64{7, 5, 1}, 3},
+			{"not", []meta.ListOption{meta.WithFilterNot(map[string]any{"user_id": 1, "priority": []int{0, 1}})}, []int64{6}, 1},
+			{"range", []meta.ListOption{meta.WithRange("created_at", "2024-01-01 01:00:00", time.Date(2024, 1, 1, 3, 0, 0, 0, time.Local))}, []int64{6, 5, 4, 3}, 4},
+			{"in", []meta.ListOption{meta.WithCondition("name", meta.OpIn, []string{"task-1", "task-3"}), meta.WithOrder("id")}, []int64{2, 4}, 2},
+			{"like", []meta.ListOption{meta.WithContains("name", "-1")}, []int64{2}, 1},
+			{"multiple orders", []meta.ListOption{meta.WithOrder("priority desc, created_at"), meta.WithLimit(4)}, []int64{3, 6, 9, 2}, 10},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				count, tasks, err := s.Tasks().List(ctx, tt.opts...)
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				var ids []int64
+				for _, task := range tasks {
+					ids = append(ids, task.ID)
+				}
+				if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) || count != tt.count {
+					t.Errorf("List() = %d, %v, want %d, %v", count, ids, tt.count, tt.wantIDs)
+				}
+			})
+		}
+
+		if _, _, err := s.Tasks().List(ctx, meta.WithOrder("name")); !errors.Is(err, meta.ErrInvalidOption) {
+			t.Errorf("List() with unsupported order error = %v, want ErrInvalidOption", err)
+		}
+	})
+}
+
+func TestStoreListCursor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		createTasks(t, s, 10)
+		ctx := context.Background()
+
+		for _, order := range []string{"created_at desc", "priority, created_at desc", "id"} {
+			var (
+				ids    []int64
+				cursor string
+			)
+			for {
+				opts := []meta.ListOption{meta.WithOrder(order), meta.WithLimit(3), meta.WithCursor(cursor)}
+				_, tasks, err := s.Tasks().List(ctx, opts...)
+				if err != nil {
+					t.Fatalf("List(%q) error = %v", order, err)
+				}
+				for _, task := range tasks {
+					ids = append(ids, task.ID)
+				}
+				if len(tasks) < 3 {
+					break
+				}
+				if cursor, err = TaskCursor(tasks[len(tasks)-1], order); err != nil {
+					t.Fatalf("TaskCursor() error = %v", err)
+				}
+			}
+
+			_, all, _ := s.Tasks().List(ctx, meta.WithOrder(order))
+			var want []int64
+			for _, task := range all {
+				want = append(want, task.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(want) {
+				t.Errorf("paged by cursor with order %q = %v, want %v", order, ids, want)
+			}
+		}
+	})
+}
+
+func TestStoreTX(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		ctx := context.Background()
+		errRollback := errors.New("rollback")
+
+		err := s.TX(ctx, func(ctx context.Context) error {
+			if err := s.Tasks().Create(ctx, &model.Task{Name: "rollback", Status: model.TaskStatusNormal}); err != nil {
+				return err
+			}
+			// 事务内可以读到未提交的修改
+			if count, _, _ := s.Tasks().List(ctx); count != 1 {
+				t.Errorf("count in transaction = %d, want 1", count)
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("TX() error = %v, want %v", err, errRollback)
+		}
+		if count, _, _ := s.Tasks().List(ctx); count != 0 {
+			t.Errorf("count after rollback = %d, want 0", count)
+		}
+
+		err = s.TX(ctx, func(ctx context.Context) error {
+			task := &model.Task{Name: "commit", Status: model.TaskStatusNormal}
+			if err := s.Tasks().Create(ctx, task); err != nil {
+				return err
+			}
+			return s.TaskEvents().Create(ctx, &model.TaskEvent{TaskID: task.ID, ToStatus: task.Status})
+		})
+		if err != nil {
+			t.Fatalf("TX() error = %v", err)
+		}
+		if count, _, _ := s.Tasks().List(ctx); count != 1 {
+			t.Errorf("count after commit = %d, want 1", count)
+		}
+		if events, _ := s.TaskEvents().List(ctx, 1); len(events) != 1 {
+			t.Errorf("events after commit = %d, want 1", len(events))
+		}
+	})
+}
+
+func TestStoreUpdate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		ctx := context.Background()
+		task := createTasks(t, s, 1)[0]
+
+		if err := s.Tasks().Create(ctx, &model.Task{Name: task.Name, Namespace: task.Namespace}); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("Create() duplicated error = %v, want ErrDuplicatedKey", err)
+		}
+
+		stale := *task
+		task.Status = model.TaskStatusPending
+		if err := s.Tasks().Update(ctx, task); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if task.ResourceVersion != 1 {
+			t.Errorf("ResourceVersion = %d, want 1", task.ResourceVersion)
+		}
+
+		// 基于旧版本的修改返回冲突
+		stale.Status = model.TaskStatusCancelled
+		if err := s.Tasks().Update(ctx, &stale); !IsConflict(err) {
+			t.Errorf("Update() stale error = %v, want conflict", err)
+		}
+
+		task.Status = model.TaskStatusQueued
+		ok, err := s.Tasks().UpdateWhere(ctx, task, map[string]any{"status": model.TaskStatusRunning})
+		if ok || err != nil {
+			t.Errorf("UpdateWhere() unmatched = %v, %v, want false, nil", ok, err)
+		}
+		ok, err = s.Tasks().UpdateWhere(ctx, task, map[string]any{"status": model.TaskStatusPending})
+		if !ok || err != nil {
+			t.Errorf("UpdateWhere() matched = %v, %v, want true, nil", ok, err)
+		}
+
+		task.Status = model.TaskStatusSucceeded
+		if err := s.Tasks().Update(ctx, task); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Update() invalid transition error = %v, want ErrInvalidTransition", err)
+		}
+
+		got, err := s.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10))
+		if err != nil || got.Status != model.TaskStatusQueued {
+			t.Errorf("Get() = %v, %v, want status Queued", got, err)
+		}
+		if _, err := s.Tasks().Get(ctx, "100"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Get() missing error = %v, want ErrRecordNotFound", err)
+		}
+	})
+}
+
+func TestStoreFencing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		ctx := context.Background()
+
+		token, err := s.Fencing().Next(ctx, "lock")
+		if err != nil || token != 1 {
+			t.Fatalf("Next() = %d, %v, want 1", token, err)
+		}
+		leaderCtx := WithFencingToken(ctx, "lock", token)
+		if err := s.Tasks().Create(leaderCtx, &model.Task{Name: "a"}); err != nil {
+			t.Fatalf("Create() with current token error = %v", err)
+		}
+
+		if token, _ := s.Fencing().Next(leaderCtx, "lock"); token != 2 {
+			t.Fatalf("Next() = %d, want 2", token)
+		}
+		if err := s.Tasks().Create(leaderCtx, &model.Task{Name: "b"}); !errors.Is(err, ErrStaleFencingToken) {
+			t.Errorf("Create() with stale token error = %v, want ErrStaleFencingToken", err)
+		}
+	})
+}
+
+func TestStoreDependencies(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		ctx := context.Background()
+		tasks := createTasks(t, s, 3)
+
+		if err := s.Tasks().AddDependencies(ctx, tasks[1].ID, []int64{tasks[0].ID}); err != nil {
+			t.Fatalf("AddDependencies() error = %v", err)
+		}
+		if err := s.Tasks().AddDependencies(ctx, tasks[2].ID, []int64{tasks[1].ID}); err != nil {
+			t.Fatalf("AddDependencies() error = %v", err)
+		}
+		if err := s.Tasks().AddDependencies(ctx, tasks[0].ID, []int64{tasks[2].ID}); !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("AddDependencies() cycle error = %v, want ErrDependencyCycle", err)
+		}
+		if err := s.Tasks().AddDependencies(ctx, tasks[0].ID, []int64{100}); !errors.Is(err, ErrUpstreamNotFound) {
+			t.Errorf("AddDependencies() missing upstream error = %v, want ErrUpstreamNotFound", err)
+		}
+
+		downstreams, err := s.Tasks().ListDownstreams(ctx, tasks[0].ID)
+		if err != nil || len(downstreams) != 1 || downstreams[0].ID != tasks[1].ID {
+			t.Errorf("ListDownstreams() = %v, %v, want task %d", downstreams, err, tasks[1].ID)
+		}
+	})
+}
+
+func TestStoreDeleteWhere(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s IStore) {
+		ctx := context.Background()
+		tasks := createTasks(t, s, 2)
+		task := tasks[1]
+		if err := s.Tasks().AddDependencies(ctx, task.ID, []int64{tasks[0].ID}); err != nil {
+			t.Fatalf("AddDependencies() error = %v", err)
+		}
+		event := model.NewTaskEvent(task, model.TaskStatusNormal, model.TaskEventReasonSubmitted, "")
+		if err := s.TaskEvents().Create(ctx, event); err != nil {
+			t.Fatalf("Create() event error = %v", err)
+		}
+		if err := s.Tasks().CreateResult(ctx, &model.TaskResult{TaskID: task.ID, Attempt: 1}); err != nil {
+			t.Fatalf("CreateResult() error = %v", err)
+		}
+		notification := &model.TaskNotification{TaskID: task.ID, EventID: event.ID, Status: model.NotificationStatusPending}
+		if err := s.Notifications().Create(ctx, notification); err != nil {
+			t.Fatalf("Create() notification error = %v", err)
+		}
+
+		// 状态不满足或版本已经变化时不删除
+		ok, err := s.Tasks().DeleteWhere(ctx, task, map[string]any{"status": model.TaskStatusRunning})
+		if ok || err != nil {
+			t.Errorf("DeleteWhere() unmatched = %v, %v, want false, nil", ok, err)
+		}
+		stale := *task
+		task.Status = model.TaskStatusPending
+		if err := s.Tasks().Update(ctx, task); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		ok, err = s.Tasks().DeleteWhere(ctx, &stale, map[string]any{"status": model.TaskStatusNormal})
+		if ok || err != nil {
+			t.Errorf("DeleteWhere() stale = %v, %v, want false, nil", ok, err)
+		}
+		if _, err := s.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10)); err != nil {
+			t.Fatalf("Get() after unmatched delete error = %v", err)
+		}
+
+		ok, err = s.Tasks().DeleteWhere(ctx, task, map[string]any{"status": model.TaskStatusPending})
+		if !ok || err != nil {
+			t.Fatalf("DeleteWhere() matched = %v, %v, want true, nil", ok, err)
+		}
+		if _, err := s.Tasks().Get(ctx, strconv.FormatInt(task.ID, 10)); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Get() deleted task error = %v, want ErrRecordNotFound", err)
+		}
+		deps, _ := s.Tasks().ListDependencies(ctx, task.ID)
+		events, _ := s.TaskEvents().List(ctx, task.ID)
+		results, _ := s.Tasks().ListResults(ctx, task.ID)
+		notifications, _ := s.Notifications().List(ctx, task.ID)
+		if len(deps)+len(events)+len(results)+len(notifications) > 0 {
+			t.Errorf("related records of deleted task: %d dependencies, %d events, %d results, %d notifications",
+				len(deps), len(events), len(results), len(notifications))
+		}
+	})
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 
@@ -15,7 +16,16 @@ type TaskStore interface {
 	Get(ctx context.Context, taskID string) (*model.Task, error)
 	List(ctx context.Context, opts ...meta.ListOption) (int64, []*model.Task, error)
 	Update(ctx context.Context, task *model.Task) error
+	UpdateWhere(ctx context.Context, task *model.Task, conds map[string]any) (bool, error)
 	Delete(ctx context.Context, taskID string) error
+	DeleteWhere(ctx context.Context, task *model.Task, conds map[string]any) (bool, error)
+	CountByStatus(ctx context.Context) (map[model.TaskStatus]int64, error)
+	CountByOwner(ctx context.Context, statuses []model.TaskStatus) ([]*model.TaskUsage, error)
+	AddDependencies(ctx context.Context, taskID int64, dependsOn []int64) error
+	ListDependencies(ctx context.Context, taskID int64) ([]*model.TaskDependency, error)
+	ListDownstreams(ctx context.Context, taskID int64) ([]*model.Task, error)
+	CreateResult(ctx context.Context, result *model.TaskResult) error
+	ListResults(ctx context.Context, taskID int64) ([]*model.TaskResult, error)
 }
 
 type taskStore struct {
@@ -43,32 +53,148 @@ func (d *taskStore) Get(ctx context.Context, taskID string) (*model.Task, error)
 	return task, nil
 }
 
+// 任务允许排序的字段
+var taskOrderFields = orderFields[*model.Task]{
+	"id":         func(t *model.Task) any { return t.ID },
+	"priority":   func(t *model.Task) any { return t.Priority },
+	"created_at": func(t *model.Task) any { return cursorTime(t.CreatedAt) },
+	"updated_at": func(t *model.Task) any { return cursorTime(t.UpdatedAt) },
+}
+
+// List 按 ListOptions 查询任务，排序字段不在允许范围内等参数错误时返回 meta.ErrInvalidOption
 func (d *taskStore) List(ctx context.Context, opts ...meta.ListOption) (count int64, ret []*model.Task, err error) {
 	o := meta.NewListOptions(opts...)
 
-	ans := d.db(ctx).
-		Where(o.Filters).
-		Not(o.Not).
-		Offset(o.Offset).
-		Limit(defaultLimit(o.Limit)).
-		Order(defaultOrder(o.Order)).
-		Find(&ret).
-		Offset(-1).
-		Limit(-1).
-		Count(&count)
+	countQuery, findQuery, err := taskOrderFields.list(d.db(ctx), o)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := findQuery.Find(&ret).Error; err != nil {
+		return 0, nil, err
+	}
+	err = countQuery.Model(&model.Task{}).Count(&count).Error
+	return count, ret, err
+}
 
-	return count, ret, ans.Error
+// TaskCursor 返回 task 之后一页的游标，order 与查询时 meta.WithOrder 的参数相同
+func TaskCursor(task *model.Task, order string) (string, error) {
+	return taskOrderFields.cursor(task, order)
 }
 
+// Update 以 resource_version 作为乐观锁更新任务，并校验状态变化是否合法
+// 任务已经被并发修改时返回 *ConflictError，状态变化不合法时返回 ErrInvalidTransition
 func (d *taskStore) Update(ctx context.Context, task *model.Task) error {
-	return d.db(ctx).Save(task).Error
+	return d.update(ctx, task, nil)
 }
 
-func (d *taskStore) Delete(ctx context.Context, taskID string) error {
-	err := d.db(ctx).Where("id = ?", taskID).Delete(&model.Task{}).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+// UpdateWhere 仅当表中的任务满足 conds 且未被并发修改时才更新，返回是否更新成功
+// conds 与 meta.WithFilter 格式相同，值为切片时表示 IN 查询
+func (d *taskStore) UpdateWhere(ctx context.Context, task *model.Task, conds map[string]any) (bool, error) {
+	err := d.update(ctx, task, conds)
+	if IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *taskStore) update(ctx context.Context, task *model.Task, conds map[string]any) error {
+	version := task.ResourceVersion
+	task.ResourceVersion++
+
+	tx := d.db(ctx).Model(task)
+	if len(conds) > 0 {
+		tx = tx.Where(conds)
+	}
+	ans := tx.Where("resource_version = ? AND status IN ?", version, model.PreviousStatuses(task.Status)).
+		Select("*").
+		Updates(task)
+	if ans.Error == nil && ans.RowsAffected > 0 {
+		return nil
+	}
+	task.ResourceVersion = version
+	if ans.Error != nil {
+		return ans.Error
+	}
+
+	// 没有更新任何记录时，区分是被并发修改还是状态变化不合法
+	current := &model.Task{}
+	if err := d.db(ctx).Select("status", "resource_version").Where("id = ?", task.ID).Take(current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ConflictError{TaskID: task.ID, ResourceVersion: version}
+		}
 		return err
 	}
+	if current.ResourceVersion == version && !current.Status.CanTransitionTo(task.Status) {
+		return fmt.Errorf("%w: task %d from %s to %s", ErrInvalidTransition, task.ID, current.Status, task.Status)
+	}
+	return &ConflictError{TaskID: task.ID, ResourceVersion: version}
+}
 
+// CountByStatus 统计各个状态的任务数量
+func (d *taskStore) CountByStatus(ctx context.Context) (map[model.TaskStatus]int64, error) {
+	var rows []struct {
+		Status model.TaskStatus
+		Count  int64
+	}
+	err := d.db(ctx).Model(&model.Task{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[model.TaskStatus]int64, len(rows))
+	for _, row := range rows {
+		ret[row.Status] = row.Count
+	}
+	return ret, nil
+}
+
+// CountByOwner 按用户和 namespace 统计处于 statuses 状态的任务数量
+func (d *taskStore) CountByOwner(ctx context.Context, statuses []model.TaskStatus) (ret []*model.TaskUsage, err error) {
+	err = d.db(ctx).Model(&model.Task{}).
+		Select("user_id, namespace, COUNT(*) AS count").
+		Where("status IN ?", statuses).
+		Group("user_id, namespace").
+		Scan(&ret).Error
+	return ret, err
+}
+
+// Delete 在一个事务中删除任务以及任务的依赖关系、状态变化、执行结果和通知
+func (d *taskStore) Delete(ctx context.Context, taskID string) error {
+	return d.ds.TX(ctx, func(ctx context.Context) error {
+		err := d.db(ctx).Where("id = ?", taskID).Delete(&model.Task{}).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return d.deleteRelated(ctx, taskID)
+	})
+}
+
+// DeleteWhere 仅当表中的任务满足 conds 且未被并发修改时才删除，与 Delete 一样删除任务相关的记录，返回是否删除成功
+// conds 与 meta.WithFilter 格式相同，值为切片时表示 IN 查询
+func (d *taskStore) DeleteWhere(ctx context.Context, task *model.Task, conds map[string]any) (bool, error) {
+	deleted := false
+	err := d.ds.TX(ctx, func(ctx context.Context) error {
+		tx := d.db(ctx).Where("id = ? AND resource_version = ?", task.ID, task.ResourceVersion)
+		if len(conds) > 0 {
+			tx = tx.Where(conds)
+		}
+		ans := tx.Delete(&model.Task{})
+		if ans.Error != nil || ans.RowsAffected == 0 {
+			return ans.Error
+		}
+		deleted = true
+		return d.deleteRelated(ctx, task.ID)
+	})
+	return deleted, err
+}
+
+// deleteRelated 删除任务的依赖关系、状态变化、执行结果和通知
+// 只删除任务依赖上游的关系，下游任务通过缺失的上游任务判断依赖无法满足
+func (d *taskStore) deleteRelated(ctx context.Context, taskID any) error {
+	for _, m := range []any{&model.TaskDependency{}, &model.TaskEvent{}, &model.TaskResult{}, &model.TaskNotification{}} {
+		if err := d.db(ctx).Where("task_id = ?", taskID).Delete(m).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
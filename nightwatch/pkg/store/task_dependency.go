@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+var (
+	// ErrDependencyCycle 任务依赖关系形成了环
+	ErrDependencyCycle = errors.New("task dependency cycle detected")
+	// ErrUpstreamNotFound 依赖的上游任务不存在
+	ErrUpstreamNotFound = errors.New("upstream task not found")
+)
+
+// AddDependencies 添加任务依赖的上游任务，上游任务不存在或依赖关系形成环时返回错误
+// 与任务创建在同一个事务中调用，保证任务和依赖关系同时生效
+func (d *taskStore) AddDependencies(ctx context.Context, taskID int64, dependsOn []int64) error {
+	dependsOn = slices.Compact(slices.Sorted(slices.Values(dependsOn)))
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	if slices.Contains(dependsOn, taskID) {
+		return fmt.Errorf("%w: task %d depends on itself", ErrDependencyCycle, taskID)
+	}
+
+	var ids []int64
+	if err := d.db(ctx).Model(&model.Task{}).Where("id IN ?", dependsOn).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) != len(dependsOn) {
+		missing := sets.New(dependsOn...).Delete(ids...)
+		return fmt.Errorf("%w: %v", ErrUpstreamNotFound, sets.List(missing))
+	}
+
+	// 从上游任务开始沿依赖关系向上遍历，能够回到 taskID 说明形成环
+	visited := sets.New(dependsOn...)
+	for frontier := dependsOn; len(frontier) > 0; {
+		var next []int64
+		if err := d.db(ctx).Model(&model.TaskDependency{}).Where("task_id IN ?", frontier).Pluck("depends_on_id", &next).Error; err != nil {
+			return err
+		}
+		frontier = nil
+		for _, id := range next {
+			if id == taskID {
+				return fmt.Errorf("%w: task %d", ErrDependencyCycle, taskID)
+			}
+			if !visited.Has(id) {
+				visited.Insert(id)
+				frontier = append(frontier, id)
+			}
+		}
+	}
+
+	deps := make([]*model.TaskDependency, 0, len(dependsOn))
+	for _, id := range dependsOn {
+		deps = append(deps, &model.TaskDependency{TaskID: taskID, DependsOnID: id})
+	}
+	return d.db(ctx).Create(&deps).Error
+}
+
+// ListDependencies 返回任务依赖的上游任务关系，上游任务被删除后关系仍然保留
+func (d *taskStore) ListDependencies(ctx context.Context, taskID int64) (ret []*model.TaskDependency, err error) {
+	err = d.db(ctx).Where("task_id = ?", taskID).Order("depends_on_id").Find(&ret).Error
+	return ret, err
+}
+
+// ListDownstreams 返回直接依赖该任务的下游任务
+func (d *taskStore) ListDownstreams(ctx context.Context, taskID int64) (ret []*model.Task, err error) {
+	downstreams := d.db(ctx).Model(&model.TaskDependency{}).Select("task_id").Where("depends_on_id = ?", taskID)
+	err = d.db(ctx).Where("id IN (?)", downstreams).Order("id").Find(&ret).Error
+	return ret, err
+}
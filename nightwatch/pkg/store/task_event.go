@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type TaskEventStore interface {
+	Create(ctx context.Context, event *model.TaskEvent) error
+	List(ctx context.Context, taskID int64) ([]*model.TaskEvent, error)
+}
+
+type taskEventStore struct {
+	ds *datastore
+}
+
+func newTaskEventStore(ds *datastore) *taskEventStore {
+	return &taskEventStore{ds}
+}
+
+func (d *taskEventStore) db(ctx context.Context) *gorm.DB {
+	return d.ds.Core(ctx)
+}
+
+func (d *taskEventStore) Create(ctx context.Context, event *model.TaskEvent) error {
+	return d.db(ctx).Create(&event).Error
+}
+
+// List 按发生顺序返回任务的所有状态变化记录
+func (d *taskEventStore) List(ctx context.Context, taskID int64) (ret []*model.TaskEvent, err error) {
+	err = d.db(ctx).Where("task_id = ?", taskID).Order("id").Find(&ret).Error
+	return ret, err
+}
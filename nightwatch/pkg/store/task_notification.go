@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type NotificationStore interface {
+	Create(ctx context.Context, notifications ...*model.TaskNotification) error
+	Update(ctx context.Context, notification *model.TaskNotification) error
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*model.TaskNotification, error)
+	List(ctx context.Context, taskID int64) ([]*model.TaskNotification, error)
+}
+
+type notificationStore struct {
+	ds *datastore
+}
+
+func newNotificationStore(ds *datastore) *notificationStore {
+	return &notificationStore{ds}
+}
+
+func (d *notificationStore) db(ctx context.Context) *gorm.DB {
+	return d.ds.Core(ctx)
+}
+
+// Create 保存等待发送的通知，没有通知时直接返回
+func (d *notificationStore) Create(ctx context.Context, notifications ...*model.TaskNotification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return d.db(ctx).Create(notifications).Error
+}
+
+func (d *notificationStore) Update(ctx context.Context, notification *model.TaskNotification) error {
+	return d.db(ctx).Save(notification).Error
+}
+
+// ListDue 按发送时间返回最多 limit 条已经到达发送时间的通知
+func (d *notificationStore) ListDue(ctx context.Context, now time.Time, limit int) (ret []*model.TaskNotification, err error) {
+	err = d.db(ctx).
+		Where("status = ? AND next_attempt_at <= ?", model.NotificationStatusPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&ret).Error
+	return ret, err
+}
+
+// List 按创建顺序返回任务的所有通知
+func (d *notificationStore) List(ctx context.Context, taskID int64) (ret []*model.TaskNotification, err error) {
+	err = d.db(ctx).Where("task_id = ?", taskID).Order("id").Find(&ret).Error
+	return ret, err
+}
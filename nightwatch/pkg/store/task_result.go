@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+// CreateResult 保存任务单次执行的结果，保存前按大小上限截断
+// 推送和定期同步可能重复保存同一次执行的结果，已经存在时忽略
+func (d *taskStore) CreateResult(ctx context.Context, result *model.TaskResult) error {
+	result.Truncate()
+	return d.db(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}, {Name: "attempt"}},
+		DoNothing: true,
+	}).Create(result).Error
+}
+
+// ListResults 按执行次数返回任务每次执行的结果
+func (d *taskStore) ListResults(ctx context.Context, taskID int64) (ret []*model.TaskResult, err error) {
+	err = d.db(ctx).Where("task_id = ?", taskID).Order("attempt").Find(&ret).Error
+	return ret, err
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/meta"
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type ScheduleStore interface {
+	Create(ctx context.Context, schedule *model.TaskSchedule) error
+	Get(ctx context.Context, scheduleID string) (*model.TaskSchedule, error)
+	List(ctx context.Context, opts ...meta.ListOption) (int64, []*model.TaskSchedule, error)
+	Update(ctx context.Context, schedule *model.TaskSchedule) error
+	UpdateWhere(ctx context.Context, schedule *model.TaskSchedule, conds map[string]any) (bool, error)
+	Delete(ctx context.Context, scheduleID string) error
+}
+
+type scheduleStore struct {
+	ds *datastore
+}
+
+func newScheduleStore(ds *datastore) *scheduleStore {
+	return &scheduleStore{ds}
+}
+
+func (d *scheduleStore) db(ctx context.Context) *gorm.DB {
+	return d.ds.Core(ctx)
+}
+
+func (d *scheduleStore) Create(ctx context.Context, schedule *model.TaskSchedule) error {
+	return d.db(ctx).Create(&schedule).Error
+}
+
+func (d *scheduleStore) Get(ctx context.Context, scheduleID string) (*model.TaskSchedule, error) {
+	schedule := &model.TaskSchedule{}
+	if err := d.db(ctx).Where("id = ?", scheduleID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// 定时任务允许排序的字段
+var scheduleOrderFields = orderFields[*model.TaskSchedule]{
+	"id":         func(s *model.TaskSchedule) any { return s.ID },
+	"created_at": func(s *model.TaskSchedule) any { return cursorTime(s.CreatedAt) },
+	"updated_at": func(s *model.TaskSchedule) any { return cursorTime(s.UpdatedAt) },
+}
+
+func (d *scheduleStore) List(ctx context.Context, opts ...meta.ListOption) (count int64, ret []*model.TaskSchedule, err error) {
+	o := meta.NewListOptions(opts...)
+
+	countQuery, findQuery, err := scheduleOrderFields.list(d.db(ctx), o)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := findQuery.Find(&ret).Error; err != nil {
+		return 0, nil, err
+	}
+	err = countQuery.Model(&model.TaskSchedule{}).Count(&count).Error
+	return count, ret, err
+}
+
+func (d *scheduleStore) Update(ctx context.Context, schedule *model.TaskSchedule) error {
+	return d.db(ctx).Save(schedule).Error
+}
+
+// UpdateWhere 仅当表中的定时任务满足 conds 时才更新，返回是否更新成功
+func (d *scheduleStore) UpdateWhere(ctx context.Context, schedule *model.TaskSchedule, conds map[string]any) (bool, error) {
+	ans := d.db(ctx).Model(schedule).Where(conds).Select("*").Updates(schedule)
+	return ans.RowsAffected > 0, ans.Error
+}
+
+func (d *scheduleStore) Delete(ctx context.Context, scheduleID string) error {
+	err := d.db(ctx).Where("id = ?", scheduleID).Delete(&model.TaskSchedule{}).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jianghushinian/blog-go-example/nightwatch/pkg/model"
+)
+
+type WatcherConfigStore interface {
+	List(ctx context.Context) ([]*model.WatcherConfig, error)
+	Save(ctx context.Context, config *model.WatcherConfig) error
+}
+
+type watcherConfigStore struct {
+	ds *datastore
+}
+
+func newWatcherConfigStore(ds *datastore) *watcherConfigStore {
+	return &watcherConfigStore{ds}
+}
+
+func (d *watcherConfigStore) db(ctx context.Context) *gorm.DB {
+	return d.ds.Core(ctx)
+}
+
+func (d *watcherConfigStore) List(ctx context.Context) (ret []*model.WatcherConfig, err error) {
+	err = d.db(ctx).Order("name").Find(&ret).Error
+	return ret, err
+}
+
+// Save 按名称创建或覆盖 Watcher 配置
+func (d *watcherConfigStore) Save(ctx context.Context, config *model.WatcherConfig) error {
+	return d.db(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "spec", "updated_at"}),
+	}).Create(config).Error
+}